@@ -0,0 +1,164 @@
+package coze
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PCMFormat is the format of linear PCM audio, the zero fields are the defaults of the
+// WebSocket APIs.
+type PCMFormat struct {
+	SampleRate int // 默认 24000
+	Channels   int // 默认 1
+	BitDepth   int // 默认 16
+}
+
+func (f PCMFormat) withDefaults() PCMFormat {
+	if f.SampleRate <= 0 {
+		f.SampleRate = 24000
+	}
+	if f.Channels <= 0 {
+		f.Channels = 1
+	}
+	if f.BitDepth <= 0 {
+		f.BitDepth = 16
+	}
+	return f
+}
+
+// BlockAlign returns the bytes of a sample of all the channels
+func (f PCMFormat) BlockAlign() int {
+	f = f.withDefaults()
+	return f.Channels * ((f.BitDepth + 7) / 8)
+}
+
+// BytesPerSecond returns the bytes of a second of audio
+func (f PCMFormat) BytesPerSecond() int {
+	return f.withDefaults().SampleRate * f.BlockAlign()
+}
+
+// Duration returns the duration of n bytes of audio
+func (f PCMFormat) Duration(n int64) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(f.BytesPerSecond())
+}
+
+// InputAudio returns the input audio config of the pcm, send it by chat.update or
+// transcriptions.update before the audio
+func (f PCMFormat) InputAudio() *WebSocketInputAudio {
+	f = f.withDefaults()
+	return &WebSocketInputAudio{
+		Format:     ptr(string(AudioFormatPCM)),
+		Codec:      ptr("pcm"),
+		SampleRate: ptr(f.SampleRate),
+		Channel:    ptr(f.Channels),
+		BitDepth:   ptr(f.BitDepth),
+	}
+}
+
+// PCMFormat returns the format of the output audio negotiated by speech.update or chat.update,
+// only the pcm codec is linear PCM
+func (o *WebSocketOutputAudio) PCMFormat() (PCMFormat, error) {
+	if o == nil {
+		return PCMFormat{}.withDefaults(), nil
+	}
+	if o.Codec != nil && *o.Codec != "" && *o.Codec != "pcm" {
+		return PCMFormat{}, fmt.Errorf("unsupported output audio codec: %s", *o.Codec)
+	}
+	format := PCMFormat{}
+	if o.PCMConfig != nil && o.PCMConfig.SampleRate != nil {
+		format.SampleRate = *o.PCMConfig.SampleRate
+	}
+	return format.withDefaults(), nil
+}
+
+// the data size of the wav written by streams which don't know the size
+const wavUnknownSize = 0xFFFFFFFF
+
+// wavHeaderSize is the size of the header written by WriteWAVHeader
+const wavHeaderSize = 44
+
+// WriteWAVHeader writes the header of a wav of the format with dataSize bytes of pcm, dataSize
+// is -1 if unknown, like streaming audio.
+func WriteWAVHeader(w io.Writer, format PCMFormat, dataSize int64) error {
+	format = format.withDefaults()
+	size, riffSize := uint32(wavUnknownSize), uint32(wavUnknownSize)
+	if dataSize >= 0 && dataSize <= wavUnknownSize-wavHeaderSize+8 {
+		size, riffSize = uint32(dataSize), uint32(dataSize)+wavHeaderSize-8
+	}
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], riffSize)
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], 1)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(format.BytesPerSecond()))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(format.BlockAlign()))
+	binary.LittleEndian.PutUint16(header[34:36], uint16(format.BitDepth))
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], size)
+	_, err := w.Write(header)
+	return err
+}
+
+// ReadWAVHeader reads the header of a wav up to the audio data, and returns the format of the
+// audio. The rest of r is the pcm audio.
+func ReadWAVHeader(r io.Reader) (PCMFormat, error) {
+	format, _, err := readWAVHeader(r)
+	return format, err
+}
+
+// readWAVHeader returns the format and the size of the data chunk, the size is -1 if unknown
+func readWAVHeader(r io.Reader) (PCMFormat, int64, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return PCMFormat{}, 0, fmt.Errorf("invalid wav header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return PCMFormat{}, 0, errors.New("invalid wav header: not a RIFF WAVE")
+	}
+	var format *PCMFormat
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return PCMFormat{}, 0, fmt.Errorf("invalid wav header: %w", err)
+		}
+		id, size := string(header[0:4]), binary.LittleEndian.Uint32(header[4:8])
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return PCMFormat{}, 0, fmt.Errorf("invalid wav header: fmt size %d", size)
+			}
+			chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return PCMFormat{}, 0, fmt.Errorf("invalid wav header: %w", err)
+			}
+			// 1 is PCM, 0xFFFE is WAVE_FORMAT_EXTENSIBLE which is PCM in practice
+			if audioFormat := binary.LittleEndian.Uint16(chunk[0:2]); audioFormat != 1 && audioFormat != 0xFFFE {
+				return PCMFormat{}, 0, fmt.Errorf("unsupported wav audio format: %d", audioFormat)
+			}
+			format = &PCMFormat{
+				Channels:   int(binary.LittleEndian.Uint16(chunk[2:4])),
+				SampleRate: int(binary.LittleEndian.Uint32(chunk[4:8])),
+				BitDepth:   int(binary.LittleEndian.Uint16(chunk[14:16])),
+			}
+		case "data":
+			if format == nil {
+				return PCMFormat{}, 0, errors.New("invalid wav header: data before fmt")
+			}
+			if size == 0 || size == wavUnknownSize {
+				return *format, -1, nil
+			}
+			return *format, int64(size), nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return PCMFormat{}, 0, fmt.Errorf("invalid wav header: %w", err)
+			}
+		}
+	}
+}
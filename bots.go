@@ -116,6 +116,10 @@ type Bot struct {
 	ModelInfo      *BotModelInfo      `json:"model_info,omitempty"`
 	FolderID       *string            `json:"folder_id,omitempty"`
 	OwnerUserID    *string            `json:"owner_user_id,omitempty"`
+
+	// The knowledge and workflows of the bot, only returned by the v2 API.
+	Knowledge        *BotKnowledge      `json:"knowledge,omitempty"`
+	WorkflowInfoList []*BotWorkflowInfo `json:"workflow_info_list,omitempty"`
 }
 
 // SimpleBot represents simplified bot information
@@ -164,6 +168,14 @@ type WorkflowIDInfo struct {
 	ID string `json:"id"`
 }
 
+// BotWorkflowInfo represents a workflow of a bot
+type BotWorkflowInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	IconURL     string `json:"icon_url,omitempty"`
+}
+
 // BotOnboardingInfo represents bot onboarding information
 type BotOnboardingInfo struct {
 	Prologue           string   `json:"prologue,omitempty"`
@@ -240,15 +252,15 @@ type RetrieveBotsResp struct {
 
 // UpdateBotsReq represents the request structure for updating a bot
 type UpdateBotsReq struct {
-	BotID           string              `json:"bot_id"`            // Bot ID
-	Name            string              `json:"name"`              // Name
-	Description     string              `json:"description"`       // Description
-	IconFileID      string              `json:"icon_file_id"`      // Icon file ID
-	PromptInfo      *BotPromptInfo      `json:"prompt_info"`       // Prompt information
-	OnboardingInfo  *BotOnboardingInfo  `json:"onboarding_info"`   // Onboarding information
-	Knowledge       *BotKnowledge       `json:"knowledge"`         // Knowledge
-	ModelInfoConfig *BotModelInfoConfig `json:"model_info_config"` // ModelInfoConfig information
-	WorkflowIDList  *WorkflowIDList     `json:"workflow_id_list"`  // WorkflowIDList information
+	BotID           string              `json:"bot_id"`                      // Bot ID
+	Name            string              `json:"name"`                        // Name
+	Description     string              `json:"description"`                 // Description
+	IconFileID      string              `json:"icon_file_id"`                // Icon file ID
+	PromptInfo      *BotPromptInfo      `json:"prompt_info,omitempty"`       // Prompt information
+	OnboardingInfo  *BotOnboardingInfo  `json:"onboarding_info,omitempty"`   // Onboarding information
+	Knowledge       *BotKnowledge       `json:"knowledge,omitempty"`         // Knowledge
+	ModelInfoConfig *BotModelInfoConfig `json:"model_info_config,omitempty"` // ModelInfoConfig information
+	WorkflowIDList  *WorkflowIDList     `json:"workflow_id_list,omitempty"`  // WorkflowIDList information
 }
 
 type UpdateBotsResp struct {
@@ -0,0 +1,473 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BotManifestFormat represents the file format of a bot manifest
+type BotManifestFormat string
+
+const (
+	BotManifestFormatJSON BotManifestFormat = "json"
+	BotManifestFormatYAML BotManifestFormat = "yaml"
+)
+
+// BotManifest represents the configuration of a bot as a file, to review bot changes and promote
+// bots between workspaces. Empty fields are not managed by the manifest, they are neither compared
+// nor updated.
+type BotManifest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// The icon of a created or updated bot, it is not exported.
+	IconFileID string `json:"icon_file_id,omitempty"`
+
+	Prompt     string             `json:"prompt,omitempty"`
+	Onboarding *BotOnboardingInfo `json:"onboarding,omitempty"`
+	Model      *BotManifestModel  `json:"model,omitempty"`
+	Knowledge  *BotKnowledge      `json:"knowledge,omitempty"`
+
+	WorkflowIDs []string `json:"workflow_ids,omitempty"`
+
+	// The plugins are exported and compared for review, they can't be configured by the API and are
+	// ignored by Import.
+	Plugins []*BotManifestPlugin `json:"plugins,omitempty"`
+}
+
+// BotManifestModel represents the model of a bot manifest
+type BotManifestModel struct {
+	ModelID    string            `json:"model_id"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// BotManifestPlugin represents a plugin of a bot manifest
+type BotManifestPlugin struct {
+	PluginID string   `json:"plugin_id"`
+	Name     string   `json:"name,omitempty"`
+	APIs     []string `json:"apis,omitempty"`
+}
+
+// NewBotManifest creates the manifest of a bot returned by Bots.Retrieve
+func NewBotManifest(bot *Bot) *BotManifest {
+	manifest := &BotManifest{
+		Name:        bot.Name,
+		Description: bot.Description,
+		Onboarding:  bot.OnboardingInfo,
+		Knowledge:   bot.Knowledge,
+	}
+	if bot.PromptInfo != nil {
+		manifest.Prompt = bot.PromptInfo.Prompt
+	}
+	if bot.ModelInfo != nil {
+		manifest.Model = &BotManifestModel{ModelID: bot.ModelInfo.ModelID, Parameters: bot.ModelInfo.Parameters}
+	}
+	for _, workflow := range bot.WorkflowInfoList {
+		manifest.WorkflowIDs = append(manifest.WorkflowIDs, workflow.ID)
+	}
+	for _, plugin := range bot.PluginInfoList {
+		item := &BotManifestPlugin{PluginID: plugin.PluginID, Name: plugin.Name}
+		for _, api := range plugin.APIInfoList {
+			item.APIs = append(item.APIs, api.Name)
+		}
+		manifest.Plugins = append(manifest.Plugins, item)
+	}
+	return manifest
+}
+
+// MarshalBotManifest encodes a manifest in format, keys are in the order of the fields of BotManifest.
+func MarshalBotManifest(manifest *BotManifest, format BotManifestFormat) ([]byte, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case BotManifestFormatJSON:
+		return append(data, '\n'), nil
+	case BotManifestFormatYAML:
+		// json is yaml, decoding it into a node keeps the order of the keys and the json field names
+		node := &yaml.Node{}
+		if err := yaml.Unmarshal(data, node); err != nil {
+			return nil, err
+		}
+		resetYAMLStyle(node)
+		buf := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(node); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown bot manifest format %q", format)
+	}
+}
+
+// UnmarshalBotManifest decodes a manifest encoded by MarshalBotManifest
+func UnmarshalBotManifest(data []byte, format BotManifestFormat) (*BotManifest, error) {
+	manifest := &BotManifest{}
+	switch format {
+	case BotManifestFormatJSON:
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, err
+		}
+	case BotManifestFormatYAML:
+		var value any
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		// the manifest is decoded by its json tags
+		jsonData, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(jsonData, manifest); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown bot manifest format %q", format)
+	}
+	if manifest.Name == "" {
+		return nil, errors.New("bot manifest name is required")
+	}
+	return manifest, nil
+}
+
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// BotManifestChangeType represents how a field of a bot differs from a manifest
+type BotManifestChangeType string
+
+const (
+	BotManifestChangeAdd    BotManifestChangeType = "add"
+	BotManifestChangeModify BotManifestChangeType = "modify"
+)
+
+// BotManifestChange represents a field of a bot different from a manifest
+type BotManifestChange struct {
+	Type BotManifestChangeType
+
+	// The dot separated json path of the field, such as "model.parameters.temperature".
+	Path string
+
+	// The current and the desired value, decoded from json.
+	Old any
+	New any
+}
+
+// String returns a one line description of the change, such as `~ prompt: "a" -> "b"`.
+func (c *BotManifestChange) String() string {
+	if c.Type == BotManifestChangeAdd {
+		return fmt.Sprintf("+ %s: %s", c.Path, formatManifestValue(c.New))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, formatManifestValue(c.Old), formatManifestValue(c.New))
+}
+
+func formatManifestValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// DiffBotManifests returns the fields of current to change to match desired, sorted by path. Only
+// the fields set in desired are compared, lists are compared as a whole.
+func DiffBotManifests(current, desired *BotManifest) ([]*BotManifestChange, error) {
+	currentValue, err := manifestValue(current)
+	if err != nil {
+		return nil, err
+	}
+	desiredValue, err := manifestValue(desired)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*BotManifestChange
+	diffManifestValues("", currentValue, desiredValue, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// FormatBotManifestChanges returns the changes one per line
+func FormatBotManifestChanges(changes []*BotManifestChange) string {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func manifestValue(manifest *BotManifest) (map[string]any, error) {
+	value := map[string]any{}
+	if manifest == nil {
+		return value, nil
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return value, json.Unmarshal(data, &value)
+}
+
+func diffManifestValues(path string, current, desired any, changes *[]*BotManifestChange) {
+	desiredMap, ok := desired.(map[string]any)
+	if !ok {
+		if !reflect.DeepEqual(current, desired) {
+			typ := BotManifestChangeModify
+			if current == nil {
+				typ = BotManifestChangeAdd
+			}
+			*changes = append(*changes, &BotManifestChange{Type: typ, Path: path, Old: current, New: desired})
+		}
+		return
+	}
+	currentMap, _ := current.(map[string]any)
+	for key, value := range desiredMap {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+		diffManifestValues(childPath, currentMap[key], value, changes)
+	}
+}
+
+// Export returns the manifest of the draft of a bot
+func (r *bots) Export(ctx context.Context, botID string) (*BotManifest, error) {
+	bot, err := r.Retrieve(ctx, &RetrieveBotsReq{BotID: botID, IsPublished: ptr(false), UseAPIVersion: 2})
+	if err != nil {
+		return nil, err
+	}
+	return NewBotManifest(&bot.Bot), nil
+}
+
+// Diff returns the fields of a bot to change to match manifest, see DiffBotManifests.
+func (r *bots) Diff(ctx context.Context, botID string, manifest *BotManifest) ([]*BotManifestChange, error) {
+	current, err := r.Export(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	return DiffBotManifests(current, manifest)
+}
+
+// Import creates or updates a bot to match req.Manifest, a bot already matching the manifest is not
+// updated, so importing the same manifest again does nothing.
+func (r *bots) Import(ctx context.Context, req *ImportBotManifestReq) (*ImportBotManifestResp, error) {
+	if req.Manifest == nil || req.Manifest.Name == "" {
+		return nil, errors.New("bot manifest name is required")
+	}
+	// the plugins can't be configured, they are not imported
+	manifest := *req.Manifest
+	manifest.Plugins = nil
+
+	botID := req.BotID
+	if botID == "" && req.SpaceID != "" {
+		found, err := r.findBot(ctx, req.SpaceID, manifest.Name)
+		if err != nil {
+			return nil, err
+		}
+		botID = found
+	}
+
+	resp := &ImportBotManifestResp{BotID: botID, DryRun: req.DryRun}
+	if botID == "" {
+		if req.SpaceID == "" {
+			return nil, errors.New("space id is required to create a bot")
+		}
+		resp.Created = true
+		resp.Changes, _ = DiffBotManifests(nil, &manifest)
+		if req.DryRun {
+			return resp, nil
+		}
+		created, err := r.Create(ctx, manifest.createReq(req.SpaceID))
+		if err != nil {
+			return nil, err
+		}
+		resp.BotID = created.BotID
+		// the knowledge can't be set on creation
+		if manifest.Knowledge != nil {
+			if _, err := r.Update(ctx, manifest.updateReq(resp.BotID, &manifest)); err != nil {
+				return resp, err
+			}
+		}
+		return resp, nil
+	}
+
+	current, err := r.Export(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	current.Plugins = nil
+	if resp.Changes, err = DiffBotManifests(current, &manifest); err != nil {
+		return nil, err
+	}
+	// the icon is not exported, so it is always updated if set
+	if req.DryRun || (len(resp.Changes) == 0 && manifest.IconFileID == "") {
+		return resp, nil
+	}
+	if _, err := r.Update(ctx, manifest.updateReq(botID, current)); err != nil {
+		return nil, err
+	}
+	resp.Updated = true
+	return resp, nil
+}
+
+// ImportBotManifestReq represents request for importing a bot manifest
+type ImportBotManifestReq struct {
+	// The manifest of the bot.
+	Manifest *BotManifest
+
+	// Optional: The ID of the bot to update. If empty, the bot of the space with the name of the
+	// manifest is updated, published or not, a bot is created if there is none.
+	BotID string
+
+	// Optional: The ID of the space, required to create a bot.
+	SpaceID string
+
+	// Optional: Only compute the changes, no bot is created or updated.
+	DryRun bool
+}
+
+// ImportBotManifestResp represents response for importing a bot manifest
+type ImportBotManifestResp struct {
+	// The ID of the created or updated bot, empty if a bot would be created in a dry run.
+	BotID   string
+	Created bool
+	Updated bool
+	DryRun  bool
+
+	// The changes of the bot, all the fields of the manifest if it is created.
+	Changes []*BotManifestChange
+}
+
+// findBot finds the bot of the space by name. The drafts are included, since the bots created by
+// Import are not published, which are not listed by Bots.List.
+func (r *bots) findBot(ctx context.Context, spaceID, name string) (string, error) {
+	paged, err := NewNumberPaged(
+		func(request *pageRequest) (*pageResponse[botDraft], error) {
+			resp := new(listBotDraftsResp)
+			err := r.core.rawRequest(ctx, &RawRequestReq{
+				Method: http.MethodGet,
+				URL:    "/v1/bots",
+				Body: &listBotDraftsReq{
+					WorkspaceID:   spaceID,
+					PublishStatus: ptr(PublishStatusALL),
+					PageNum:       request.PageNum,
+					PageSize:      request.PageSize,
+				},
+			}, resp)
+			if err != nil {
+				return nil, err
+			}
+			return &pageResponse[botDraft]{
+				response: resp.HTTPResponse,
+				HasMore:  len(resp.Data.Items) >= request.PageSize,
+				Data:     resp.Data.Items,
+				LogID:    resp.HTTPResponse.LogID(),
+			}, nil
+		}, 100, 1)
+	if err != nil {
+		return "", err
+	}
+	for paged.Next() {
+		if bot := paged.Current(); bot.Name == name {
+			return bot.ID, nil
+		}
+	}
+	return "", paged.Err()
+}
+
+type listBotDraftsReq struct {
+	WorkspaceID   string         `query:"workspace_id" json:"-"`
+	PublishStatus *PublishStatus `query:"publish_status" json:"-"`
+	PageNum       int            `query:"page_num" json:"-"`
+	PageSize      int            `query:"page_size" json:"-"`
+}
+
+type listBotDraftsResp struct {
+	baseResponse
+	Data struct {
+		Total int         `json:"total"`
+		Items []*botDraft `json:"items"`
+	} `json:"data"`
+}
+
+type botDraft struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (m *BotManifest) createReq(spaceID string) *CreateBotsReq {
+	return &CreateBotsReq{
+		SpaceID:         spaceID,
+		Name:            m.Name,
+		Description:     m.Description,
+		IconFileID:      m.IconFileID,
+		PromptInfo:      m.promptInfo(),
+		OnboardingInfo:  m.Onboarding,
+		ModelInfoConfig: m.modelInfoConfig(),
+		WorkflowIDList:  m.workflowIDList(),
+	}
+}
+
+// updateReq keeps the description of current if the manifest has none, since it is always sent
+func (m *BotManifest) updateReq(botID string, current *BotManifest) *UpdateBotsReq {
+	description := m.Description
+	if description == "" {
+		description = current.Description
+	}
+	return &UpdateBotsReq{
+		BotID:           botID,
+		Name:            m.Name,
+		Description:     description,
+		IconFileID:      m.IconFileID,
+		PromptInfo:      m.promptInfo(),
+		OnboardingInfo:  m.Onboarding,
+		Knowledge:       m.Knowledge,
+		ModelInfoConfig: m.modelInfoConfig(),
+		WorkflowIDList:  m.workflowIDList(),
+	}
+}
+
+func (m *BotManifest) promptInfo() *BotPromptInfo {
+	if m.Prompt == "" {
+		return nil
+	}
+	return &BotPromptInfo{Prompt: m.Prompt}
+}
+
+func (m *BotManifest) modelInfoConfig() *BotModelInfoConfig {
+	if m.Model == nil {
+		return nil
+	}
+	return &BotModelInfoConfig{ModelID: m.Model.ModelID, Parameters: m.Model.Parameters}
+}
+
+func (m *BotManifest) workflowIDList() *WorkflowIDList {
+	if len(m.WorkflowIDs) == 0 {
+		return nil
+	}
+	list := &WorkflowIDList{}
+	for _, id := range m.WorkflowIDs {
+		list.IDs = append(list.IDs, WorkflowIDInfo{ID: id})
+	}
+	return list
+}
@@ -0,0 +1,182 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotsManifest(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	liveBot := func() *Bot {
+		return &Bot{
+			BotID:          "bot1",
+			Name:           "Helper",
+			Description:    "helps",
+			PromptInfo:     &BotPromptInfo{Prompt: "You are helpful.\nBe brief."},
+			OnboardingInfo: &BotOnboardingInfo{Prologue: "Hi", SuggestedQuestions: []string{"Q1"}},
+			ModelInfo:      &BotModelInfo{ModelID: "m1", ModelName: "Model", Parameters: map[string]string{"temperature": "0.7", "top_p": "1"}},
+			Knowledge:      &BotKnowledge{DatasetIDs: []string{"d1"}, AutoCall: true},
+			WorkflowInfoList: []*BotWorkflowInfo{
+				{ID: "w1", Name: "flow"},
+			},
+			PluginInfoList: []*BotPluginInfo{
+				{PluginID: "p1", Name: "search", APIInfoList: []*BotPluginAPIInfo{{APIID: "a1", Name: "web"}}},
+			},
+		}
+	}
+
+	var created *CreateBotsReq
+	var updated *UpdateBotsReq
+	newManifestBots := func(drafts []*botDraft) *bots {
+		created, updated = nil, nil
+		return newBots(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			var body []byte
+			if req.Body != nil {
+				body, _ = io.ReadAll(req.Body)
+			}
+			switch req.URL.Path {
+			case "/v1/bots/bot1", "/v1/bots/bot2":
+				as.Equal("false", req.URL.Query().Get("is_published"))
+				bot := liveBot()
+				bot.BotID = strings.TrimPrefix(req.URL.Path, "/v1/bots/")
+				return mockResponse(http.StatusOK, &retrieveBotsResp{Data: &RetrieveBotsResp{Bot: *bot}})
+			case "/v1/bots":
+				as.Equal("space1", req.URL.Query().Get("workspace_id"))
+				as.Equal("all", req.URL.Query().Get("publish_status"))
+				resp := &listBotDraftsResp{}
+				resp.Data.Items = drafts
+				resp.Data.Total = len(drafts)
+				return mockResponse(http.StatusOK, resp)
+			case "/v1/bot/create":
+				created = &CreateBotsReq{}
+				as.Nil(json.Unmarshal(body, created))
+				// the created bot is an unpublished draft
+				drafts = append(drafts, &botDraft{ID: "bot2", Name: created.Name})
+				return mockResponse(http.StatusOK, &createBotsResp{Data: &CreateBotsResp{BotID: "bot2"}})
+			case "/v1/bot/update":
+				updated = &UpdateBotsReq{}
+				as.Nil(json.Unmarshal(body, updated))
+				return mockResponse(http.StatusOK, &updateBotsResp{Data: &UpdateBotsResp{}})
+			default:
+				t.Fatalf("unexpected request: %s", req.URL)
+				return nil, nil
+			}
+		})))
+	}
+
+	t.Run("export and round trip", func(t *testing.T) {
+		manifest, err := newManifestBots(nil).Export(ctx, "bot1")
+		as.Nil(err)
+		as.Equal([]string{"w1"}, manifest.WorkflowIDs)
+		as.Equal([]string{"web"}, manifest.Plugins[0].APIs)
+
+		for _, format := range []BotManifestFormat{BotManifestFormatJSON, BotManifestFormatYAML} {
+			data, err := MarshalBotManifest(manifest, format)
+			as.Nil(err)
+			loaded, err := UnmarshalBotManifest(data, format)
+			as.Nil(err)
+			as.Equal(manifest, loaded)
+		}
+
+		data, err := MarshalBotManifest(manifest, BotManifestFormatYAML)
+		as.Nil(err)
+		as.Contains(string(data), "name: Helper\ndescription: helps\nprompt: |-\n  You are helpful.\n  Be brief.\n")
+		as.Contains(string(data), `temperature: "0.7"`)
+
+		_, err = UnmarshalBotManifest([]byte("description: x"), BotManifestFormatYAML)
+		as.NotNil(err)
+	})
+
+	t.Run("diff only managed fields", func(t *testing.T) {
+		changes, err := newManifestBots(nil).Diff(ctx, "bot1", &BotManifest{
+			Name:        "Helper",
+			Prompt:      "You are helpful.",
+			Model:       &BotManifestModel{ModelID: "m1", Parameters: map[string]string{"temperature": "0.2"}},
+			WorkflowIDs: []string{"w1", "w2"},
+			Onboarding:  &BotOnboardingInfo{Prologue: "Hi", SuggestedQuestions: []string{"Q1"}},
+		})
+		as.Nil(err)
+		as.Equal(`~ model.parameters.temperature: "0.7" -> "0.2"
+~ prompt: "You are helpful.\nBe brief." -> "You are helpful."
+~ workflow_ids: ["w1"] -> ["w1","w2"]`, FormatBotManifestChanges(changes))
+	})
+
+	t.Run("import is idempotent", func(t *testing.T) {
+		bots := newManifestBots(nil)
+		manifest := NewBotManifest(liveBot())
+		resp, err := bots.Import(ctx, &ImportBotManifestReq{BotID: "bot1", Manifest: manifest})
+		as.Nil(err)
+		as.False(resp.Updated)
+		as.Empty(resp.Changes)
+		as.Nil(updated)
+
+		manifest.Prompt = "new prompt"
+		manifest.Description = ""
+		manifest.Plugins = []*BotManifestPlugin{{PluginID: "p2"}}
+		resp, err = bots.Import(ctx, &ImportBotManifestReq{BotID: "bot1", Manifest: manifest, DryRun: true})
+		as.Nil(err)
+		as.Len(resp.Changes, 1)
+		as.Nil(updated)
+
+		resp, err = bots.Import(ctx, &ImportBotManifestReq{BotID: "bot1", Manifest: manifest})
+		as.Nil(err)
+		as.True(resp.Updated)
+		as.Equal("new prompt", updated.PromptInfo.Prompt)
+		as.Equal("helps", updated.Description)
+		as.Equal("w1", updated.WorkflowIDList.IDs[0].ID)
+	})
+
+	t.Run("import finds or creates by name", func(t *testing.T) {
+		bots := newManifestBots([]*botDraft{{ID: "bot1", Name: "Helper"}})
+		resp, err := bots.Import(ctx, &ImportBotManifestReq{SpaceID: "space1", Manifest: NewBotManifest(liveBot())})
+		as.Nil(err)
+		as.Equal("bot1", resp.BotID)
+		as.False(resp.Created)
+		as.Nil(created)
+
+		bots = newManifestBots(nil)
+		resp, err = bots.Import(ctx, &ImportBotManifestReq{SpaceID: "space1", Manifest: NewBotManifest(liveBot())})
+		as.Nil(err)
+		as.True(resp.Created)
+		as.Equal("bot2", resp.BotID)
+		as.Equal("space1", created.SpaceID)
+		as.Equal("m1", created.ModelInfoConfig.ModelID)
+		as.Equal([]string{"d1"}, updated.Knowledge.DatasetIDs)
+		as.Equal("bot2", updated.BotID)
+
+		_, err = bots.Import(ctx, &ImportBotManifestReq{Manifest: &BotManifest{Name: "x"}})
+		as.NotNil(err)
+	})
+
+	t.Run("import twice into a space", func(t *testing.T) {
+		bots := newManifestBots(nil)
+		resp, err := bots.Import(ctx, &ImportBotManifestReq{SpaceID: "space1", Manifest: NewBotManifest(liveBot())})
+		as.Nil(err)
+		as.True(resp.Created)
+
+		created, updated = nil, nil
+		resp, err = bots.Import(ctx, &ImportBotManifestReq{SpaceID: "space1", Manifest: NewBotManifest(liveBot())})
+		as.Nil(err)
+		as.Equal("bot2", resp.BotID)
+		as.False(resp.Created)
+		as.False(resp.Updated)
+		as.Empty(resp.Changes)
+		as.Nil(created)
+		as.Nil(updated)
+	})
+
+	t.Run("update without knowledge", func(t *testing.T) {
+		req := (&BotManifest{Name: "Helper"}).updateReq("bot1", &BotManifest{})
+		data, err := json.Marshal(req)
+		as.Nil(err)
+		as.NotContains(string(data), "knowledge")
+	})
+}
@@ -2,6 +2,7 @@ package coze
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"testing"
 
@@ -73,6 +74,27 @@ func TestBots(t *testing.T) {
 		as.NotEmpty(resp.Response().LogID())
 	})
 
+	t.Run("update bot omits the unset configs", func(t *testing.T) {
+		var body map[string]interface{}
+		bots := newBots(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			require.Nil(t, json.NewDecoder(req.Body).Decode(&body))
+			return mockResponse(http.StatusOK, &updateBotsResp{})
+		})))
+		_, err := bots.Update(context.Background(), &UpdateBotsReq{
+			BotID: "test_bot_id",
+			Name:  "Updated Bot",
+			PromptInfo: &BotPromptInfo{
+				Prompt: "Updated Prompt",
+			},
+		})
+		as.Nil(err)
+		as.Equal("test_bot_id", body["bot_id"])
+		as.Contains(body, "prompt_info")
+		for _, key := range []string{"onboarding_info", "knowledge", "model_info_config", "workflow_id_list"} {
+			as.NotContains(body, key)
+		}
+	})
+
 	t.Run("publish bot", func(t *testing.T) {
 		botID := randomString(10)
 		bots := newBots(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
@@ -11,6 +11,17 @@ import (
 )
 
 func (r *chat) Create(ctx context.Context, req *CreateChatsReq) (*CreateChatsResp, error) {
+	if err := r.client.checkUsage(ctx, req.usageScope()); err != nil {
+		return nil, err
+	}
+	resp, err := r.create(ctx, req)
+	if err == nil && resp != nil && resp.Status == ChatStatusCompleted {
+		r.client.recordChatUsage(ctx, UsageSourceChat, &resp.Chat, req.UserID)
+	}
+	return resp, err
+}
+
+func (r *chat) create(ctx context.Context, req *CreateChatsReq) (*CreateChatsResp, error) {
 	req.Stream = ptr(false)
 	req.AutoSaveHistory = ptr(true)
 
@@ -28,7 +39,10 @@ func (r *chat) CreateAndPoll(ctx context.Context, req *CreateChatsReq, timeout *
 	req.Stream = ptr(false)
 	req.AutoSaveHistory = ptr(true)
 
-	chatResp, err := r.Create(ctx, req)
+	if err := r.client.checkUsage(ctx, req.usageScope()); err != nil {
+		return nil, err
+	}
+	chatResp, err := r.create(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +77,9 @@ func (r *chat) CreateAndPoll(ctx context.Context, req *CreateChatsReq, timeout *
 			break
 		}
 	}
+	if chat.Status == ChatStatusCompleted {
+		r.client.recordChatUsage(ctx, UsageSourceChat, &chat, req.UserID)
+	}
 	messages, err := r.Messages.List(ctx, &ListChatsMessagesReq{
 		ConversationID: conversationID,
 		ChatID:         chat.ID,
@@ -79,6 +96,9 @@ func (r *chat) CreateAndPoll(ctx context.Context, req *CreateChatsReq, timeout *
 func (r *chat) Stream(ctx context.Context, req *CreateChatsReq) (Stream[ChatEvent], error) {
 	req.Stream = ptr(true)
 
+	if err := r.client.checkUsage(ctx, req.usageScope()); err != nil {
+		return nil, err
+	}
 	request := &RawRequestReq{
 		Method: http.MethodPost,
 		URL:    "/v3/chat",
@@ -86,7 +106,7 @@ func (r *chat) Stream(ctx context.Context, req *CreateChatsReq) (Stream[ChatEven
 	}
 	response := new(createChatsResp)
 	err := r.client.rawRequest(ctx, request, response)
-	return newStream(ctx, r.client, response.HTTPResponse, parseChatEvent), err
+	return newStream(ctx, r.client, response.HTTPResponse, r.client.chatUsageProcessor(req.UserID)), err
 }
 
 func (r *chat) Cancel(ctx context.Context, req *CancelChatsReq) (*CancelChatsResp, error) {
@@ -121,6 +141,9 @@ func (r *chat) SubmitToolOutputs(ctx context.Context, req *SubmitToolOutputsChat
 	}
 	response := new(submitToolOutputsChatResp)
 	err := r.client.rawRequest(ctx, request, response)
+	if err == nil && response.Chat != nil && response.Chat.Status == ChatStatusCompleted {
+		r.client.recordChatUsage(ctx, UsageSourceChat, &response.Chat.Chat, "")
+	}
 	return response.Chat, err
 }
 
@@ -134,7 +157,7 @@ func (r *chat) StreamSubmitToolOutputs(ctx context.Context, req *SubmitToolOutpu
 	}
 	response := new(submitToolOutputsChatResp)
 	err := r.client.rawRequest(ctx, request, response)
-	return newStream(ctx, r.client, response.HTTPResponse, parseChatEvent), err
+	return newStream(ctx, r.client, response.HTTPResponse, r.client.chatUsageProcessor("")), err
 }
 
 // ChatStatus The running status of the session.
@@ -304,11 +327,19 @@ type CreateChatsReq struct {
 
 	// Optional: Support card response for question node
 	EnableCard *bool `json:"enable_card,omitempty"`
-	
+
 	// 指令相关
 	ShortcutCommand *ShortcutCommand `json:"shortcut_command,omitempty"`
 }
 
+func (r *CreateChatsReq) usageScope() UsageScope {
+	return UsageScope{
+		BotID:          r.BotID,
+		UserID:         r.UserID,
+		ConversationID: r.ConversationID,
+	}
+}
+
 type ShortcutCommand struct {
 	// 指令ID
 	CommandId string `json:"command_id"`
@@ -25,13 +25,14 @@ type CozeAPI struct {
 }
 
 type clientOption struct {
-	baseURL     string
-	client      HTTPClient
-	logLevel    LogLevel
-	logger      Logger
-	auth        Auth
-	enableLogID bool
-	headers     http.Header
+	baseURL      string
+	client       HTTPClient
+	logLevel     LogLevel
+	logger       Logger
+	auth         Auth
+	enableLogID  bool
+	headers      http.Header
+	usageTracker UsageTracker
 }
 
 type CozeAPIOption func(*clientOption)
@@ -76,6 +77,14 @@ func WithHeaders(headers http.Header) CozeAPIOption {
 	}
 }
 
+// WithUsageTracker sets the usage tracker of chats (Create, CreateAndPoll, Stream, tool outputs and
+// websocket chats) and workflow runs (Create, Stream and Resume).
+func WithUsageTracker(tracker UsageTracker) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.usageTracker = tracker
+	}
+}
+
 func NewCozeAPI(auth Auth, opts ...CozeAPIOption) CozeAPI {
 	opt := &clientOption{
 		baseURL:  ComBaseURL,
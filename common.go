@@ -108,6 +108,10 @@ const (
 	// the reply content related to the recommended questions will be returned.
 	MessageTypeFollowUp MessageType = "follow_up"
 
+	// MessageTypeVerbose Intermediate information of the chat process, such as the knowledge recall
+	// result and the end of the answer, see Message.Verbose.
+	MessageTypeVerbose MessageType = "verbose"
+
 	MessageTypeUnknown MessageType = ""
 )
 
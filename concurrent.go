@@ -0,0 +1,58 @@
+package coze
+
+import (
+	"context"
+	"sync"
+)
+
+// runConcurrently calls fn for the indexes [0, n) by at most concurrency goroutines, concurrency
+// less than 1 is 1. No more index is started once ctx is done or fn returns an error, the ctx
+// passed to fn is canceled on the first error.
+//
+// It returns the first error of fn, or the error of ctx if some indexes are not started. fn
+// returns nil to go on with the other indexes, like recording the error in its result.
+func runConcurrently(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	started := 0
+	for ; started < n; started++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// the slot may be acquired together with the cancellation
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(started)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if started < n {
+		return ctx.Err()
+	}
+	return nil
+}
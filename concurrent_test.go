@@ -0,0 +1,74 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("bounded", func(t *testing.T) {
+		var running, maxRunning int32
+		var mu sync.Mutex
+		done := make([]bool, 10)
+		err := runConcurrently(ctx, len(done), 3, func(ctx context.Context, i int) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			mu.Lock()
+			if n > maxRunning {
+				maxRunning = n
+			}
+			done[i] = true
+			mu.Unlock()
+			return nil
+		})
+		as.Nil(err)
+		as.LessOrEqual(maxRunning, int32(3))
+		for _, ok := range done {
+			as.True(ok)
+		}
+	})
+
+	t.Run("stop at first error", func(t *testing.T) {
+		var started int32
+		err := runConcurrently(ctx, 10, 1, func(ctx context.Context, i int) error {
+			atomic.AddInt32(&started, 1)
+			if i == 2 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		as.EqualError(err, "failed")
+		as.Equal(int32(3), started)
+	})
+
+	t.Run("stop on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		var started int32
+		err := runConcurrently(ctx, 10, 1, func(ctx context.Context, i int) error {
+			atomic.AddInt32(&started, 1)
+			if i == 1 {
+				cancel()
+			}
+			return nil
+		})
+		as.ErrorIs(err, context.Canceled)
+		as.Equal(int32(2), started)
+	})
+
+	t.Run("done before cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		err := runConcurrently(ctx, 2, 2, func(ctx context.Context, i int) error {
+			return nil
+		})
+		cancel()
+		as.Nil(err)
+	})
+}
@@ -0,0 +1,4 @@
+// Package cozetest provides in-process servers emulating the Coze API, so the applications built
+// on the SDK can be tested without network. Server emulates the REST and SSE endpoints, and
+// WebSocketServer the realtime ones. Point the client to a server with coze.WithBaseURL.
+package cozetest
@@ -0,0 +1,416 @@
+package cozetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The codes of the errors returned by Server.
+const (
+	CodeInvalidParam = 4000
+	CodeNotFound     = 4004
+	CodeRateLimited  = 4013
+	CodeUnauthorized = 4100
+)
+
+// Server is an in-process server of the REST and SSE endpoints of Coze. It keeps the bots,
+// conversations, messages, chats, datasets, documents and files in memory, so the SDK calls behave
+// like against the real API: a created conversation can be retrieved, a chat adds its messages to
+// the conversation, and so on. The chats and the workflow streams run scripted replies, and the
+// responses of any endpoint can be replaced with Handle or failed with Inject.
+//
+//	server := cozetest.NewServer()
+//	defer server.Close()
+//	server.ChatReply(cozetest.StreamMessage("Hel", "lo"))
+//	api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+type Server struct {
+	// The base URL of the server, like http://127.0.0.1:12345.
+	URL string
+
+	server *httptest.Server
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // the chats running after their response
+
+	mu       sync.Mutex
+	seq      int64
+	routes   []*route
+	handlers []*route // set by Handle, matched before routes
+	faults   []*fault
+	requests []*Request
+	state
+}
+
+// NewServer starts a server, close it after the test.
+func NewServer() *Server {
+	s := &Server{seq: 7000000000000000000}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.state = newState()
+	s.registerResources()
+	s.registerStreams()
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close stops the running chats and the server.
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+	s.server.Close()
+}
+
+// Handle replaces the responses of method and path with handler, path may have parameters like
+// /v1/bots/:bot_id. The requests are still recorded and the injected faults still apply.
+func (s *Server) Handle(method, path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append([]*route{newRoute(method, path, func(c *call) { handler(c.w, c.r) })}, s.handlers...)
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// DecodeBody decodes the json body of the request into v, like *coze.CreateChatsReq.
+func (r *Request) DecodeBody(v any) error {
+	if len(r.Body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Body, v)
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request{}, s.requests...)
+}
+
+// RequestsTo returns the requests of method and path received so far, path may have parameters
+// like /v1/bots/:bot_id, any method matches if method is empty.
+func (s *Server) RequestsTo(method, path string) []*Request {
+	matcher := newRoute(method, path, nil)
+	var requests []*Request
+	for _, request := range s.Requests() {
+		if _, ok := matcher.match(request.Method, request.Path); ok {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// TestingT is the subset of testing.TB used by the assertions.
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+// AssertRequested asserts method and path are requested, and returns the last request of them.
+func (s *Server) AssertRequested(t TestingT, method, path string) *Request {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	requests := s.RequestsTo(method, path)
+	if len(requests) == 0 {
+		t.Errorf("expected request %s %s, requested: %s", method, path, s.requested())
+		return nil
+	}
+	return requests[len(requests)-1]
+}
+
+// AssertNotRequested asserts method and path are not requested.
+func (s *Server) AssertNotRequested(t TestingT, method, path string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if n := len(s.RequestsTo(method, path)); n > 0 {
+		t.Errorf("unexpected request %s %s, requested %d times", method, path, n)
+		return false
+	}
+	return true
+}
+
+// AssertRequestCount asserts method and path are requested n times.
+func (s *Server) AssertRequestCount(t TestingT, method, path string, n int) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if count := len(s.RequestsTo(method, path)); count != n {
+		t.Errorf("expected request %s %s %d times, requested %d times", method, path, n, count)
+		return false
+	}
+	return true
+}
+
+func (s *Server) requested() string {
+	var requested []string
+	for _, request := range s.Requests() {
+		requested = append(requested, request.Method+" "+request.Path)
+	}
+	return "[" + strings.Join(requested, ", ") + "]"
+}
+
+// Fault is an error injected into the responses of an endpoint.
+type Fault struct {
+	// The code of the error, returned in the json body like the errors of Coze.
+	Code int
+	Msg  string
+	// The status of the response, default is 200. The body is empty if Code is 0, like the 429 of
+	// a gateway.
+	HTTPStatus int
+	// The delay of the response. A fault with only Latency delays the response without failing it.
+	Latency time.Duration
+	// The number of requests the fault applies to, 0 is all the requests.
+	Times int
+}
+
+type fault struct {
+	Fault
+	matcher *route
+	applied int
+}
+
+// Inject applies fault to the next requests of method and path, path may have parameters like
+// /v1/bots/:bot_id, any method matches if method is empty. The faults apply in the order they are
+// injected, one per request.
+func (s *Server) Inject(method, path string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{Fault: f, matcher: newRoute(method, path, nil)})
+}
+
+// RateLimit fails the next times requests of method and path with 429 and CodeRateLimited.
+func (s *Server) RateLimit(method, path string, times int) {
+	s.Inject(method, path, Fault{Code: CodeRateLimited, Msg: "rate limit exceeded", HTTPStatus: http.StatusTooManyRequests, Times: times})
+}
+
+// ClearFaults removes the injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// nextFault returns the fault applying to the request, if any
+func (s *Server) nextFault(method, path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if _, ok := f.matcher.match(method, path); !ok {
+			continue
+		}
+		f.applied++
+		if f.Times > 0 && f.applied >= f.Times {
+			s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+		}
+		applied := f.Fault
+		return &applied
+	}
+	return nil
+}
+
+func (s *Server) newID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newIDLocked()
+}
+
+func (s *Server) newIDLocked() string {
+	s.seq++
+	return strconv.FormatInt(s.seq, 10)
+}
+
+// route is an endpoint, the segments starting with : of the pattern are parameters
+type route struct {
+	method  string
+	pattern []string
+	handle  func(c *call)
+}
+
+func newRoute(method, path string, handle func(c *call)) *route {
+	return &route{method: method, pattern: strings.Split(strings.Trim(path, "/"), "/"), handle: handle}
+}
+
+func (r *route) match(method, path string) (map[string]string, bool) {
+	if r.method != "" && r.method != method {
+		return nil, false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(r.pattern) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range r.pattern {
+		if strings.HasPrefix(segment, ":") {
+			params[segment[1:]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (s *Server) register(method, path string, handle func(c *call)) {
+	s.routes = append(s.routes, newRoute(method, path, handle))
+}
+
+func (s *Server) findRoute(method, path string) (*route, map[string]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, routes := range [][]*route{s.handlers, s.routes} {
+		for _, r := range routes {
+			if params, ok := r.match(method, path); ok {
+				return r, params, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// call is a request being served
+type call struct {
+	server *Server
+	w      http.ResponseWriter
+	r      *http.Request
+	params map[string]string
+	body   []byte
+	logID  string
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	s.mu.Lock()
+	s.requests = append(s.requests, &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	logID := fmt.Sprintf("cozetest_%d", len(s.requests))
+	s.mu.Unlock()
+
+	c := &call{server: s, w: w, r: r, body: body, logID: logID}
+	w.Header().Set("X-Tt-Logid", logID)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		c.failStatus(http.StatusUnauthorized, CodeUnauthorized, "authentication is invalid")
+		return
+	}
+	if f := s.nextFault(r.Method, r.URL.Path); f != nil {
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if f.Code != 0 {
+			c.failStatus(f.HTTPStatus, f.Code, f.Msg)
+			return
+		}
+		if f.HTTPStatus != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(f.HTTPStatus)
+			return
+		}
+	}
+	route, params, ok := s.findRoute(r.Method, r.URL.Path)
+	if !ok {
+		c.failStatus(http.StatusNotFound, CodeNotFound, fmt.Sprintf("%s %s not found", r.Method, r.URL.Path))
+		return
+	}
+	c.params = params
+	route.handle(c)
+}
+
+// decode decodes the json body into v, it answers CodeInvalidParam and returns false if it fails
+func (c *call) decode(v any) bool {
+	if len(c.body) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(c.body, v); err != nil {
+		c.fail(CodeInvalidParam, fmt.Sprintf("invalid body: %s", err))
+		return false
+	}
+	return true
+}
+
+func (c *call) query(key string) string {
+	return c.r.URL.Query().Get(key)
+}
+
+// queryInt returns the int query parameter, or def if it's absent or invalid
+func (c *call) queryInt(key string, def int) int {
+	if v, err := strconv.Atoi(c.query(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// data answers data in the data field
+func (c *call) data(data any) {
+	c.ok(map[string]any{"data": data})
+}
+
+// ok answers the fields at the top level
+func (c *call) ok(fields map[string]any) {
+	body := map[string]any{"code": 0, "msg": ""}
+	for k, v := range fields {
+		body[k] = v
+	}
+	c.writeJSON(http.StatusOK, body)
+}
+
+// fail answers an error of Coze
+func (c *call) fail(code int, msg string) {
+	c.failStatus(http.StatusOK, code, msg)
+}
+
+// failStatus answers an error of Coze with the http status, 200 if status is 0
+func (c *call) failStatus(status, code int, msg string) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c.writeJSON(status, map[string]any{"code": code, "msg": msg, "detail": map[string]string{"logid": c.logID}})
+}
+
+func (c *call) writeJSON(status int, body any) {
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(status)
+	_ = json.NewEncoder(c.w).Encode(body)
+}
+
+// paginate returns the page of items, page starts from 1
+func paginate[T any](items []T, page, size int) []T {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 20
+	}
+	start := (page - 1) * size
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + size
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
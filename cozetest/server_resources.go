@@ -0,0 +1,929 @@
+package cozetest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// state is the data kept by Server, guarded by Server.mu
+type state struct {
+	bots          map[string]*botEntry
+	botOrder      []string
+	conversations map[string]*conversationEntry
+	convOrder     []string
+	chats         map[string]*chatEntry
+	datasets      map[string]*datasetEntry
+	datasetOrder  []string
+	files         map[string]*fileEntry
+
+	chatReplies     [][]StreamStep
+	workflowReplies [][]StreamStep
+	workflowResults []string
+}
+
+type botEntry struct {
+	spaceID   string
+	bot       coze.Bot
+	published bool
+}
+
+type conversationEntry struct {
+	botID        string
+	conversation coze.Conversation
+	messages     []*coze.Message
+}
+
+type datasetEntry struct {
+	dataset   coze.Dataset
+	documents []*coze.Document
+}
+
+type fileEntry struct {
+	info    coze.FileInfo
+	content []byte
+}
+
+func newState() state {
+	return state{
+		bots:          map[string]*botEntry{},
+		conversations: map[string]*conversationEntry{},
+		chats:         map[string]*chatEntry{},
+		datasets:      map[string]*datasetEntry{},
+		files:         map[string]*fileEntry{},
+	}
+}
+
+func (s *Server) registerResources() {
+	s.register(http.MethodPost, "/v1/bot/create", s.createBot)
+	s.register(http.MethodPost, "/v1/bot/update", s.updateBot)
+	s.register(http.MethodPost, "/v1/bot/publish", s.publishBot)
+	s.register(http.MethodGet, "/v1/space/published_bots_list", s.listBots)
+	s.register(http.MethodGet, "/v1/bot/get_online_info", s.retrieveBot)
+	s.register(http.MethodGet, "/v1/bots/:bot_id", s.retrieveBot)
+
+	s.register(http.MethodPost, "/v1/conversation/create", s.createConversation)
+	s.register(http.MethodGet, "/v1/conversation/retrieve", s.retrieveConversation)
+	s.register(http.MethodGet, "/v1/conversations", s.listConversations)
+	s.register(http.MethodPost, "/v1/conversations/:conversation_id/clear", s.clearConversation)
+	s.register(http.MethodPost, "/v1/conversation/message/create", s.createMessage)
+	s.register(http.MethodPost, "/v1/conversation/message/list", s.listMessages)
+	s.register(http.MethodGet, "/v1/conversation/message/retrieve", s.retrieveMessage)
+	s.register(http.MethodPost, "/v1/conversation/message/modify", s.modifyMessage)
+	s.register(http.MethodPost, "/v1/conversation/message/delete", s.deleteMessage)
+
+	s.register(http.MethodPost, "/v1/datasets", s.createDataset)
+	s.register(http.MethodGet, "/v1/datasets", s.listDatasets)
+	s.register(http.MethodPut, "/v1/datasets/:dataset_id", s.updateDataset)
+	s.register(http.MethodDelete, "/v1/datasets/:dataset_id", s.deleteDataset)
+	s.register(http.MethodPost, "/v1/datasets/:dataset_id/process", s.processDocuments)
+	s.register(http.MethodPost, "/open_api/knowledge/document/create", s.createDocuments)
+	s.register(http.MethodPost, "/open_api/knowledge/document/update", s.updateDocument)
+	s.register(http.MethodPost, "/open_api/knowledge/document/delete", s.deleteDocuments)
+	s.register(http.MethodPost, "/open_api/knowledge/document/list", s.listDocuments)
+
+	s.register(http.MethodPost, "/v1/files/upload", s.uploadFile)
+	s.register(http.MethodGet, "/v1/files/retrieve", s.retrieveFile)
+}
+
+// AddBot adds a published bot of the space, the bot id is generated if it's empty. It returns the
+// added bot.
+func (s *Server) AddBot(spaceID string, bot *coze.Bot) *coze.Bot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &botEntry{spaceID: spaceID, bot: *bot, published: true}
+	if entry.bot.BotID == "" {
+		entry.bot.BotID = s.newIDLocked()
+	}
+	s.addBotLocked(entry)
+	added := entry.bot
+	return &added
+}
+
+// Bot returns the bot, or nil if it doesn't exist.
+func (s *Server) Bot(botID string) *coze.Bot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.bots[botID]; ok {
+		bot := entry.bot
+		return &bot
+	}
+	return nil
+}
+
+func (s *Server) addBotLocked(entry *botEntry) {
+	if _, ok := s.bots[entry.bot.BotID]; !ok {
+		s.botOrder = append(s.botOrder, entry.bot.BotID)
+	}
+	s.bots[entry.bot.BotID] = entry
+}
+
+func (s *Server) createBot(c *call) {
+	req := &coze.CreateBotsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.SpaceID == "" || req.Name == "" {
+		c.fail(CodeInvalidParam, "space_id and name are required")
+		return
+	}
+	now := time.Now().Unix()
+	s.mu.Lock()
+	entry := &botEntry{spaceID: req.SpaceID, bot: coze.Bot{
+		BotID:          s.newIDLocked(),
+		Name:           req.Name,
+		Description:    req.Description,
+		CreateTime:     now,
+		UpdateTime:     now,
+		PromptInfo:     req.PromptInfo,
+		OnboardingInfo: req.OnboardingInfo,
+	}}
+	s.addBotLocked(entry)
+	s.mu.Unlock()
+	c.data(map[string]any{"bot_id": entry.bot.BotID})
+}
+
+func (s *Server) updateBot(c *call) {
+	req := &coze.UpdateBotsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.bots[req.BotID]
+	if !ok {
+		c.fail(CodeNotFound, fmt.Sprintf("bot %s not found", req.BotID))
+		return
+	}
+	if req.Name != "" {
+		entry.bot.Name = req.Name
+	}
+	if req.Description != "" {
+		entry.bot.Description = req.Description
+	}
+	if req.PromptInfo != nil {
+		entry.bot.PromptInfo = req.PromptInfo
+	}
+	if req.OnboardingInfo != nil {
+		entry.bot.OnboardingInfo = req.OnboardingInfo
+	}
+	if req.Knowledge != nil {
+		entry.bot.Knowledge = req.Knowledge
+	}
+	entry.bot.UpdateTime = time.Now().Unix()
+	c.data(map[string]any{})
+}
+
+func (s *Server) publishBot(c *call) {
+	req := &coze.PublishBotsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.bots[req.BotID]
+	if !ok {
+		c.fail(CodeNotFound, fmt.Sprintf("bot %s not found", req.BotID))
+		return
+	}
+	entry.published = true
+	entry.bot.Version = strconv.FormatInt(time.Now().UnixNano(), 10)
+	c.data(map[string]any{"bot_id": entry.bot.BotID, "version": entry.bot.Version})
+}
+
+func (s *Server) listBots(c *call) {
+	spaceID := c.query("space_id")
+	s.mu.Lock()
+	var bots []*coze.SimpleBot
+	for _, botID := range s.botOrder {
+		entry := s.bots[botID]
+		if entry.spaceID != spaceID || !entry.published {
+			continue
+		}
+		bots = append(bots, &coze.SimpleBot{
+			BotID:       entry.bot.BotID,
+			BotName:     entry.bot.Name,
+			Description: entry.bot.Description,
+			IconURL:     entry.bot.IconURL,
+			PublishTime: strconv.FormatInt(entry.bot.UpdateTime, 10),
+			FolderID:    entry.bot.FolderID,
+		})
+	}
+	s.mu.Unlock()
+	c.data(map[string]any{
+		"space_bots": paginate(bots, c.queryInt("page_index", 1), c.queryInt("page_size", 20)),
+		"total":      len(bots),
+	})
+}
+
+// retrieveBot serves both the v1 get_online_info and the v2 bots/:bot_id
+func (s *Server) retrieveBot(c *call) {
+	botID := c.params["bot_id"]
+	if botID == "" {
+		botID = c.query("bot_id")
+	}
+	bot := s.Bot(botID)
+	if bot == nil {
+		c.fail(CodeNotFound, fmt.Sprintf("bot %s not found", botID))
+		return
+	}
+	c.data(bot)
+}
+
+// AddConversation adds a conversation of the bot with the messages, it returns the added
+// conversation.
+func (s *Server) AddConversation(botID string, messages ...*coze.Message) *coze.Conversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.addConversationLocked(botID, nil)
+	for _, message := range messages {
+		s.addMessageLocked(entry, message, "")
+	}
+	conversation := entry.conversation
+	return &conversation
+}
+
+// Conversation returns the conversation, or nil if it doesn't exist.
+func (s *Server) Conversation(conversationID string) *coze.Conversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.conversations[conversationID]; ok {
+		conversation := entry.conversation
+		return &conversation
+	}
+	return nil
+}
+
+// Messages returns the messages of the conversation in the order they are created, including the
+// ones added by the chats.
+func (s *Server) Messages(conversationID string) []*coze.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.conversations[conversationID]
+	if !ok {
+		return nil
+	}
+	return copyMessages(entry.messages)
+}
+
+func (s *Server) addConversationLocked(botID string, metaData map[string]string) *conversationEntry {
+	entry := &conversationEntry{botID: botID, conversation: coze.Conversation{
+		ID:            s.newIDLocked(),
+		CreatedAt:     int(time.Now().Unix()),
+		MetaData:      metaData,
+		LastSectionID: s.newIDLocked(),
+	}}
+	s.conversations[entry.conversation.ID] = entry
+	s.convOrder = append(s.convOrder, entry.conversation.ID)
+	return entry
+}
+
+// addMessageLocked adds a copy of message to the conversation and returns it
+func (s *Server) addMessageLocked(entry *conversationEntry, message *coze.Message, chatID string) *coze.Message {
+	added := *message
+	if added.ID == "" {
+		added.ID = s.newIDLocked()
+	}
+	if added.Role == "" {
+		added.Role = coze.MessageRoleUser
+	}
+	if added.Type == "" {
+		if added.Role == coze.MessageRoleUser {
+			added.Type = coze.MessageTypeQuestion
+		} else {
+			added.Type = coze.MessageTypeAnswer
+		}
+	}
+	if added.ContentType == "" {
+		added.ContentType = coze.MessageContentTypeText
+	}
+	added.ConversationID = entry.conversation.ID
+	added.SectionID = entry.conversation.LastSectionID
+	added.BotID = entry.botID
+	if chatID != "" {
+		added.ChatID = chatID
+	}
+	now := time.Now().Unix()
+	added.CreatedAt, added.UpdatedAt = now, now
+	entry.messages = append(entry.messages, &added)
+	copied := added
+	return &copied
+}
+
+// conversation returns the conversation of the conversation_id query, it answers CodeNotFound
+// and returns nil if it doesn't exist
+func (s *Server) conversationLocked(c *call) *conversationEntry {
+	conversationID := c.params["conversation_id"]
+	if conversationID == "" {
+		conversationID = c.query("conversation_id")
+	}
+	entry, ok := s.conversations[conversationID]
+	if !ok {
+		c.fail(CodeNotFound, fmt.Sprintf("conversation %s not found", conversationID))
+		return nil
+	}
+	return entry
+}
+
+func (s *Server) createConversation(c *call) {
+	req := &coze.CreateConversationsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.addConversationLocked(req.BotID, req.MetaData)
+	for _, message := range req.Messages {
+		s.addMessageLocked(entry, message, "")
+	}
+	c.data(entry.conversation)
+}
+
+func (s *Server) retrieveConversation(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry := s.conversationLocked(c); entry != nil {
+		c.data(entry.conversation)
+	}
+}
+
+func (s *Server) listConversations(c *call) {
+	botID := c.query("bot_id")
+	s.mu.Lock()
+	var conversations []*coze.Conversation
+	// the latest first
+	for i := len(s.convOrder) - 1; i >= 0; i-- {
+		entry := s.conversations[s.convOrder[i]]
+		if entry.botID != botID {
+			continue
+		}
+		conversation := entry.conversation
+		conversations = append(conversations, &conversation)
+	}
+	s.mu.Unlock()
+	pageNum, pageSize := c.queryInt("page_num", 1), c.queryInt("page_size", 50)
+	page := paginate(conversations, pageNum, pageSize)
+	c.data(map[string]any{
+		"has_more":      pageNum*pageSize < len(conversations),
+		"conversations": page,
+	})
+}
+
+func (s *Server) clearConversation(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.conversationLocked(c)
+	if entry == nil {
+		return
+	}
+	entry.conversation.LastSectionID = s.newIDLocked()
+	c.data(map[string]any{"id": entry.conversation.LastSectionID, "conversation_id": entry.conversation.ID})
+}
+
+func (s *Server) createMessage(c *call) {
+	req := &coze.CreateMessageReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.conversationLocked(c)
+	if entry == nil {
+		return
+	}
+	c.data(s.addMessageLocked(entry, &coze.Message{
+		Role:        req.Role,
+		Content:     req.Content,
+		ContentType: req.ContentType,
+		MetaData:    req.MetaData,
+	}, ""))
+}
+
+func (s *Server) listMessages(c *call) {
+	req := &coze.ListConversationsMessagesReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	entry := s.conversationLocked(c)
+	if entry == nil {
+		s.mu.Unlock()
+		return
+	}
+	messages := copyMessages(entry.messages)
+	s.mu.Unlock()
+
+	if req.ChatID != nil && *req.ChatID != "" {
+		messages = filterMessages(messages, func(m *coze.Message) bool { return m.ChatID == *req.ChatID })
+	}
+	if req.Order == nil || *req.Order != "asc" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	// before_id and after_id are positions in the listed order, so after_id continues from the
+	// last_id of the previous page
+	if req.BeforeID != nil && *req.BeforeID != "" {
+		messages = messagesAround(messages, *req.BeforeID, true)
+	}
+	if req.AfterID != nil && *req.AfterID != "" {
+		messages = messagesAround(messages, *req.AfterID, false)
+	}
+	limit := 50
+	if req.Limit > 0 {
+		limit = req.Limit
+	}
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	firstID, lastID := "", ""
+	if len(messages) > 0 {
+		firstID, lastID = messages[0].ID, messages[len(messages)-1].ID
+	}
+	c.ok(map[string]any{"data": messages, "first_id": firstID, "last_id": lastID, "has_more": hasMore})
+}
+
+// message returns the message of the conversation_id and message_id query, it answers
+// CodeNotFound and returns -1 if it doesn't exist
+func (s *Server) messageLocked(c *call) (*conversationEntry, int) {
+	entry := s.conversationLocked(c)
+	if entry == nil {
+		return nil, -1
+	}
+	messageID := c.query("message_id")
+	for i, message := range entry.messages {
+		if message.ID == messageID {
+			return entry, i
+		}
+	}
+	c.fail(CodeNotFound, fmt.Sprintf("message %s not found", messageID))
+	return nil, -1
+}
+
+func (s *Server) retrieveMessage(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, i := s.messageLocked(c); entry != nil {
+		c.data(entry.messages[i])
+	}
+}
+
+func (s *Server) modifyMessage(c *call) {
+	req := &coze.UpdateConversationMessagesReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, i := s.messageLocked(c)
+	if entry == nil {
+		return
+	}
+	message := *entry.messages[i]
+	if req.Content != "" {
+		message.Content = req.Content
+	}
+	if req.ContentType != "" {
+		message.ContentType = req.ContentType
+	}
+	if req.MetaData != nil {
+		message.MetaData = req.MetaData
+	}
+	message.UpdatedAt = time.Now().Unix()
+	entry.messages[i] = &message
+	c.ok(map[string]any{"message": message})
+}
+
+func (s *Server) deleteMessage(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, i := s.messageLocked(c)
+	if entry == nil {
+		return
+	}
+	message := entry.messages[i]
+	entry.messages = append(entry.messages[:i:i], entry.messages[i+1:]...)
+	c.data(message)
+}
+
+func copyMessages(messages []*coze.Message) []*coze.Message {
+	copied := make([]*coze.Message, 0, len(messages))
+	for _, message := range messages {
+		m := *message
+		copied = append(copied, &m)
+	}
+	return copied
+}
+
+func filterMessages(messages []*coze.Message, keep func(m *coze.Message) bool) []*coze.Message {
+	filtered := make([]*coze.Message, 0, len(messages))
+	for _, message := range messages {
+		if keep(message) {
+			filtered = append(filtered, message)
+		}
+	}
+	return filtered
+}
+
+// messagesAround returns the messages before or after the message with id
+func messagesAround(messages []*coze.Message, id string, before bool) []*coze.Message {
+	for i, message := range messages {
+		if message.ID != id {
+			continue
+		}
+		if before {
+			return messages[:i]
+		}
+		return messages[i+1:]
+	}
+	return []*coze.Message{}
+}
+
+// AddDataset adds a dataset, the dataset id is generated if it's empty. It returns the added
+// dataset.
+func (s *Server) AddDataset(dataset *coze.Dataset) *coze.Dataset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &datasetEntry{dataset: *dataset}
+	if entry.dataset.ID == "" {
+		entry.dataset.ID = s.newIDLocked()
+	}
+	if _, ok := s.datasets[entry.dataset.ID]; !ok {
+		s.datasetOrder = append(s.datasetOrder, entry.dataset.ID)
+	}
+	s.datasets[entry.dataset.ID] = entry
+	added := entry.dataset
+	return &added
+}
+
+// Dataset returns the dataset, or nil if it doesn't exist.
+func (s *Server) Dataset(datasetID string) *coze.Dataset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.datasets[datasetID]; ok {
+		dataset := entry.datasetInfo()
+		return &dataset
+	}
+	return nil
+}
+
+// AddDocument adds a document to the dataset, the document id is generated if it's empty. It
+// returns the added document, or nil if the dataset doesn't exist.
+func (s *Server) AddDocument(datasetID string, document *coze.Document) *coze.Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.datasets[datasetID]
+	if !ok {
+		return nil
+	}
+	added := *document
+	if added.DocumentID == "" {
+		added.DocumentID = s.newIDLocked()
+	}
+	entry.documents = append(entry.documents, &added)
+	copied := added
+	return &copied
+}
+
+// Documents returns the documents of the dataset.
+func (s *Server) Documents(datasetID string) []*coze.Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.datasets[datasetID]
+	if !ok {
+		return nil
+	}
+	return copyDocuments(entry.documents)
+}
+
+// datasetInfo returns the dataset with the counts of the documents
+func (e *datasetEntry) datasetInfo() coze.Dataset {
+	dataset := e.dataset
+	dataset.DocCount = len(e.documents)
+	dataset.FileList = make([]string, 0, len(e.documents))
+	for _, document := range e.documents {
+		dataset.FileList = append(dataset.FileList, document.Name)
+	}
+	return dataset
+}
+
+// datasetLocked returns the dataset of the id, it answers CodeNotFound and returns nil if it
+// doesn't exist
+func (s *Server) datasetLocked(c *call, datasetID string) *datasetEntry {
+	entry, ok := s.datasets[datasetID]
+	if !ok {
+		c.fail(CodeNotFound, fmt.Sprintf("dataset %s not found", datasetID))
+		return nil
+	}
+	return entry
+}
+
+func (s *Server) createDataset(c *call) {
+	req := &coze.CreateDatasetsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.SpaceID == "" || req.Name == "" {
+		c.fail(CodeInvalidParam, "space_id and name are required")
+		return
+	}
+	now := int(time.Now().Unix())
+	dataset := s.AddDataset(&coze.Dataset{
+		Name:        req.Name,
+		Description: req.Description,
+		SpaceID:     req.SpaceID,
+		Status:      coze.DatasetStatusEnabled,
+		FormatType:  req.FormatType,
+		CanEdit:     true,
+		CreateTime:  now,
+		UpdateTime:  now,
+	})
+	c.data(map[string]any{"dataset_id": dataset.ID})
+}
+
+func (s *Server) listDatasets(c *call) {
+	spaceID, name := c.query("space_id"), c.query("name")
+	formatType, filterFormat := c.r.URL.Query()["format_type"]
+	s.mu.Lock()
+	var datasets []*coze.Dataset
+	for _, datasetID := range s.datasetOrder {
+		dataset := s.datasets[datasetID].datasetInfo()
+		if dataset.SpaceID != spaceID || !strings.Contains(dataset.Name, name) {
+			continue
+		}
+		if filterFormat && strconv.Itoa(int(dataset.FormatType)) != formatType[0] {
+			continue
+		}
+		datasets = append(datasets, &dataset)
+	}
+	s.mu.Unlock()
+	c.data(map[string]any{
+		"total_count":  len(datasets),
+		"dataset_list": paginate(datasets, c.queryInt("page_num", 1), c.queryInt("page_size", 10)),
+	})
+}
+
+func (s *Server) updateDataset(c *call) {
+	req := &coze.UpdateDatasetsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.datasetLocked(c, c.params["dataset_id"])
+	if entry == nil {
+		return
+	}
+	entry.dataset.Name = req.Name
+	if req.Description != "" {
+		entry.dataset.Description = req.Description
+	}
+	entry.dataset.UpdateTime = int(time.Now().Unix())
+	c.data(map[string]any{})
+}
+
+func (s *Server) deleteDataset(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	datasetID := c.params["dataset_id"]
+	if s.datasetLocked(c, datasetID) == nil {
+		return
+	}
+	delete(s.datasets, datasetID)
+	for i, id := range s.datasetOrder {
+		if id == datasetID {
+			s.datasetOrder = append(s.datasetOrder[:i:i], s.datasetOrder[i+1:]...)
+			break
+		}
+	}
+	c.data(map[string]any{})
+}
+
+func (s *Server) processDocuments(c *call) {
+	req := &coze.ProcessDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.datasetLocked(c, c.params["dataset_id"])
+	if entry == nil {
+		return
+	}
+	progresses := []*coze.DocumentProgress{}
+	for _, documentID := range req.DocumentIDs {
+		for _, document := range entry.documents {
+			if document.DocumentID != documentID {
+				continue
+			}
+			progress := 0
+			if document.Status == coze.DocumentStatusCompleted {
+				progress = 100
+			}
+			progresses = append(progresses, &coze.DocumentProgress{
+				DocumentID:     document.DocumentID,
+				Size:           document.Size,
+				Type:           document.Type,
+				Status:         document.Status,
+				Progress:       progress,
+				UpdateType:     document.UpdateType,
+				DocumentName:   document.Name,
+				UpdateInterval: document.UpdateInterval,
+			})
+		}
+	}
+	c.data(map[string]any{"data": progresses})
+}
+
+// createDocuments adds the documents completed, use AddDocument for the documents in other status
+func (s *Server) createDocuments(c *call) {
+	req := &coze.CreateDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.datasetLocked(c, strconv.FormatInt(req.DatasetID, 10))
+	if entry == nil {
+		return
+	}
+	now := int(time.Now().Unix())
+	documents := make([]*coze.Document, 0, len(req.DocumentBases))
+	for _, base := range req.DocumentBases {
+		document := &coze.Document{
+			DocumentID:    s.newIDLocked(),
+			ChunkStrategy: req.ChunkStrategy,
+			CreateTime:    now,
+			UpdateTime:    now,
+			FormatType:    req.FormatType,
+			Name:          base.Name,
+			Status:        coze.DocumentStatusCompleted,
+		}
+		if info := base.SourceInfo; info != nil {
+			if info.WebUrl != nil {
+				document.SourceType = coze.DocumentSourceTypeOnlineWeb
+				document.Type = "url"
+			}
+			if info.FileType != nil {
+				document.Type = *info.FileType
+			}
+			if info.FileBase64 != nil {
+				content, _ := base64.StdEncoding.DecodeString(*info.FileBase64)
+				document.Size = len(content)
+				document.CharCount = len([]rune(string(content)))
+			}
+		}
+		if rule := base.UpdateRule; rule != nil {
+			document.UpdateType = rule.UpdateType
+			document.UpdateInterval = rule.UpdateInterval
+		}
+		entry.documents = append(entry.documents, document)
+		documents = append(documents, document)
+	}
+	c.ok(map[string]any{"document_infos": copyDocuments(documents)})
+}
+
+// documentLocked returns the dataset and the index of the document, or nil and -1 if it doesn't
+// exist
+func (s *Server) documentLocked(documentID string) (*datasetEntry, int) {
+	for _, entry := range s.datasets {
+		for i, document := range entry.documents {
+			if document.DocumentID == documentID {
+				return entry, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+func (s *Server) updateDocument(c *call) {
+	req := &coze.UpdateDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documentID := strconv.FormatInt(req.DocumentID, 10)
+	entry, i := s.documentLocked(documentID)
+	if entry == nil {
+		c.fail(CodeNotFound, fmt.Sprintf("document %s not found", documentID))
+		return
+	}
+	document := *entry.documents[i]
+	if req.DocumentName != "" {
+		document.Name = req.DocumentName
+	}
+	if rule := req.UpdateRule; rule != nil {
+		document.UpdateType = rule.UpdateType
+		document.UpdateInterval = rule.UpdateInterval
+	}
+	document.UpdateTime = int(time.Now().Unix())
+	entry.documents[i] = &document
+	c.data(map[string]any{})
+}
+
+func (s *Server) deleteDocuments(c *call) {
+	req := &coze.DeleteDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range req.DocumentIDs {
+		if entry, i := s.documentLocked(strconv.FormatInt(id, 10)); entry != nil {
+			entry.documents = append(entry.documents[:i:i], entry.documents[i+1:]...)
+		}
+	}
+	c.data(map[string]any{})
+}
+
+func (s *Server) listDocuments(c *call) {
+	req := &coze.ListDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	entry := s.datasetLocked(c, strconv.FormatInt(req.DatasetID, 10))
+	if entry == nil {
+		s.mu.Unlock()
+		return
+	}
+	documents := copyDocuments(entry.documents)
+	s.mu.Unlock()
+	size := req.Size
+	if size == 0 {
+		size = 10
+	}
+	c.ok(map[string]any{"total": len(documents), "document_infos": paginate(documents, req.Page, size)})
+}
+
+func copyDocuments(documents []*coze.Document) []*coze.Document {
+	copied := make([]*coze.Document, 0, len(documents))
+	for _, document := range documents {
+		d := *document
+		copied = append(copied, &d)
+	}
+	return copied
+}
+
+// AddFile adds a file with the content, it returns the info of the added file.
+func (s *Server) AddFile(fileName string, content []byte) *coze.FileInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &fileEntry{
+		info: coze.FileInfo{
+			ID:        s.newIDLocked(),
+			Bytes:     len(content),
+			CreatedAt: int(time.Now().Unix()),
+			FileName:  fileName,
+		},
+		content: append([]byte{}, content...),
+	}
+	s.files[entry.info.ID] = entry
+	info := entry.info
+	return &info
+}
+
+// File returns the info and the content of the file, or nil if it doesn't exist.
+func (s *Server) File(fileID string) (*coze.FileInfo, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.files[fileID]
+	if !ok {
+		return nil, nil
+	}
+	info := entry.info
+	return &info, append([]byte{}, entry.content...)
+}
+
+func (s *Server) uploadFile(c *call) {
+	file, header, err := c.r.FormFile("file")
+	if err != nil {
+		c.fail(CodeInvalidParam, fmt.Sprintf("invalid file: %s", err))
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.fail(CodeInvalidParam, fmt.Sprintf("invalid file: %s", err))
+		return
+	}
+	c.data(s.AddFile(header.Filename, content))
+}
+
+func (s *Server) retrieveFile(c *call) {
+	fileID := c.query("file_id")
+	info, _ := s.File(fileID)
+	if info == nil {
+		c.fail(CodeNotFound, fmt.Sprintf("file %s not found", fileID))
+		return
+	}
+	c.data(info)
+}
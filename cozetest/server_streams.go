@@ -0,0 +1,564 @@
+package cozetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// errStreamAborted is returned by the steps dropping the connection
+var errStreamAborted = errors.New("stream aborted")
+
+// StreamStep is a step of a scripted chat or workflow reply, it sends events or controls the
+// stream.
+type StreamStep func(w *StreamWriter) error
+
+type chatEntry struct {
+	chat   coze.Chat
+	cancel context.CancelFunc
+}
+
+// ChatReply queues the reply of a chat, the chats created by /v3/chat and continued by
+// /v3/chat/submit_tool_outputs use the queued replies in order, and reply "hello" once the queue
+// is empty. The reply is wrapped by conversation.chat.in_progress and conversation.chat.completed,
+// and conversation.chat.created for a new chat. The streamed chats send the events, the other
+// chats run the reply after the response, so the chat is completed once polled.
+func (s *Server) ChatReply(steps ...StreamStep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chatReplies = append(s.chatReplies, steps)
+}
+
+// WorkflowReply queues the reply of /v1/workflow/stream_run and /v1/workflow/stream_resume, the
+// streams use the queued replies in order, and reply a message "hello" once the queue is empty.
+// The stream ends with the Done event unless a step ends it.
+func (s *Server) WorkflowReply(steps ...StreamStep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workflowReplies = append(s.workflowReplies, steps)
+}
+
+// WorkflowResult queues the result of /v1/workflow/run, the runs use the queued results in order,
+// and return {"output":"hello"} once the queue is empty.
+func (s *Server) WorkflowResult(data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workflowResults = append(s.workflowResults, data)
+}
+
+// Chat returns the chat, or nil if it doesn't exist.
+func (s *Server) Chat(chatID string) *coze.Chat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.chats[chatID]; ok {
+		chat := entry.chat
+		return &chat
+	}
+	return nil
+}
+
+func (s *Server) registerStreams() {
+	s.register(http.MethodPost, "/v3/chat", s.createChat)
+	s.register(http.MethodGet, "/v3/chat/retrieve", s.retrieveChat)
+	s.register(http.MethodPost, "/v3/chat/cancel", s.cancelChat)
+	s.register(http.MethodPost, "/v3/chat/submit_tool_outputs", s.submitToolOutputs)
+	s.register(http.MethodGet, "/v3/chat/message/list", s.listChatMessages)
+
+	s.register(http.MethodPost, "/v1/workflow/run", s.runWorkflow)
+	s.register(http.MethodPost, "/v1/workflow/stream_run", s.streamWorkflow)
+	s.register(http.MethodPost, "/v1/workflow/stream_resume", s.streamWorkflow)
+}
+
+// StreamWriter writes the events of a scripted reply.
+type StreamWriter struct {
+	server *Server
+	ctx    context.Context
+	w      http.ResponseWriter // nil if the reply is not streamed
+	chat   *chatEntry          // nil for the workflows
+	seq    int
+}
+
+// Context is done when the client disconnects, the chat is canceled or the server is closed.
+func (w *StreamWriter) Context() context.Context {
+	return w.ctx
+}
+
+// Chat returns the chat of the reply, or nil for the workflows.
+func (w *StreamWriter) Chat() *coze.Chat {
+	if w.chat == nil {
+		return nil
+	}
+	w.server.mu.Lock()
+	defer w.server.mu.Unlock()
+	chat := w.chat.chat
+	return &chat
+}
+
+// Send sends an event, data is sent as it is if it's a string, or encoded as json. Nothing is sent
+// if the reply is not streamed.
+func (w *StreamWriter) Send(event string, data any) error {
+	if w.ctx.Err() != nil {
+		return errTurnEnded
+	}
+	if w.w == nil {
+		return nil
+	}
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case json.RawMessage:
+		payload = string(v)
+	default:
+		bs, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		payload = string(bs)
+	}
+	var b strings.Builder
+	if w.chat == nil {
+		fmt.Fprintf(&b, "id: %d\n", w.seq)
+	}
+	w.seq++
+	fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", event, payload)
+	if _, err := w.w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	if flusher, ok := w.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// sendChat updates the status of the chat and sends it
+func (w *StreamWriter) sendChat(event coze.ChatEventType, status coze.ChatStatus, update func(chat *coze.Chat)) error {
+	if w.ctx.Err() != nil {
+		return errTurnEnded
+	}
+	w.server.mu.Lock()
+	w.chat.chat.Status = status
+	if update != nil {
+		update(&w.chat.chat)
+	}
+	chat := w.chat.chat
+	w.server.mu.Unlock()
+	return w.Send(string(event), &chat)
+}
+
+// run runs the steps between start and end, it returns nil if a step ends the stream
+func (w *StreamWriter) run(steps []StreamStep, start, end func() error) error {
+	if start != nil {
+		if err := start(); err != nil {
+			return ignoreTurnEnded(err)
+		}
+	}
+	for _, step := range steps {
+		if err := step(w); err != nil {
+			return ignoreTurnEnded(err)
+		}
+	}
+	return ignoreTurnEnded(end())
+}
+
+func ignoreTurnEnded(err error) error {
+	if errors.Is(err, errTurnEnded) {
+		return nil
+	}
+	return err
+}
+
+// startStream sends the headers of the stream and returns the context ending with the request or
+// the server
+func (s *Server) startStream(c *call) (context.Context, context.CancelFunc) {
+	c.w.Header().Set("Content-Type", "text/event-stream")
+	c.w.Header().Set("Cache-Control", "no-cache")
+	c.w.WriteHeader(http.StatusOK)
+	ctx, cancel := context.WithCancel(c.r.Context())
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// dequeueLocked returns the next queued reply, or defaults
+func dequeueLocked(replies *[][]StreamStep, defaults ...StreamStep) []StreamStep {
+	if len(*replies) == 0 {
+		return defaults
+	}
+	steps := (*replies)[0]
+	*replies = (*replies)[1:]
+	return steps
+}
+
+func (s *Server) createChat(c *call) {
+	req := &coze.CreateChatsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.BotID == "" {
+		c.fail(CodeInvalidParam, "bot_id is required")
+		return
+	}
+	s.mu.Lock()
+	var conversation *conversationEntry
+	if c.query("conversation_id") != "" {
+		if conversation = s.conversationLocked(c); conversation == nil {
+			s.mu.Unlock()
+			return
+		}
+	} else {
+		conversation = s.addConversationLocked(req.BotID, nil)
+	}
+	entry := &chatEntry{chat: coze.Chat{
+		ID:             s.newIDLocked(),
+		ConversationID: conversation.conversation.ID,
+		BotID:          req.BotID,
+		CreatedAt:      int(time.Now().Unix()),
+		MetaData:       req.MetaData,
+		Status:         coze.ChatStatusCreated,
+	}}
+	for _, message := range req.Messages {
+		s.addMessageLocked(conversation, message, entry.chat.ID)
+	}
+	s.chats[entry.chat.ID] = entry
+	steps := dequeueLocked(&s.chatReplies, StreamMessage("hello"))
+	s.mu.Unlock()
+	s.runChat(c, entry, steps, req.Stream != nil && *req.Stream, true)
+}
+
+// runChat streams the reply of the chat, or answers the chat and runs the reply after
+func (s *Server) runChat(c *call, entry *chatEntry, steps []StreamStep, stream, created bool) {
+	w := &StreamWriter{server: s, chat: entry}
+	start := func() error {
+		if created {
+			if err := w.sendChat(coze.ChatEventConversationChatCreated, coze.ChatStatusCreated, nil); err != nil {
+				return err
+			}
+		}
+		return w.sendChat(coze.ChatEventConversationChatInProgress, coze.ChatStatusInProgress, nil)
+	}
+	end := func() error {
+		if err := w.sendChat(coze.ChatEventConversationChatCompleted, coze.ChatStatusCompleted, func(chat *coze.Chat) {
+			chat.CompletedAt = int(time.Now().Unix())
+		}); err != nil {
+			return err
+		}
+		return w.Send(string(coze.ChatEventDone), "[DONE]")
+	}
+
+	if stream {
+		ctx, cancel := s.startStream(c)
+		defer cancel()
+		s.mu.Lock()
+		entry.cancel = cancel
+		s.mu.Unlock()
+		w.ctx, w.w = ctx, c.w
+		if err := w.run(steps, start, end); errors.Is(err, errStreamAborted) {
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	entry.cancel = cancel
+	entry.chat.Status = coze.ChatStatusInProgress
+	chat := entry.chat
+	s.mu.Unlock()
+	w.ctx = ctx
+	c.data(&chat)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		_ = w.run(steps, start, end)
+	}()
+}
+
+// chatLocked returns the chat of the conversation_id and chat_id, it answers CodeNotFound and
+// returns nil if it doesn't exist
+func (s *Server) chatLocked(c *call, conversationID, chatID string) *chatEntry {
+	entry, ok := s.chats[chatID]
+	if !ok || entry.chat.ConversationID != conversationID {
+		c.fail(CodeNotFound, fmt.Sprintf("chat %s of conversation %s not found", chatID, conversationID))
+		return nil
+	}
+	return entry
+}
+
+func (s *Server) retrieveChat(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry := s.chatLocked(c, c.query("conversation_id"), c.query("chat_id")); entry != nil {
+		c.data(entry.chat)
+	}
+}
+
+func (s *Server) cancelChat(c *call) {
+	req := &coze.CancelChatsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.chatLocked(c, req.ConversationID, req.ChatID)
+	if entry == nil {
+		return
+	}
+	switch entry.chat.Status {
+	case coze.ChatStatusCompleted, coze.ChatStatusFailed, coze.ChatStatusCancelled:
+		c.fail(CodeInvalidParam, fmt.Sprintf("chat %s is %s", req.ChatID, entry.chat.Status))
+		return
+	}
+	if entry.cancel != nil {
+		entry.cancel()
+	}
+	entry.chat.Status = coze.ChatStatusCancelled
+	c.data(entry.chat)
+}
+
+func (s *Server) submitToolOutputs(c *call) {
+	req := &coze.SubmitToolOutputsChatReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	entry := s.chatLocked(c, c.query("conversation_id"), c.query("chat_id"))
+	if entry == nil {
+		s.mu.Unlock()
+		return
+	}
+	if entry.chat.Status != coze.ChatStatusRequiresAction {
+		s.mu.Unlock()
+		c.fail(CodeInvalidParam, fmt.Sprintf("chat %s is %s", entry.chat.ID, entry.chat.Status))
+		return
+	}
+	entry.chat.RequiredAction = nil
+	steps := dequeueLocked(&s.chatReplies, StreamMessage("hello"))
+	s.mu.Unlock()
+	s.runChat(c, entry, steps, req.Stream != nil && *req.Stream, false)
+}
+
+func (s *Server) listChatMessages(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.chatLocked(c, c.query("conversation_id"), c.query("chat_id"))
+	if entry == nil {
+		return
+	}
+	conversation := s.conversations[entry.chat.ConversationID]
+	c.data(filterMessages(copyMessages(conversation.messages), func(m *coze.Message) bool {
+		return m.ChatID == entry.chat.ID && m.Type != coze.MessageTypeQuestion
+	}))
+}
+
+func (s *Server) runWorkflow(c *call) {
+	req := &coze.RunWorkflowsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.WorkflowID == "" {
+		c.fail(CodeInvalidParam, "workflow_id is required")
+		return
+	}
+	s.mu.Lock()
+	executeID := s.newIDLocked()
+	data := `{"output":"hello"}`
+	if len(s.workflowResults) > 0 {
+		data, s.workflowResults = s.workflowResults[0], s.workflowResults[1:]
+	}
+	s.mu.Unlock()
+	if req.IsAsync {
+		c.ok(map[string]any{"execute_id": executeID, "debug_url": s.debugURL(executeID)})
+		return
+	}
+	c.ok(map[string]any{"data": data, "execute_id": executeID, "debug_url": s.debugURL(executeID)})
+}
+
+func (s *Server) streamWorkflow(c *call) {
+	req := &struct {
+		WorkflowID string `json:"workflow_id"`
+	}{}
+	if !c.decode(req) {
+		return
+	}
+	if req.WorkflowID == "" {
+		c.fail(CodeInvalidParam, "workflow_id is required")
+		return
+	}
+	s.mu.Lock()
+	executeID := s.newIDLocked()
+	steps := dequeueLocked(&s.workflowReplies, WorkflowMessage("End", "hello"))
+	s.mu.Unlock()
+
+	ctx, cancel := s.startStream(c)
+	defer cancel()
+	w := &StreamWriter{server: s, ctx: ctx, w: c.w}
+	end := func() error {
+		return w.Send(string(coze.WorkflowEventTypeDone), &coze.WorkflowEventDebugURL{URL: s.debugURL(executeID)})
+	}
+	if err := w.run(steps, nil, end); errors.Is(err, errStreamAborted) {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (s *Server) debugURL(executeID string) string {
+	return fmt.Sprintf("%s/work_flow?execute_id=%s", s.URL, executeID)
+}
+
+// StreamEvent sends an event, data is sent as it is if it's a string, or encoded as json.
+func StreamEvent(event string, data any) StreamStep {
+	return func(w *StreamWriter) error {
+		return w.Send(event, data)
+	}
+}
+
+// StreamMessage sends a conversation.message.delta of each content, then the
+// conversation.message.completed of the whole content, and adds the message to the conversation.
+func StreamMessage(contents ...string) StreamStep {
+	return func(w *StreamWriter) error {
+		if w.chat == nil {
+			return errors.New("StreamMessage is a step of the chat replies")
+		}
+		s := w.server
+		s.mu.Lock()
+		conversation := s.conversations[w.chat.chat.ConversationID]
+		message := s.addMessageLocked(conversation, &coze.Message{
+			Role:    coze.MessageRoleAssistant,
+			Type:    coze.MessageTypeAnswer,
+			Content: strings.Join(contents, ""),
+		}, w.chat.chat.ID)
+		s.mu.Unlock()
+		for _, content := range contents {
+			delta := *message
+			delta.Content = content
+			if err := w.Send(string(coze.ChatEventConversationMessageDelta), &delta); err != nil {
+				return err
+			}
+		}
+		return w.Send(string(coze.ChatEventConversationMessageCompleted), message)
+	}
+}
+
+// StreamRequiresAction sends conversation.chat.requires_action with the tool calls and ends the
+// stream, /v3/chat/submit_tool_outputs continues the chat with the next queued reply.
+func StreamRequiresAction(toolCalls ...*coze.ChatToolCall) StreamStep {
+	return func(w *StreamWriter) error {
+		if w.chat == nil {
+			return errors.New("StreamRequiresAction is a step of the chat replies")
+		}
+		err := w.sendChat(coze.ChatEventConversationChatRequiresAction, coze.ChatStatusRequiresAction, func(chat *coze.Chat) {
+			chat.RequiredAction = &coze.ChatRequiredAction{
+				Type:              "submit_tool_outputs",
+				SubmitToolOutputs: &coze.ChatSubmitToolOutputs{ToolCalls: toolCalls},
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if err := w.Send(string(coze.ChatEventDone), "[DONE]"); err != nil {
+			return err
+		}
+		return errTurnEnded
+	}
+}
+
+// StreamChatFailed sends conversation.chat.failed with the error and ends the stream.
+func StreamChatFailed(code int, msg string) StreamStep {
+	return func(w *StreamWriter) error {
+		if w.chat == nil {
+			return errors.New("StreamChatFailed is a step of the chat replies")
+		}
+		err := w.sendChat(coze.ChatEventConversationChatFailed, coze.ChatStatusFailed, func(chat *coze.Chat) {
+			chat.FailedAt = int(time.Now().Unix())
+			chat.LastError = &coze.ChatError{Code: code, Msg: msg}
+		})
+		if err != nil {
+			return err
+		}
+		if err := w.Send(string(coze.ChatEventDone), "[DONE]"); err != nil {
+			return err
+		}
+		return errTurnEnded
+	}
+}
+
+// StreamError sends an error event and ends the stream, the chat fails with the error.
+func StreamError(code int, msg string) StreamStep {
+	return func(w *StreamWriter) error {
+		if w.chat != nil {
+			w.server.mu.Lock()
+			w.chat.chat.Status = coze.ChatStatusFailed
+			w.chat.chat.FailedAt = int(time.Now().Unix())
+			w.chat.chat.LastError = &coze.ChatError{Code: code, Msg: msg}
+			w.server.mu.Unlock()
+		}
+		if err := w.Send(string(coze.ChatEventError), map[string]any{"code": code, "msg": msg}); err != nil {
+			return err
+		}
+		return errTurnEnded
+	}
+}
+
+// StreamSleep pauses the reply.
+func StreamSleep(d time.Duration) StreamStep {
+	return func(w *StreamWriter) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-w.ctx.Done():
+			return errTurnEnded
+		}
+	}
+}
+
+// StreamDisconnect drops the connection in the middle of the stream, like a network failure.
+func StreamDisconnect() StreamStep {
+	return func(w *StreamWriter) error {
+		return errStreamAborted
+	}
+}
+
+// WorkflowMessage sends a Message event of the node.
+func WorkflowMessage(nodeTitle, content string) StreamStep {
+	return func(w *StreamWriter) error {
+		return w.Send(string(coze.WorkflowEventTypeMessage), &coze.WorkflowEventMessage{
+			Content:      content,
+			NodeTitle:    nodeTitle,
+			NodeSeqID:    "0",
+			NodeIsFinish: true,
+		})
+	}
+}
+
+// WorkflowInterrupt sends an Interrupt event of the node, resume it with
+// /v1/workflow/stream_resume and the event id.
+func WorkflowInterrupt(nodeTitle, eventID string, interruptType int) StreamStep {
+	return func(w *StreamWriter) error {
+		return w.Send(string(coze.WorkflowEventTypeInterrupt), &coze.WorkflowEventInterrupt{
+			InterruptData: &coze.WorkflowEventInterruptData{EventID: eventID, Type: interruptType},
+			NodeTitle:     nodeTitle,
+		})
+	}
+}
+
+// WorkflowError sends an Error event and ends the stream.
+func WorkflowError(code int, msg string) StreamStep {
+	return func(w *StreamWriter) error {
+		if err := w.Send(string(coze.WorkflowEventTypeError), &coze.WorkflowEventError{ErrorCode: code, ErrorMessage: msg}); err != nil {
+			return err
+		}
+		return errTurnEnded
+	}
+}
@@ -0,0 +1,409 @@
+package cozetest_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coze-dev/coze-go"
+	"github.com/coze-dev/coze-go/cozetest"
+)
+
+func newServerAPI(server *cozetest.Server) coze.CozeAPI {
+	return coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+}
+
+func TestServer(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("bots", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+
+		created, err := api.Bots.Create(ctx, &coze.CreateBotsReq{SpaceID: "space", Name: "bot"})
+		as.Nil(err)
+		as.NotEmpty(created.BotID)
+		_, err = api.Bots.Update(ctx, &coze.UpdateBotsReq{BotID: created.BotID, Description: "desc"})
+		as.Nil(err)
+		published, err := api.Bots.Publish(ctx, &coze.PublishBotsReq{BotID: created.BotID, ConnectorIDs: []string{"1024"}})
+		as.Nil(err)
+		as.NotEmpty(published.BotVersion)
+
+		bot, err := api.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: created.BotID})
+		as.Nil(err)
+		as.Equal("bot", bot.Name)
+		as.Equal("desc", bot.Description)
+
+		server.AddBot("space", &coze.Bot{Name: "seeded"})
+		server.AddBot("other", &coze.Bot{Name: "other"})
+		paged, err := api.Bots.List(ctx, &coze.ListBotsReq{SpaceID: "space", PageSize: 1})
+		as.Nil(err)
+		var names []string
+		for paged.Next() {
+			names = append(names, paged.Current().BotName)
+		}
+		as.Nil(paged.Err())
+		as.Equal([]string{"bot", "seeded"}, names)
+
+		_, err = api.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: "missing"})
+		cozeErr, ok := coze.AsCozeError(err)
+		as.True(ok)
+		as.Equal(cozetest.CodeNotFound, cozeErr.Code)
+	})
+
+	t.Run("conversations and messages", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+
+		conversation, err := api.Conversations.Create(ctx, &coze.CreateConversationsReq{
+			BotID:    "bot",
+			Messages: []*coze.Message{coze.BuildUserQuestionText("first", nil)},
+		})
+		as.Nil(err)
+		retrieved, err := api.Conversations.Retrieve(ctx, &coze.RetrieveConversationsReq{ConversationID: conversation.ID})
+		as.Nil(err)
+		as.Equal(conversation.ID, retrieved.ID)
+
+		for _, content := range []string{"second", "third"} {
+			_, err := api.Conversations.Messages.Create(ctx, &coze.CreateMessageReq{
+				ConversationID: conversation.ID,
+				Role:           coze.MessageRoleUser,
+				Content:        content,
+				ContentType:    coze.MessageContentTypeText,
+			})
+			as.Nil(err)
+		}
+		paged, err := api.Conversations.Messages.List(ctx, &coze.ListConversationsMessagesReq{ConversationID: conversation.ID, Limit: 2})
+		as.Nil(err)
+		var contents []string
+		for paged.Next() {
+			contents = append(contents, paged.Current().Content)
+		}
+		as.Nil(paged.Err())
+		as.Equal([]string{"third", "second", "first"}, contents)
+
+		messages := server.Messages(conversation.ID)
+		as.Len(messages, 3)
+		_, err = api.Conversations.Messages.Update(ctx, &coze.UpdateConversationMessagesReq{
+			ConversationID: conversation.ID,
+			MessageID:      messages[0].ID,
+			Content:        "updated",
+			ContentType:    coze.MessageContentTypeText,
+		})
+		as.Nil(err)
+		_, err = api.Conversations.Messages.Delete(ctx, &coze.DeleteConversationsMessagesReq{ConversationID: conversation.ID, MessageID: messages[1].ID})
+		as.Nil(err)
+		messages = server.Messages(conversation.ID)
+		as.Len(messages, 2)
+		as.Equal("updated", messages[0].Content)
+
+		_, err = api.Conversations.Retrieve(ctx, &coze.RetrieveConversationsReq{ConversationID: "missing"})
+		as.NotNil(err)
+	})
+
+	t.Run("chat stream", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+		server.ChatReply(
+			cozetest.StreamMessage("It's ", "sunny"),
+			cozetest.StreamRequiresAction(&coze.ChatToolCall{ID: "call", Type: "function", Function: &coze.ChatToolCallFunction{Name: "weather"}}),
+		)
+		server.ChatReply(cozetest.StreamMessage("Done"))
+
+		stream, err := api.Chat.Stream(ctx, &coze.CreateChatsReq{
+			BotID:    "bot",
+			UserID:   "user",
+			Messages: []*coze.Message{coze.BuildUserQuestionText("weather?", nil)},
+		})
+		as.Nil(err)
+		var events []coze.ChatEventType
+		var content string
+		var chat *coze.Chat
+		for {
+			event, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			as.Nil(err)
+			events = append(events, event.Event)
+			if event.Event == coze.ChatEventConversationMessageDelta {
+				content += event.Message.Content
+			}
+			if event.Chat != nil {
+				chat = event.Chat
+			}
+		}
+		as.Equal("It's sunny", content)
+		as.Equal([]coze.ChatEventType{
+			coze.ChatEventConversationChatCreated,
+			coze.ChatEventConversationChatInProgress,
+			coze.ChatEventConversationMessageDelta,
+			coze.ChatEventConversationMessageDelta,
+			coze.ChatEventConversationMessageCompleted,
+			coze.ChatEventConversationChatRequiresAction,
+			coze.ChatEventDone,
+		}, events)
+		as.Equal(coze.ChatStatusRequiresAction, chat.Status)
+
+		stream, err = api.Chat.StreamSubmitToolOutputs(ctx, &coze.SubmitToolOutputsChatReq{
+			ConversationID: chat.ConversationID,
+			ChatID:         chat.ID,
+			ToolOutputs:    []*coze.ToolOutput{{ToolCallID: "call", Output: "sunny"}},
+		})
+		as.Nil(err)
+		content = ""
+		for {
+			event, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			as.Nil(err)
+			if event.Event == coze.ChatEventConversationMessageCompleted {
+				content = event.Message.Content
+			}
+		}
+		as.Equal("Done", content)
+		as.Equal(coze.ChatStatusCompleted, server.Chat(chat.ID).Status)
+
+		messages := server.Messages(chat.ConversationID)
+		as.Len(messages, 3)
+		as.Equal("weather?", messages[0].Content)
+		as.Equal(chat.ID, messages[0].ChatID)
+
+		request := server.AssertRequested(t, http.MethodPost, "/v3/chat/submit_tool_outputs")
+		req := &coze.SubmitToolOutputsChatReq{}
+		as.Nil(request.DecodeBody(req))
+		as.Equal("sunny", req.ToolOutputs[0].Output)
+	})
+
+	t.Run("chat poll", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+		server.ChatReply(cozetest.StreamMessage("polled"))
+
+		conversation := server.AddConversation("bot")
+		poll, err := api.Chat.CreateAndPoll(ctx, &coze.CreateChatsReq{
+			ConversationID: conversation.ID,
+			BotID:          "bot",
+			UserID:         "user",
+			Messages:       []*coze.Message{coze.BuildUserQuestionText("hi", nil)},
+		}, nil)
+		as.Nil(err)
+		as.Equal(coze.ChatStatusCompleted, poll.Chat.Status)
+		as.Len(poll.Messages, 1)
+		as.Equal("polled", poll.Messages[0].Content)
+		server.AssertRequested(t, http.MethodGet, "/v3/chat/retrieve")
+	})
+
+	t.Run("chat errors", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+		server.ChatReply(cozetest.StreamMessage("partial"), cozetest.StreamError(5000, "model error"))
+		server.ChatReply(cozetest.StreamMessage("partial"), cozetest.StreamDisconnect())
+
+		stream, err := api.Chat.Stream(ctx, &coze.CreateChatsReq{BotID: "bot", UserID: "user"})
+		as.Nil(err)
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		as.Contains(err.Error(), "model error")
+
+		stream, err = api.Chat.Stream(ctx, &coze.CreateChatsReq{BotID: "bot", UserID: "user"})
+		as.Nil(err)
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		as.NotErrorIs(err, io.EOF)
+	})
+
+	t.Run("datasets and documents", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+
+		created, err := api.Datasets.Create(ctx, &coze.CreateDatasetsReq{Name: "docs", SpaceID: "space", FormatType: coze.DocumentFormatTypeDocument})
+		as.Nil(err)
+		datasetID := created.DatasetID
+		documents, err := api.Datasets.Documents.Create(ctx, &coze.CreateDatasetsDocumentsReq{
+			DatasetID: mustParseInt(t, datasetID),
+			DocumentBases: []*coze.DocumentBase{{
+				Name: "readme.txt",
+				SourceInfo: &coze.DocumentSourceInfo{
+					FileBase64: ptr(base64.StdEncoding.EncodeToString([]byte("hello"))),
+					FileType:   ptr("txt"),
+				},
+			}},
+		})
+		as.Nil(err)
+		as.Len(documents.DocumentInfos, 1)
+		as.Equal(5, documents.DocumentInfos[0].Size)
+
+		progress, err := api.Datasets.Process(ctx, &coze.ProcessDocumentsReq{DatasetID: datasetID, DocumentIDs: []string{documents.DocumentInfos[0].DocumentID}})
+		as.Nil(err)
+		as.Equal(100, progress.Data[0].Progress)
+
+		paged, err := api.Datasets.List(ctx, coze.NewListDatasetsReq("space"))
+		as.Nil(err)
+		as.Equal(1, paged.Total())
+		as.Equal(1, paged.Items()[0].DocCount)
+
+		docs, err := api.Datasets.Documents.List(ctx, &coze.ListDatasetsDocumentsReq{DatasetID: mustParseInt(t, datasetID)})
+		as.Nil(err)
+		as.Equal(1, docs.Total())
+		as.Equal("readme.txt", docs.Items()[0].Name)
+
+		_, err = api.Datasets.Documents.Delete(ctx, &coze.DeleteDatasetsDocumentsReq{DocumentIDs: []int64{mustParseInt(t, documents.DocumentInfos[0].DocumentID)}})
+		as.Nil(err)
+		as.Empty(server.Documents(datasetID))
+		_, err = api.Datasets.Delete(ctx, &coze.DeleteDatasetsReq{DatasetID: datasetID})
+		as.Nil(err)
+		as.Nil(server.Dataset(datasetID))
+	})
+
+	t.Run("files", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+
+		uploaded, err := api.Files.Upload(ctx, &coze.UploadFilesReq{File: coze.NewUploadFile(strings.NewReader("content"), "a.txt")})
+		as.Nil(err)
+		as.Equal("a.txt", uploaded.FileName)
+		as.Equal(7, uploaded.Bytes)
+		_, content := server.File(uploaded.ID)
+		as.Equal("content", string(content))
+
+		retrieved, err := api.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: uploaded.ID})
+		as.Nil(err)
+		as.Equal(uploaded.ID, retrieved.ID)
+	})
+
+	t.Run("workflows", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+		server.WorkflowResult(`{"output":"ran"}`)
+		server.WorkflowReply(
+			cozetest.WorkflowMessage("llm", "part"),
+			cozetest.WorkflowInterrupt("question", "event", 2),
+		)
+		server.WorkflowReply(cozetest.WorkflowError(6000, "node failed"))
+
+		run, err := api.Workflows.Runs.Create(ctx, &coze.RunWorkflowsReq{WorkflowID: "workflow"})
+		as.Nil(err)
+		as.Equal(`{"output":"ran"}`, run.Data)
+
+		stream, err := api.Workflows.Runs.Stream(ctx, &coze.RunWorkflowsReq{WorkflowID: "workflow"})
+		as.Nil(err)
+		var events []coze.WorkflowEventType
+		for {
+			event, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			as.Nil(err)
+			events = append(events, event.Event)
+			if event.Event == coze.WorkflowEventTypeInterrupt {
+				as.Equal("event", event.Interrupt.InterruptData.EventID)
+			}
+		}
+		as.Equal([]coze.WorkflowEventType{coze.WorkflowEventTypeMessage, coze.WorkflowEventTypeInterrupt, coze.WorkflowEventTypeDone}, events)
+
+		stream, err = api.Workflows.Runs.Resume(ctx, &coze.ResumeRunWorkflowsReq{WorkflowID: "workflow", EventID: "event", ResumeData: "yes", InterruptType: 2})
+		as.Nil(err)
+		event, err := stream.Recv()
+		as.Nil(err)
+		as.Equal(coze.WorkflowEventTypeError, event.Event)
+		as.Equal(6000, event.Error.ErrorCode)
+		_, err = stream.Recv()
+		as.ErrorIs(err, io.EOF)
+	})
+
+	t.Run("faults", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+
+		server.RateLimit(http.MethodGet, "/v1/files/retrieve", 1)
+		_, err := api.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "missing"})
+		cozeErr, ok := coze.AsCozeError(err)
+		as.True(ok)
+		as.Equal(cozetest.CodeRateLimited, cozeErr.Code)
+		as.NotEmpty(cozeErr.LogID)
+		_, err = api.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "missing"})
+		cozeErr, ok = coze.AsCozeError(err)
+		as.True(ok)
+		as.Equal(cozetest.CodeNotFound, cozeErr.Code)
+
+		server.Inject("", "/v1/bots/:bot_id", cozetest.Fault{HTTPStatus: http.StatusBadGateway, Times: 1})
+		_, err = api.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: "bot", UseAPIVersion: 2})
+		as.NotNil(err)
+		as.Contains(err.Error(), "502")
+
+		server.Inject(http.MethodPost, "/v3/chat", cozetest.Fault{Code: 4001, Msg: "bot not published"})
+		_, err = api.Chat.Stream(ctx, &coze.CreateChatsReq{BotID: "bot", UserID: "user"})
+		cozeErr, ok = coze.AsCozeError(err)
+		as.True(ok)
+		as.Equal(4001, cozeErr.Code)
+		server.ClearFaults()
+
+		server.Inject(http.MethodPost, "/v1/conversation/create", cozetest.Fault{Latency: 100 * time.Millisecond, Times: 1})
+		start := time.Now()
+		_, err = api.Conversations.Create(ctx, &coze.CreateConversationsReq{BotID: "bot"})
+		as.Nil(err)
+		as.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("handle and assertions", func(t *testing.T) {
+		server := cozetest.NewServer()
+		defer server.Close()
+		api := newServerAPI(server)
+		server.Handle(http.MethodGet, "/v1/files/retrieve", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"code":0,"data":{"id":"custom","file_name":"custom.txt"}}`))
+		})
+		file, err := api.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "any"})
+		as.Nil(err)
+		as.Equal("custom.txt", file.FileName)
+
+		request := server.AssertRequested(t, http.MethodGet, "/v1/files/retrieve")
+		as.Equal("any", request.Query.Get("file_id"))
+		as.Equal("Bearer token", request.Header.Get("Authorization"))
+		server.AssertRequestCount(t, "", "/v1/files/retrieve", 1)
+		server.AssertNotRequested(t, http.MethodPost, "/v1/files/upload")
+
+		rec := &recordT{}
+		server.AssertRequested(rec, http.MethodPost, "/v3/chat")
+		server.AssertNotRequested(rec, http.MethodGet, "/v1/files/retrieve")
+		as.Len(rec.errors, 2)
+	})
+}
+
+type recordT struct {
+	errors []string
+}
+
+func (r *recordT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
+
+func mustParseInt(t *testing.T, s string) int64 {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		t.Fatalf("invalid id: %s", s)
+	}
+	return v
+}
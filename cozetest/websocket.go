@@ -0,0 +1,521 @@
+package cozetest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// The paths of the realtime endpoints served by WebSocketServer.
+const (
+	ChatPath          = "/v1/chat"
+	SpeechPath        = "/v1/audio/speech"
+	TranscriptionPath = "/v1/audio/transcriptions"
+)
+
+// errTurnEnded is returned by the steps ending a turn without the completed event
+var errTurnEnded = errors.New("turn ended")
+
+// WebSocketServer is an in-process server of the realtime endpoints of Coze, /v1/chat,
+// /v1/audio/speech and /v1/audio/transcriptions. It answers the config events and runs a scripted
+// reply for each turn, like a chat started by conversation.message.create, or the speech of the
+// text submitted by input_text_buffer.complete.
+//
+//	server := cozetest.NewWebSocketServer()
+//	defer server.Close()
+//	server.ChatReply(cozetest.MessageDeltas("Hel", "lo"))
+//	api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+type WebSocketServer struct {
+	// The base URL of the server, like http://127.0.0.1:12345.
+	URL string
+
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	replies  map[string][][]Step // queued replies of each path
+	connects map[string][][]Step // queued scripts run after the next connections of each path
+	sessions []*WebSocketSession
+}
+
+// NewWebSocketServer starts a server, close it after the test.
+func NewWebSocketServer() *WebSocketServer {
+	s := &WebSocketServer{
+		replies:  map[string][][]Step{},
+		connects: map[string][][]Step{},
+		upgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+	}
+	mux := http.NewServeMux()
+	for _, path := range []string{ChatPath, SpeechPath, TranscriptionPath} {
+		mux.HandleFunc(path, s.serveWebSocket)
+	}
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close disconnects all the sessions and stops the server.
+func (s *WebSocketServer) Close() {
+	for _, session := range s.Sessions() {
+		session.disconnect()
+	}
+	s.server.Close()
+}
+
+// ChatReply queues the reply of a chat turn, the turns use the queued replies in order, and reply
+// "hello" once the queue is empty. The reply is wrapped by conversation.chat.created,
+// conversation.chat.in_progress and conversation.chat.completed.
+func (s *WebSocketServer) ChatReply(steps ...Step) {
+	s.queue(s.replies, ChatPath, steps)
+}
+
+// SpeechReply queues the reply of a submitted text, the texts use the queued replies in order, and
+// reply 100ms of silent pcm once the queue is empty. The reply is wrapped by
+// input_text_buffer.completed and speech.audio.completed.
+func (s *WebSocketServer) SpeechReply(steps ...Step) {
+	s.queue(s.replies, SpeechPath, steps)
+}
+
+// TranscriptionReply queues the reply of a submitted audio, the audios use the queued replies in
+// order, and reply "hello" once the queue is empty. The reply is wrapped by
+// input_audio_buffer.completed and transcriptions.message.completed.
+func (s *WebSocketServer) TranscriptionReply(steps ...Step) {
+	s.queue(s.replies, TranscriptionPath, steps)
+}
+
+// OnConnect queues a script run after the created event of the next connection of path, like
+// Disconnect to test reconnecting.
+func (s *WebSocketServer) OnConnect(path string, steps ...Step) {
+	s.queue(s.connects, path, steps)
+}
+
+// Sessions returns the connections accepted so far, a connection is added before the handshake
+// completes, so it's there once the client is connected.
+func (s *WebSocketServer) Sessions() []*WebSocketSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*WebSocketSession{}, s.sessions...)
+}
+
+func (s *WebSocketServer) queue(scripts map[string][][]Step, path string, steps []Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scripts[path] = append(scripts[path], steps)
+}
+
+func (s *WebSocketServer) dequeue(scripts map[string][][]Step, path string) ([]Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(scripts[path]) == 0 {
+		return nil, false
+	}
+	steps := scripts[path][0]
+	scripts[path] = scripts[path][1:]
+	return steps, true
+}
+
+func (s *WebSocketServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	session := &WebSocketSession{
+		server:   s,
+		path:     r.URL.Path,
+		header:   r.Header.Clone(),
+		query:    r.URL.Query(),
+		incoming: make(chan *ReceivedEvent, 1000),
+		done:     make(chan struct{}),
+	}
+	s.mu.Lock()
+	session.index = len(s.sessions) + 1
+	s.sessions = append(s.sessions, session)
+	s.mu.Unlock()
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.mu.Lock()
+		for i, v := range s.sessions {
+			if v == session {
+				s.sessions = append(s.sessions[:i:i], s.sessions[i+1:]...)
+				break
+			}
+		}
+		s.mu.Unlock()
+		return
+	}
+	session.mu.Lock()
+	session.conn = conn
+	session.mu.Unlock()
+	go session.readLoop()
+	session.run()
+}
+
+// ReceivedEvent is an event sent by the client
+type ReceivedEvent struct {
+	EventType coze.WebSocketEventType `json:"event_type"`
+	ID        string                  `json:"id,omitempty"`
+	Data      json.RawMessage         `json:"data,omitempty"`
+}
+
+// DecodeData decodes the data of the event into v, like *coze.WebSocketChatUpdateEventData.
+func (r *ReceivedEvent) DecodeData(v any) error {
+	if len(r.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+// WebSocketSession is a connection of the server.
+type WebSocketSession struct {
+	server *WebSocketServer
+	index  int
+	path   string
+	header http.Header
+	query  url.Values
+	conn   *websocket.Conn
+
+	writeMu  sync.Mutex
+	incoming chan *ReceivedEvent
+	done     chan struct{}
+	doneOnce sync.Once
+
+	mu         sync.Mutex
+	received   []*ReceivedEvent
+	inputText  string
+	inputAudio []byte
+	eventSeq   int
+	turnSeq    int
+
+	// the turn running the steps
+	chat *coze.Chat
+}
+
+// Path returns the requested path, like /v1/chat.
+func (s *WebSocketSession) Path() string {
+	return s.path
+}
+
+// Header returns the headers of the handshake request.
+func (s *WebSocketSession) Header() http.Header {
+	return s.header
+}
+
+// Query returns the query of the handshake request, like bot_id of /v1/chat.
+func (s *WebSocketSession) Query() url.Values {
+	return s.query
+}
+
+// Received returns the events sent by the client so far.
+func (s *WebSocketSession) Received() []*ReceivedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ReceivedEvent{}, s.received...)
+}
+
+// ReceivedTypes returns the types of the events sent by the client so far.
+func (s *WebSocketSession) ReceivedTypes() []coze.WebSocketEventType {
+	received := s.Received()
+	types := make([]coze.WebSocketEventType, 0, len(received))
+	for _, event := range received {
+		types = append(types, event.EventType)
+	}
+	return types
+}
+
+// InputText returns all the text appended by input_text_buffer.append.
+func (s *WebSocketSession) InputText() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inputText
+}
+
+// InputAudio returns the audio appended by input_audio_buffer.append since the last
+// input_audio_buffer.clear.
+func (s *WebSocketSession) InputAudio() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte{}, s.inputAudio...)
+}
+
+// Done is closed when the connection is closed.
+func (s *WebSocketSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send sends an event to the client, data is encoded as the data field.
+func (s *WebSocketSession) Send(eventType coze.WebSocketEventType, data any) error {
+	s.mu.Lock()
+	s.eventSeq++
+	event := map[string]any{
+		"event_type": eventType,
+		"id":         fmt.Sprintf("event_%d_%d", s.index, s.eventSeq),
+		"detail":     &coze.EventDetail{LogID: fmt.Sprintf("cozetest_%d", s.index), RespondAt: fmt.Sprintf("%d", time.Now().UnixMilli())},
+	}
+	s.mu.Unlock()
+	if data != nil {
+		event["data"] = data
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+func (s *WebSocketSession) readLoop() {
+	defer s.doneOnce.Do(func() { close(s.done) })
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		event := &ReceivedEvent{}
+		if err := json.Unmarshal(message, event); err != nil {
+			_ = s.sendError(4000, fmt.Sprintf("invalid event: %s", err))
+			continue
+		}
+		s.mu.Lock()
+		s.received = append(s.received, event)
+		s.mu.Unlock()
+		select {
+		case s.incoming <- event:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// run sends the created event, runs the connect scripts and answers the received events in order
+func (s *WebSocketSession) run() {
+	defer s.disconnect()
+	created := map[string]coze.WebSocketEventType{
+		ChatPath:          coze.WebSocketEventTypeChatCreated,
+		SpeechPath:        coze.WebSocketEventTypeSpeechCreated,
+		TranscriptionPath: coze.WebSocketEventTypeTranscriptionsCreated,
+	}[s.path]
+	if err := s.Send(created, nil); err != nil {
+		return
+	}
+	if steps, ok := s.server.dequeue(s.server.connects, s.path); ok {
+		if err := s.runSteps(steps); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case event := <-s.incoming:
+			if err := s.handle(event); err != nil && !errors.Is(err, errTurnEnded) {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *WebSocketSession) runSteps(steps []Step) error {
+	for _, step := range steps {
+		if err := step(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle answers an event of the client like the Coze server
+func (s *WebSocketSession) handle(event *ReceivedEvent) error {
+	switch event.EventType {
+	case coze.WebSocketEventTypeChatUpdate:
+		return s.Send(coze.WebSocketEventTypeChatUpdated, event.Data)
+	case coze.WebSocketEventTypeSpeechUpdate:
+		return s.Send(coze.WebSocketEventTypeSpeechUpdated, event.Data)
+	case coze.WebSocketEventTypeTranscriptionsUpdate:
+		return s.Send(coze.WebSocketEventTypeTranscriptionsUpdated, event.Data)
+	case coze.WebSocketEventTypeInputTextBufferAppend:
+		data := &coze.WebSocketInputTextBufferAppendEventData{}
+		if err := event.DecodeData(data); err != nil {
+			return s.sendError(4000, err.Error())
+		}
+		s.mu.Lock()
+		s.inputText += data.Delta
+		s.mu.Unlock()
+		return nil
+	case coze.WebSocketEventTypeInputAudioBufferAppend:
+		data := &coze.WebSocketInputAudioBufferAppendEventData{}
+		if err := event.DecodeData(data); err != nil {
+			return s.sendError(4000, err.Error())
+		}
+		s.mu.Lock()
+		s.inputAudio = append(s.inputAudio, data.Delta...)
+		s.mu.Unlock()
+		return nil
+	case coze.WebSocketEventTypeInputAudioBufferClear:
+		s.mu.Lock()
+		s.inputAudio = nil
+		s.mu.Unlock()
+		return s.Send(coze.WebSocketEventTypeInputAudioBufferCleared, nil)
+	case coze.WebSocketEventTypeConversationClear:
+		return s.Send(coze.WebSocketEventTypeConversationCleared, nil)
+	case coze.WebSocketEventTypeConversationChatCancel:
+		return s.Send(coze.WebSocketEventTypeConversationChatCanceled, &coze.WebSocketConversationChatCanceledEventData{Code: 2, Msg: "canceled by user"})
+	case coze.WebSocketEventTypeConversationChatSubmitToolOutputs:
+		// only expected by RequiresAction
+		return nil
+	case coze.WebSocketEventTypeInputTextBufferComplete:
+		if err := s.Send(coze.WebSocketEventTypeInputTextBufferCompleted, nil); err != nil {
+			return err
+		}
+		return s.runTurn(func() error { return s.runSteps(s.reply(SpeechAudio(make([]byte, 3200)))) }, coze.WebSocketEventTypeSpeechAudioCompleted)
+	case coze.WebSocketEventTypeInputAudioBufferComplete:
+		if err := s.Send(coze.WebSocketEventTypeInputAudioBufferCompleted, nil); err != nil {
+			return err
+		}
+		if s.path == TranscriptionPath {
+			return s.runTurn(func() error { return s.runSteps(s.reply(Transcript("hello"))) }, coze.WebSocketEventTypeTranscriptionsMessageCompleted)
+		}
+		return s.runChat()
+	case coze.WebSocketEventTypeConversationMessageCreate:
+		return s.runChat()
+	case coze.WebSocketEventTypeInputTextGenerateAudio:
+		return s.runSteps([]Step{ChatAudio(make([]byte, 3200))})
+	default:
+		return s.sendError(4000, fmt.Sprintf("unknown event_type: %s", event.EventType))
+	}
+}
+
+// reply returns the next queued reply of the path, or the default steps
+func (s *WebSocketSession) reply(defaults ...Step) []Step {
+	if steps, ok := s.server.dequeue(s.server.replies, s.path); ok {
+		return steps
+	}
+	return defaults
+}
+
+// runTurn runs a turn and sends the completed event unless a step ended the turn
+func (s *WebSocketSession) runTurn(run func() error, completed coze.WebSocketEventType) error {
+	if err := run(); err != nil {
+		return err
+	}
+	return s.Send(completed, nil)
+}
+
+func (s *WebSocketSession) runChat() error {
+	s.mu.Lock()
+	s.turnSeq++
+	now := int(time.Now().Unix())
+	s.chat = &coze.Chat{
+		ID:             fmt.Sprintf("chat_%d_%d", s.index, s.turnSeq),
+		ConversationID: fmt.Sprintf("conversation_%d", s.index),
+		BotID:          s.query.Get("bot_id"),
+		CreatedAt:      now,
+		Status:         coze.ChatStatusCreated,
+	}
+	chat := *s.chat
+	s.mu.Unlock()
+
+	if err := s.Send(coze.WebSocketEventTypeConversationChatCreated, &chat); err != nil {
+		return err
+	}
+	if err := s.sendChatStatus(coze.WebSocketEventTypeConversationChatInProgress, coze.ChatStatusInProgress, nil); err != nil {
+		return err
+	}
+	if err := s.runSteps(s.reply(MessageDeltas("hello"))); err != nil {
+		return err
+	}
+	return s.sendChatStatus(coze.WebSocketEventTypeConversationChatCompleted, coze.ChatStatusCompleted, func(chat *coze.Chat) {
+		chat.CompletedAt = int(time.Now().Unix())
+		chat.Usage = &coze.ChatUsage{}
+	})
+}
+
+func (s *WebSocketSession) sendChatStatus(eventType coze.WebSocketEventType, status coze.ChatStatus, update func(chat *coze.Chat)) error {
+	s.mu.Lock()
+	chat := *s.currentChat()
+	s.mu.Unlock()
+	chat.Status = status
+	if update != nil {
+		update(&chat)
+	}
+	return s.Send(eventType, &chat)
+}
+
+// currentChat returns the chat of the running turn, it creates one for the steps run outside of a
+// chat turn
+func (s *WebSocketSession) currentChat() *coze.Chat {
+	if s.chat == nil {
+		s.chat = &coze.Chat{
+			ID:             fmt.Sprintf("chat_%d_0", s.index),
+			ConversationID: fmt.Sprintf("conversation_%d", s.index),
+			BotID:          s.query.Get("bot_id"),
+		}
+	}
+	return s.chat
+}
+
+func (s *WebSocketSession) newMessage(contentType coze.MessageContentType) *coze.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chat := s.currentChat()
+	s.eventSeq++
+	return &coze.Message{
+		ID:             fmt.Sprintf("message_%d_%d", s.index, s.eventSeq),
+		ConversationID: chat.ConversationID,
+		BotID:          chat.BotID,
+		ChatID:         chat.ID,
+		Role:           coze.MessageRoleAssistant,
+		Type:           coze.MessageTypeAnswer,
+		ContentType:    contentType,
+	}
+}
+
+func (s *WebSocketSession) sendError(code int, msg string) error {
+	return s.Send(coze.WebSocketEventTypeError, map[string]any{"code": code, "msg": msg})
+}
+
+// waitFor waits for an event of the client, the events received meanwhile are answered
+func (s *WebSocketSession) waitFor(eventType coze.WebSocketEventType, timeout time.Duration) (*ReceivedEvent, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case event := <-s.incoming:
+			if event.EventType == eventType {
+				return event, nil
+			}
+			if err := s.handle(event); err != nil && !errors.Is(err, errTurnEnded) {
+				return nil, err
+			}
+		case <-s.done:
+			return nil, errors.New("connection closed")
+		case <-deadline:
+			return nil, fmt.Errorf("wait event_type: %s timeout", eventType)
+		}
+	}
+}
+
+func (s *WebSocketSession) disconnect() {
+	s.doneOnce.Do(func() { close(s.done) })
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
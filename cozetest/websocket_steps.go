@@ -0,0 +1,175 @@
+package cozetest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// Step is a step of a scripted reply, it sends events to the client or controls the connection.
+type Step func(s *WebSocketSession) error
+
+// Event sends an event, data is encoded as the data field.
+func Event(eventType coze.WebSocketEventType, data any) Step {
+	return func(s *WebSocketSession) error {
+		return s.Send(eventType, data)
+	}
+}
+
+// MessageDeltas sends a conversation.message.delta of each content, then the
+// conversation.message.completed of the whole content.
+func MessageDeltas(contents ...string) Step {
+	return func(s *WebSocketSession) error {
+		message := s.newMessage(coze.MessageContentTypeText)
+		for _, content := range contents {
+			delta := *message
+			delta.Content = content
+			if err := s.Send(coze.WebSocketEventTypeConversationMessageDelta, &delta); err != nil {
+				return err
+			}
+		}
+		message.Content = strings.Join(contents, "")
+		return s.Send(coze.WebSocketEventTypeConversationMessageCompleted, message)
+	}
+}
+
+// ChatAudio sends a conversation.audio.delta of each chunk, then conversation.audio.completed.
+func ChatAudio(chunks ...[]byte) Step {
+	return func(s *WebSocketSession) error {
+		message := s.newMessage(coze.MessageContentTypeAudio)
+		for _, chunk := range chunks {
+			if err := s.Send(coze.WebSocketEventTypeConversationAudioDelta, &coze.WebSocketConversationAudioDeltaEventData{
+				Role:           message.Role,
+				Type:           message.Type,
+				Content:        chunk,
+				ContentType:    message.ContentType,
+				ID:             message.ID,
+				ConversationID: message.ConversationID,
+				BotID:          message.BotID,
+				ChatID:         message.ChatID,
+			}); err != nil {
+				return err
+			}
+		}
+		return s.Send(coze.WebSocketEventTypeConversationAudioCompleted, message)
+	}
+}
+
+// SpeechAudio sends a speech.audio.update of each chunk.
+func SpeechAudio(chunks ...[]byte) Step {
+	return func(s *WebSocketSession) error {
+		for _, chunk := range chunks {
+			if err := s.Send(coze.WebSocketEventTypeSpeechAudioUpdate, &coze.WebSocketSpeechAudioUpdateEventData{Delta: chunk}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Transcript sends a transcriptions.message.update of each content.
+func Transcript(contents ...string) Step {
+	return func(s *WebSocketSession) error {
+		for _, content := range contents {
+			if err := s.Send(coze.WebSocketEventTypeTranscriptionsMessageUpdate, &coze.WebSocketTranscriptionsMessageUpdateEventData{Content: content}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// RequiresAction sends conversation.chat.requires_action with the tool calls, and waits up to 10s
+// for conversation.chat.submit_tool_outputs before the following steps.
+func RequiresAction(toolCalls ...*coze.ChatToolCall) Step {
+	return func(s *WebSocketSession) error {
+		err := s.sendChatStatus(coze.WebSocketEventTypeConversationChatRequiresAction, coze.ChatStatusRequiresAction, func(chat *coze.Chat) {
+			chat.RequiredAction = &coze.ChatRequiredAction{
+				Type:              "submit_tool_outputs",
+				SubmitToolOutputs: &coze.ChatSubmitToolOutputs{ToolCalls: toolCalls},
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := s.waitFor(coze.WebSocketEventTypeConversationChatSubmitToolOutputs, 10*time.Second); err != nil {
+			return err
+		}
+		return s.sendChatStatus(coze.WebSocketEventTypeConversationChatInProgress, coze.ChatStatusInProgress, nil)
+	}
+}
+
+// WaitForEvent waits up to timeout for an event of the client before the following steps, the
+// other events received meanwhile are answered. No timeout if timeout is 0.
+func WaitForEvent(eventType coze.WebSocketEventType, timeout time.Duration) Step {
+	return func(s *WebSocketSession) error {
+		_, err := s.waitFor(eventType, timeout)
+		return err
+	}
+}
+
+// Error sends an error event and ends the turn without the completed event.
+func Error(code int, msg string) Step {
+	return func(s *WebSocketSession) error {
+		if err := s.sendError(code, msg); err != nil {
+			return err
+		}
+		return errTurnEnded
+	}
+}
+
+// ChatFailed sends conversation.chat.failed with the error and ends the turn.
+func ChatFailed(code int, msg string) Step {
+	return func(s *WebSocketSession) error {
+		err := s.sendChatStatus(coze.WebSocketEventTypeConversationChatFailed, coze.ChatStatusFailed, func(chat *coze.Chat) {
+			chat.FailedAt = int(time.Now().Unix())
+			chat.LastError = &coze.ChatError{Code: code, Msg: msg}
+		})
+		if err != nil {
+			return err
+		}
+		return errTurnEnded
+	}
+}
+
+// Sleep pauses the reply.
+func Sleep(d time.Duration) Step {
+	return func(s *WebSocketSession) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-s.done:
+			return errTurnEnded
+		}
+	}
+}
+
+// Disconnect drops the connection without a close frame, like a network failure.
+func Disconnect() Step {
+	return func(s *WebSocketSession) error {
+		s.disconnect()
+		return errTurnEnded
+	}
+}
+
+// CloseConnection sends a close frame with the code and reason, then closes the connection.
+func CloseConnection(code int, reason string) Step {
+	return func(s *WebSocketSession) error {
+		s.writeMu.Lock()
+		err := s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+		s.writeMu.Unlock()
+		if err != nil {
+			return err
+		}
+		// let the client read the close frame before the connection is closed
+		select {
+		case <-s.done:
+		case <-time.After(time.Second):
+		}
+		s.disconnect()
+		return errTurnEnded
+	}
+}
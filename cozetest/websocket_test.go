@@ -0,0 +1,198 @@
+package cozetest_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coze-dev/coze-go"
+	"github.com/coze-dev/coze-go/cozetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestWebSocketServer(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("chat", func(t *testing.T) {
+		server := cozetest.NewWebSocketServer()
+		defer server.Close()
+		server.ChatReply(
+			cozetest.MessageDeltas("It's ", "sunny"),
+			cozetest.RequiresAction(&coze.ChatToolCall{ID: "call", Type: "function", Function: &coze.ChatToolCallFunction{Name: "weather"}}),
+			cozetest.ChatAudio([]byte{1, 2}, []byte{3}),
+		)
+		api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+		chat := api.WebSockets.Chat.Create(ctx, &coze.CreateWebsocketChatReq{BotID: ptr("bot")})
+
+		var mu sync.Mutex
+		var content string
+		var audio []byte
+		chat.OnConversationMessageDelta(func(ctx context.Context, cli *coze.WebSocketChat, event *coze.WebSocketConversationMessageDeltaEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			content += event.Data.Content
+			return nil
+		})
+		chat.OnConversationAudioDelta(func(ctx context.Context, cli *coze.WebSocketChat, event *coze.WebSocketConversationAudioDeltaEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			audio = append(audio, event.Data.Content...)
+			return nil
+		})
+		chat.OnConversationChatRequiresAction(func(ctx context.Context, cli *coze.WebSocketChat, event *coze.WebSocketConversationChatRequiresActionEvent) error {
+			call := event.Data.RequiredAction.SubmitToolOutputs.ToolCalls[0]
+			return cli.ConversationChatSubmitToolOutputs(&coze.WebSocketConversationChatSubmitToolOutputsEventData{
+				ChatID:      event.Data.ID,
+				ToolOutputs: []*coze.ToolOutput{{ToolCallID: call.ID, Output: "sunny"}},
+			})
+		})
+		as.Nil(chat.Connect())
+
+		events := chat.Events(ctx)
+		defer events.Close()
+		as.Nil(chat.ChatUpdate(&coze.WebSocketChatUpdateEventData{ChatConfig: &coze.WebSocketChatConfig{UserID: ptr("user")}}))
+		updated, err := coze.AwaitWebSocketEvent[*coze.WebSocketChatUpdatedEvent](ctx, events, coze.WebSocketEventTypeChatUpdated)
+		as.Nil(err)
+		as.Equal("user", *updated.Data.ChatConfig.UserID)
+
+		as.Nil(chat.ConversationMessageCreate(&coze.WebSocketConversationMessageCreateEventData{Role: coze.MessageRoleUser, ContentType: coze.MessageContentTypeText, Content: "weather?"}))
+		completed, err := coze.AwaitWebSocketEvent[*coze.WebSocketConversationChatCompletedEvent](ctx, events, coze.WebSocketEventTypeConversationChatCompleted)
+		as.Nil(err)
+		as.Equal(coze.ChatStatusCompleted, completed.Data.Status)
+		as.Nil(chat.Close())
+
+		session := server.Sessions()[0]
+		as.Equal(cozetest.ChatPath, session.Path())
+		as.Equal("bot", session.Query().Get("bot_id"))
+		as.Equal("Bearer token", session.Header().Get("Authorization"))
+		as.Equal([]coze.WebSocketEventType{
+			coze.WebSocketEventTypeChatUpdate,
+			coze.WebSocketEventTypeConversationMessageCreate,
+			coze.WebSocketEventTypeConversationChatSubmitToolOutputs,
+		}, session.ReceivedTypes())
+		mu.Lock()
+		defer mu.Unlock()
+		as.Equal("It's sunny", content)
+		as.Equal([]byte{1, 2, 3}, audio)
+	})
+
+	t.Run("chat turns", func(t *testing.T) {
+		server := cozetest.NewWebSocketServer()
+		defer server.Close()
+		server.ChatReply(cozetest.Error(4000, "invalid message"))
+		api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+		chat := api.WebSockets.Chat.Create(ctx, &coze.CreateWebsocketChatReq{BotID: ptr("bot")})
+		as.Nil(chat.Connect())
+		defer chat.Close()
+
+		events := chat.Events(ctx)
+		defer events.Close()
+		as.Nil(chat.ConversationMessageCreate(&coze.WebSocketConversationMessageCreateEventData{Role: coze.MessageRoleUser, ContentType: coze.MessageContentTypeText, Content: "1"}))
+		_, err := coze.AwaitWebSocketEvent[*coze.WebSocketConversationChatCompletedEvent](ctx, events, coze.WebSocketEventTypeConversationChatCompleted)
+		as.NotNil(err)
+
+		// the queue is empty, the default reply
+		as.Nil(chat.ConversationMessageCreate(&coze.WebSocketConversationMessageCreateEventData{Role: coze.MessageRoleUser, ContentType: coze.MessageContentTypeText, Content: "2"}))
+		message, err := coze.AwaitWebSocketEvent[*coze.WebSocketConversationMessageCompletedEvent](ctx, events, coze.WebSocketEventTypeConversationMessageCompleted)
+		as.Nil(err)
+		as.Equal("hello", message.Data.Content)
+		as.True(strings.HasPrefix(message.Data.ChatID, "chat_"))
+	})
+
+	t.Run("speech", func(t *testing.T) {
+		server := cozetest.NewWebSocketServer()
+		defer server.Close()
+		server.SpeechReply(cozetest.SpeechAudio([]byte("ab"), []byte("c")))
+		api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+		speech := api.WebSockets.Audio.Speech.Create(ctx, &coze.CreateWebsocketAudioSpeechReq{})
+
+		var mu sync.Mutex
+		var audio []byte
+		speech.OnSpeechAudioUpdate(func(ctx context.Context, cli *coze.WebSocketAudioSpeech, event *coze.WebSocketSpeechAudioUpdateEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			audio = append(audio, event.Data.Delta...)
+			return nil
+		})
+		as.Nil(speech.Connect())
+		as.Nil(speech.InputTextBufferAppend(&coze.WebSocketInputTextBufferAppendEventData{Delta: "hello "}))
+		as.Nil(speech.InputTextBufferAppend(&coze.WebSocketInputTextBufferAppendEventData{Delta: "world"}))
+		as.Nil(speech.InputTextBufferComplete(nil))
+		as.Nil(speech.Wait())
+		as.Nil(speech.Close())
+
+		as.Equal("hello world", server.Sessions()[0].InputText())
+		mu.Lock()
+		defer mu.Unlock()
+		as.Equal("abc", string(audio))
+	})
+
+	t.Run("transcription", func(t *testing.T) {
+		server := cozetest.NewWebSocketServer()
+		defer server.Close()
+		server.TranscriptionReply(cozetest.Transcript("he", "hello"))
+		api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+		transcription := api.WebSockets.Audio.Transcriptions.Create(ctx, &coze.CreateWebsocketAudioTranscriptionReq{})
+
+		var mu sync.Mutex
+		var texts []string
+		transcription.OnTranscriptionsMessageUpdate(func(ctx context.Context, cli *coze.WebSocketAudioTranscription, event *coze.WebSocketTranscriptionsMessageUpdateEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			texts = append(texts, event.Data.Content)
+			return nil
+		})
+		as.Nil(transcription.Connect())
+		as.Nil(transcription.InputAudioBufferAppend(&coze.WebSocketInputAudioBufferAppendEventData{Delta: []byte{1, 2, 3}}))
+		as.Nil(transcription.InputAudioBufferComplete(nil))
+		as.Nil(transcription.Wait())
+		as.Nil(transcription.Close())
+
+		as.Equal([]byte{1, 2, 3}, server.Sessions()[0].InputAudio())
+		mu.Lock()
+		defer mu.Unlock()
+		as.Equal([]string{"he", "hello"}, texts)
+	})
+
+	t.Run("disconnect and reconnect", func(t *testing.T) {
+		server := cozetest.NewWebSocketServer()
+		defer server.Close()
+		server.OnConnect(cozetest.ChatPath, cozetest.Sleep(10*time.Millisecond), cozetest.Disconnect())
+		api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+		chat := api.WebSockets.Chat.Create(ctx, &coze.CreateWebsocketChatReq{
+			BotID:                 ptr("bot"),
+			WebSocketClientOption: &coze.WebSocketClientOption{Reconnect: &coze.WebSocketReconnectPolicy{InitialBackoff: time.Millisecond}},
+		})
+		as.Nil(chat.Connect())
+		defer chat.Close()
+
+		_, err := chat.WaitForEvent(ctx, &coze.WebSocketWaitReq{EventTypes: []coze.WebSocketEventType{coze.WebSocketEventTypeReconnected}, Timeout: 5 * time.Second})
+		as.Nil(err)
+		as.Len(server.Sessions(), 2)
+	})
+
+	t.Run("close connection", func(t *testing.T) {
+		server := cozetest.NewWebSocketServer()
+		defer server.Close()
+		server.OnConnect(cozetest.SpeechPath, cozetest.CloseConnection(4001, "quota exceeded"))
+		api := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+		speech := api.WebSockets.Audio.Speech.Create(ctx, &coze.CreateWebsocketAudioSpeechReq{})
+		closed := make(chan *coze.WebSocketClosedEvent, 1)
+		speech.OnClosed(func(ctx context.Context, cli *coze.WebSocketAudioSpeech, event *coze.WebSocketClosedEvent) error {
+			closed <- event
+			return nil
+		})
+		as.Nil(speech.Connect())
+		event := <-closed
+		as.Equal(4001, event.Data.Code)
+		as.Equal("quota exceeded", event.Data.Reason)
+	})
+}
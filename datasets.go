@@ -67,13 +67,19 @@ func (r *datasets) Delete(ctx context.Context, req *DeleteDatasetsReq) (*DeleteD
 }
 
 func (r *datasets) Process(ctx context.Context, req *ProcessDocumentsReq) (*ProcessDocumentsResp, error) {
+	return processDocuments(ctx, r.client, req)
+}
+
+// processDocuments gets the processing progress of documents, it is shared with
+// datasetsDocuments.WaitProcessed
+func processDocuments(ctx context.Context, client *core, req *ProcessDocumentsReq) (*ProcessDocumentsResp, error) {
 	request := &RawRequestReq{
 		Method: http.MethodPost,
 		URL:    "/v1/datasets/:dataset_id/process",
 		Body:   req,
 	}
 	response := new(processDocumentsResp)
-	err := r.client.rawRequest(ctx, request, response)
+	err := client.rawRequest(ctx, request, response)
 	return response.Data, err
 }
 
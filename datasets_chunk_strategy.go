@@ -0,0 +1,234 @@
+package coze
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DocumentChunkTypeAuto Automatic chunking and cleaning.
+	DocumentChunkTypeAuto = 0
+	// DocumentChunkTypeCustom Custom chunking by separator and max tokens.
+	DocumentChunkTypeCustom = 1
+
+	minChunkMaxTokens = 100
+	maxChunkMaxTokens = 2000
+
+	// the rules of automatic chunking simulated by PreviewChunks
+	autoChunkSeparator = "\n"
+	autoChunkMaxTokens = 800
+)
+
+// ErrInvalidChunkStrategy is wrapped by the errors of DocumentChunkStrategy.Validate
+var ErrInvalidChunkStrategy = errors.New("invalid chunk strategy")
+
+// DocumentChunkStrategyBuildAuto creates a strategy of automatic chunking and cleaning
+func DocumentChunkStrategyBuildAuto() *DocumentChunkStrategy {
+	return &DocumentChunkStrategy{ChunkType: DocumentChunkTypeAuto}
+}
+
+// DocumentChunkStrategyBuildCustom creates a strategy splitting by separator, chunks longer than
+// maxTokens are split further.
+func DocumentChunkStrategyBuildCustom(separator string, maxTokens int, removeExtraSpaces, removeUrlsEmails bool) *DocumentChunkStrategy {
+	return &DocumentChunkStrategy{
+		ChunkType:         DocumentChunkTypeCustom,
+		Separator:         separator,
+		MaxTokens:         maxTokens,
+		RemoveExtraSpaces: removeExtraSpaces,
+		RemoveUrlsEmails:  removeUrlsEmails,
+	}
+}
+
+// DocumentChunkStrategyBuildMarkdownHeadings creates a strategy splitting markdown before every
+// heading, so a chunk is a section.
+func DocumentChunkStrategyBuildMarkdownHeadings(maxTokens int) *DocumentChunkStrategy {
+	return DocumentChunkStrategyBuildCustom("\n#", maxTokens, false, false)
+}
+
+// DocumentChunkStrategyBuildParagraph creates a strategy splitting by blank lines
+func DocumentChunkStrategyBuildParagraph(maxTokens int) *DocumentChunkStrategy {
+	return DocumentChunkStrategyBuildCustom("\n\n", maxTokens, false, false)
+}
+
+// DocumentChunkStrategyBuildTableRows creates a strategy for spreadsheets and CSV files, every row
+// is a chunk.
+func DocumentChunkStrategyBuildTableRows() *DocumentChunkStrategy {
+	return DocumentChunkStrategyBuildCustom("\n", minChunkMaxTokens, false, false)
+}
+
+// DocumentChunkStrategyBuildImage creates a strategy of image datasets
+func DocumentChunkStrategyBuildImage(captionType DocumentCaptionType) *DocumentChunkStrategy {
+	return &DocumentChunkStrategy{ChunkType: DocumentChunkTypeAuto, CaptionType: &captionType}
+}
+
+// Validate checks the strategy for documents of formatType before it is sent, the returned error
+// wraps ErrInvalidChunkStrategy.
+func (s *DocumentChunkStrategy) Validate(formatType DocumentFormatType) error {
+	if s.CaptionType != nil {
+		if formatType != DocumentFormatTypeImage {
+			return chunkStrategyError("caption_type only takes effect for images")
+		}
+		if *s.CaptionType != DocumentCaptionTypeAuto && *s.CaptionType != DocumentCaptionTypeManual {
+			return chunkStrategyError("unknown caption_type %d", *s.CaptionType)
+		}
+	}
+	switch s.ChunkType {
+	case DocumentChunkTypeAuto:
+		return nil
+	case DocumentChunkTypeCustom:
+	default:
+		return chunkStrategyError("unknown chunk_type %d", s.ChunkType)
+	}
+
+	if formatType == DocumentFormatTypeImage {
+		return chunkStrategyError("custom chunking is not supported for images")
+	}
+	if s.Separator == "" {
+		return chunkStrategyError("separator is required for custom chunking")
+	}
+	if s.MaxTokens < minChunkMaxTokens || s.MaxTokens > maxChunkMaxTokens {
+		return chunkStrategyError("max_tokens %d is out of range [%d, %d]", s.MaxTokens, minChunkMaxTokens, maxChunkMaxTokens)
+	}
+	return nil
+}
+
+func chunkStrategyError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidChunkStrategy, fmt.Sprintf(format, args...))
+}
+
+// DocumentChunkPreview represents a chunk simulated by PreviewChunks
+type DocumentChunkPreview struct {
+	Index   int
+	Content string
+
+	// The estimated token count of the content.
+	Tokens int
+}
+
+var (
+	chunkExtraSpacesRegexp = regexp.MustCompile(`[ \t]+`)
+	chunkExtraLinesRegexp  = regexp.MustCompile(`\n{2,}`)
+	chunkUrlsEmailsRegexp  = regexp.MustCompile(`https?://\S+|[\w.+-]+@[\w-]+\.[\w.-]+`)
+)
+
+// PreviewChunks simulates locally how a text is split by the strategy, to tune a strategy before the
+// document is uploaded. The server may count tokens and clean text differently, so the result is an
+// approximation.
+func PreviewChunks(text string, strategy *DocumentChunkStrategy) ([]*DocumentChunkPreview, error) {
+	if strategy == nil {
+		strategy = DocumentChunkStrategyBuildAuto()
+	}
+	if err := strategy.Validate(DocumentFormatTypeDocument); err != nil {
+		return nil, err
+	}
+
+	separator, maxTokens := strategy.Separator, strategy.MaxTokens
+	removeExtraSpaces, removeUrlsEmails := strategy.RemoveExtraSpaces, strategy.RemoveUrlsEmails
+	if strategy.ChunkType == DocumentChunkTypeAuto {
+		separator, maxTokens = autoChunkSeparator, autoChunkMaxTokens
+		removeExtraSpaces = true
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if removeUrlsEmails {
+		text = chunkUrlsEmailsRegexp.ReplaceAllString(text, "")
+	}
+	if removeExtraSpaces {
+		text = chunkExtraSpacesRegexp.ReplaceAllString(text, " ")
+		text = chunkExtraLinesRegexp.ReplaceAllString(text, "\n")
+	}
+
+	var chunks []*DocumentChunkPreview
+	for i, piece := range strings.Split(text, separator) {
+		// a separator starting a line is kept at the start of the chunks, such as markdown headings
+		if i > 0 && strings.HasPrefix(separator, "\n") && strings.TrimSpace(separator) != "" {
+			piece = strings.TrimLeft(separator, "\n") + piece
+		}
+		for _, content := range splitByTokens(piece, maxTokens) {
+			content = strings.TrimSpace(content)
+			if content == "" {
+				continue
+			}
+			chunks = append(chunks, &DocumentChunkPreview{
+				Index:   len(chunks),
+				Content: content,
+				Tokens:  estimateTokens(content),
+			})
+		}
+	}
+	return chunks, nil
+}
+
+// splitByTokens splits text into pieces of at most maxTokens estimated tokens, words are not split
+// unless a single word exceeds maxTokens.
+func splitByTokens(text string, maxTokens int) []string {
+	var res []string
+	start, tokens := 0, 0
+	for _, unit := range tokenUnits(text, maxTokens) {
+		if tokens+unit.tokens > maxTokens && unit.start > start {
+			res = append(res, text[start:unit.start])
+			start, tokens = unit.start, 0
+		}
+		tokens += unit.tokens
+	}
+	return append(res, text[start:])
+}
+
+// estimateTokens estimates the token count of text, see tokenUnits.
+func estimateTokens(text string) int {
+	tokens := 0
+	for _, unit := range tokenUnits(text, 0) {
+		tokens += unit.tokens
+	}
+	return tokens
+}
+
+type tokenUnit struct {
+	start  int
+	tokens int
+}
+
+// tokenUnits splits text into units never split into chunks: a CJK character or a punctuation is a
+// token, other words are a token per 4 bytes and spaces are free. Words longer than maxTokens are
+// split if maxTokens is positive.
+func tokenUnits(text string, maxTokens int) []tokenUnit {
+	var units []tokenUnit
+	wordStart := -1
+	flush := func(end int) {
+		if wordStart < 0 {
+			return
+		}
+		for wordStart < end {
+			size := end - wordStart
+			if maxTokens > 0 && size > maxTokens*4 {
+				size = maxTokens * 4
+				for size > 0 && !utf8.RuneStart(text[wordStart+size]) {
+					size--
+				}
+			}
+			units = append(units, tokenUnit{start: wordStart, tokens: (size + 3) / 4})
+			wordStart += size
+		}
+		wordStart = -1
+	}
+	for i, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || unicode.IsPunct(r):
+			flush(i)
+			units = append(units, tokenUnit{start: i, tokens: 1})
+		case unicode.IsSpace(r):
+			flush(i)
+			units = append(units, tokenUnit{start: i})
+		default:
+			if wordStart < 0 {
+				wordStart = i
+			}
+		}
+	}
+	flush(len(text))
+	return units
+}
@@ -0,0 +1,84 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentChunkStrategy(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("validate", func(t *testing.T) {
+		as.Nil(DocumentChunkStrategyBuildAuto().Validate(DocumentFormatTypeDocument))
+		as.Nil(DocumentChunkStrategyBuildMarkdownHeadings(800).Validate(DocumentFormatTypeDocument))
+		as.Nil(DocumentChunkStrategyBuildParagraph(500).Validate(DocumentFormatTypeDocument))
+		as.Nil(DocumentChunkStrategyBuildTableRows().Validate(DocumentFormatTypeSpreadsheet))
+		as.Nil(DocumentChunkStrategyBuildImage(DocumentCaptionTypeManual).Validate(DocumentFormatTypeImage))
+
+		for _, strategy := range []*DocumentChunkStrategy{
+			DocumentChunkStrategyBuildParagraph(50),
+			DocumentChunkStrategyBuildParagraph(3000),
+			DocumentChunkStrategyBuildCustom("", 500, false, false),
+			{ChunkType: 2},
+			DocumentChunkStrategyBuildImage(DocumentCaptionTypeAuto),
+		} {
+			as.True(errors.Is(strategy.Validate(DocumentFormatTypeDocument), ErrInvalidChunkStrategy))
+		}
+		as.NotNil(DocumentChunkStrategyBuildParagraph(500).Validate(DocumentFormatTypeImage))
+	})
+
+	t.Run("create validates before sending", func(t *testing.T) {
+		documents := newDatasetsDocuments(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			t.Fatal("unexpected request")
+			return nil, nil
+		})))
+		_, err := documents.Create(context.Background(), &CreateDatasetsDocumentsReq{
+			DatasetID:     1,
+			DocumentBases: []*DocumentBase{DocumentBaseBuildLocalFile("a.md", "a", "md")},
+			ChunkStrategy: DocumentChunkStrategyBuildCustom("\n", 10, false, false),
+		})
+		as.True(errors.Is(err, ErrInvalidChunkStrategy))
+	})
+
+	t.Run("preview markdown headings", func(t *testing.T) {
+		text := "# Title\nintro\n\n## Install\nrun go get\n\n## Usage\nimport the package"
+		chunks, err := PreviewChunks(text, DocumentChunkStrategyBuildMarkdownHeadings(100))
+		as.Nil(err)
+		as.Len(chunks, 3)
+		as.Equal("# Title\nintro", chunks[0].Content)
+		as.Equal("## Install\nrun go get", chunks[1].Content)
+		as.Equal(2, chunks[2].Index)
+	})
+
+	t.Run("preview splits long chunks", func(t *testing.T) {
+		text := strings.Repeat("word ", 300) + "\n\n" + strings.Repeat("字", 150)
+		chunks, err := PreviewChunks(text, DocumentChunkStrategyBuildParagraph(100))
+		as.Nil(err)
+		as.Len(chunks, 5)
+		for _, chunk := range chunks {
+			as.LessOrEqual(chunk.Tokens, 100)
+		}
+		as.Equal(150, chunks[3].Tokens+chunks[4].Tokens)
+	})
+
+	t.Run("preview cleaning", func(t *testing.T) {
+		text := "contact   me at dev@example.com\n\n\nor   https://example.com/page now"
+		chunks, err := PreviewChunks(text, DocumentChunkStrategyBuildCustom("\n", 100, true, true))
+		as.Nil(err)
+		as.Len(chunks, 2)
+		as.Equal("contact me at", chunks[0].Content)
+		as.Equal("or now", chunks[1].Content)
+
+		chunks, err = PreviewChunks("a\nb", nil)
+		as.Nil(err)
+		as.Len(chunks, 2)
+
+		_, err = PreviewChunks("a", &DocumentChunkStrategy{ChunkType: 1})
+		as.NotNil(err)
+	})
+}
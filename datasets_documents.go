@@ -7,6 +7,11 @@ import (
 )
 
 func (r *datasetsDocuments) Create(ctx context.Context, req *CreateDatasetsDocumentsReq) (*CreateDatasetsDocumentsResp, error) {
+	if req.ChunkStrategy != nil {
+		if err := req.ChunkStrategy.Validate(req.FormatType); err != nil {
+			return nil, err
+		}
+	}
 	request := &RawRequestReq{
 		Method:  http.MethodPost,
 		URL:     "/open_api/knowledge/document/create",
@@ -170,8 +175,24 @@ type DocumentChunkStrategy struct {
 	// The chunk identifier.
 	// Required when chunk_type=1.
 	Separator string `json:"separator,omitempty"`
+
+	// How the images of an image dataset are captioned. Values include:
+	// 0: Automatically captioned by the model
+	// 1: Manually captioned
+	// Only takes effect for images.
+	CaptionType *DocumentCaptionType `json:"caption_type,omitempty"`
 }
 
+// DocumentCaptionType represents how images are captioned
+type DocumentCaptionType int
+
+const (
+	// Automatically captioned by the model
+	DocumentCaptionTypeAuto DocumentCaptionType = 0
+	// Manually captioned
+	DocumentCaptionTypeManual DocumentCaptionType = 1
+)
+
 // DocumentSourceInfo represents source information for a document
 type DocumentSourceInfo struct {
 	// Base64 encoding of the local file.
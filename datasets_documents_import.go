@@ -0,0 +1,299 @@
+package coze
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxDocumentBases is the max number of documents created by a single request
+const maxDocumentBases = 10
+
+// Import creates any number of documents, they are split into requests of at most req.BatchSize
+// documents and req.MaxBatchBytes encoded bytes, which are sent concurrently. The content of a
+// local file is only read when its batch is sent.
+//
+// The returned error is only set if the import could not start, the failures of the documents are
+// reported by the results. No more batch is sent once ctx is done, its documents fail with the
+// error of ctx.
+func (r *datasetsDocuments) Import(ctx context.Context, req *ImportDatasetsDocumentsReq) (*ImportDatasetsDocumentsResp, error) {
+	if req.DatasetID == 0 {
+		return nil, errors.New("dataset id is required")
+	}
+	resp := &ImportDatasetsDocumentsResp{Results: make([]*DocumentImportResult, len(req.Sources))}
+	for i, source := range req.Sources {
+		resp.Results[i] = &DocumentImportResult{Index: i, Name: source.Name}
+	}
+
+	batches := req.batches()
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = 2
+	}
+	err := runConcurrently(ctx, len(batches), concurrency, func(ctx context.Context, i int) error {
+		r.importBatch(ctx, req, batches[i], resp.Results)
+		return nil
+	})
+	if err != nil {
+		// the documents of the batches not sent as ctx is done
+		for _, result := range resp.Results {
+			if result.Document == nil && result.Err == nil {
+				result.Err = err
+			}
+		}
+	}
+	return resp, nil
+}
+
+// ImportDatasetsDocumentsReq represents request for importing documents
+type ImportDatasetsDocumentsReq struct {
+	// The ID of the knowledge base.
+	DatasetID int64
+
+	// The documents to create.
+	Sources []*DocumentImportSource
+
+	// Optional: The chunk strategy, see CreateDatasetsDocumentsReq.ChunkStrategy.
+	ChunkStrategy *DocumentChunkStrategy
+
+	// Optional: The type of file format, see CreateDatasetsDocumentsReq.FormatType.
+	FormatType DocumentFormatType
+
+	// Optional: The max number of documents of a request, default and max is 10.
+	BatchSize int
+
+	// Optional: The max base64 encoded bytes of a request, default is 20MB. A document larger than
+	// the limit is sent alone.
+	MaxBatchBytes int64
+
+	// Optional: The max number of concurrent requests, default is 2.
+	Concurrency int
+}
+
+// ImportDatasetsDocumentsResp represents response for importing documents
+type ImportDatasetsDocumentsResp struct {
+	// The results in the order of ImportDatasetsDocumentsReq.Sources.
+	Results []*DocumentImportResult
+}
+
+// Succeeded returns the results of the created documents
+func (r *ImportDatasetsDocumentsResp) Succeeded() []*DocumentImportResult {
+	var res []*DocumentImportResult
+	for _, result := range r.Results {
+		if result.Err == nil {
+			res = append(res, result)
+		}
+	}
+	return res
+}
+
+// Failed returns the results of the documents failed to be created
+func (r *ImportDatasetsDocumentsResp) Failed() []*DocumentImportResult {
+	var res []*DocumentImportResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			res = append(res, result)
+		}
+	}
+	return res
+}
+
+// DocumentImportResult represents the result of a single imported document
+type DocumentImportResult struct {
+	Index int
+	Name  string
+
+	// The created document, nil if failed.
+	Document *Document
+	Err      error
+}
+
+// DocumentImportSource represents a document to import
+type DocumentImportSource struct {
+	// The name of the document.
+	Name string
+
+	// The file extension of a local file, such as "md", default is the extension of Name.
+	FileType string
+
+	// The size of a local file in bytes, used to split batches by MaxBatchBytes, 0 if unknown.
+	Size int64
+
+	// Opens the content of a local file, called when the batch of the document is sent.
+	Open func() (io.ReadCloser, error)
+
+	// The document of other sources such as web pages and images, used if Open is nil.
+	Base *DocumentBase
+}
+
+// NewDocumentImportSourceFromPath creates a source of a local file
+func NewDocumentImportSourceFromPath(filePath string) (*DocumentImportSource, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentImportSource{
+		Name: filepath.Base(filePath),
+		Size: info.Size(),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(filePath)
+		},
+	}, nil
+}
+
+// NewDocumentImportSourceFromFS creates a source of a file of fsys, the document is named name.
+func NewDocumentImportSourceFromFS(fsys fs.FS, name string) (*DocumentImportSource, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentImportSource{
+		Name: name,
+		Size: info.Size(),
+		Open: func() (io.ReadCloser, error) {
+			return fsys.Open(name)
+		},
+	}, nil
+}
+
+// NewDocumentImportSourceFromReader creates a source of the content of reader, the reader can only
+// be imported once.
+func NewDocumentImportSourceFromReader(name string, reader io.Reader) *DocumentImportSource {
+	return &DocumentImportSource{
+		Name: name,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(reader), nil
+		},
+	}
+}
+
+// NewDocumentImportSourceFromBase creates a source of a built document, such as a web page.
+func NewDocumentImportSourceFromBase(base *DocumentBase) *DocumentImportSource {
+	return &DocumentImportSource{Name: base.Name, Base: base}
+}
+
+// encodedSize returns the base64 size of the source, 0 if unknown
+func (s *DocumentImportSource) encodedSize() int64 {
+	if s.Open == nil {
+		return 0
+	}
+	return int64(base64.StdEncoding.EncodedLen(int(s.Size)))
+}
+
+// build reads and encodes the content of a local file
+func (s *DocumentImportSource) build() (*DocumentBase, error) {
+	if s.Open == nil {
+		if s.Base == nil {
+			return nil, errors.New("document source has no content")
+		}
+		return s.Base, nil
+	}
+	reader, err := s.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content := &strings.Builder{}
+	if s.Size > 0 {
+		content.Grow(base64.StdEncoding.EncodedLen(int(s.Size)))
+	}
+	encoder := base64.NewEncoder(base64.StdEncoding, content)
+	if _, err := io.Copy(encoder, reader); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	encoded := content.String()
+	fileType := s.FileType
+	if fileType == "" {
+		fileType = strings.TrimPrefix(path.Ext(s.Name), ".")
+	}
+	return &DocumentBase{
+		Name:       s.Name,
+		SourceInfo: &DocumentSourceInfo{FileBase64: &encoded, FileType: &fileType},
+	}, nil
+}
+
+// batches splits the indexes of the sources by BatchSize and MaxBatchBytes
+func (req *ImportDatasetsDocumentsReq) batches() [][]int {
+	batchSize := req.BatchSize
+	if batchSize <= 0 || batchSize > maxDocumentBases {
+		batchSize = maxDocumentBases
+	}
+	maxBytes := req.MaxBatchBytes
+	if maxBytes <= 0 {
+		maxBytes = 20 << 20
+	}
+
+	var res [][]int
+	var batch []int
+	var batchBytes int64
+	for i, source := range req.Sources {
+		size := source.encodedSize()
+		if len(batch) > 0 && (len(batch) >= batchSize || batchBytes+size > maxBytes) {
+			res = append(res, batch)
+			batch, batchBytes = nil, 0
+		}
+		batch = append(batch, i)
+		batchBytes += size
+	}
+	if len(batch) > 0 {
+		res = append(res, batch)
+	}
+	return res
+}
+
+func (r *datasetsDocuments) importBatch(ctx context.Context, req *ImportDatasetsDocumentsReq, batch []int, results []*DocumentImportResult) {
+	// the documents failed to be read are excluded from the request
+	var bases []*DocumentBase
+	var sent []int
+	for _, i := range batch {
+		base, err := req.Sources[i].build()
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		bases = append(bases, base)
+		sent = append(sent, i)
+	}
+	if len(bases) == 0 {
+		return
+	}
+
+	resp, err := r.Create(ctx, &CreateDatasetsDocumentsReq{
+		DatasetID:     req.DatasetID,
+		DocumentBases: bases,
+		ChunkStrategy: req.ChunkStrategy,
+		FormatType:    req.FormatType,
+	})
+	if err != nil {
+		for _, i := range sent {
+			results[i].Err = err
+		}
+		return
+	}
+
+	// the documents are returned in the order of the request, fall back to names otherwise
+	byName := map[string][]*Document{}
+	for _, doc := range resp.DocumentInfos {
+		byName[doc.Name] = append(byName[doc.Name], doc)
+	}
+	for j, i := range sent {
+		if len(resp.DocumentInfos) == len(sent) {
+			results[i].Document = resp.DocumentInfos[j]
+		} else if docs := byName[bases[j].Name]; len(docs) > 0 {
+			results[i].Document = docs[0]
+			byName[bases[j].Name] = docs[1:]
+		} else {
+			results[i].Err = errors.New("document is not returned by the server")
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package coze
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetsDocumentsImport(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var batchSizes []int
+	documents := newDatasetsDocuments(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		reqBody := &CreateDatasetsDocumentsReq{}
+		as.Nil(json.Unmarshal(body, reqBody))
+		mu.Lock()
+		batchSizes = append(batchSizes, len(reqBody.DocumentBases))
+		mu.Unlock()
+		docs := []*Document{}
+		for _, base := range reqBody.DocumentBases {
+			if base.Name == "fail.md" {
+				return mockResponse(http.StatusOK, &createDatasetsDocumentsResp{baseResponse: baseResponse{Code: 4000, Msg: "invalid document"}})
+			}
+			content := ""
+			if base.SourceInfo.FileBase64 != nil {
+				decoded, _ := base64.StdEncoding.DecodeString(*base.SourceInfo.FileBase64)
+				content = string(decoded)
+			}
+			docs = append(docs, &Document{DocumentID: "id_" + base.Name, Name: base.Name, Type: content})
+		}
+		return mockResponse(http.StatusOK, &createDatasetsDocumentsResp{
+			CreateDatasetsDocumentsResp: &CreateDatasetsDocumentsResp{DocumentInfos: docs},
+		})
+	})))
+
+	t.Run("split into batches", func(t *testing.T) {
+		batchSizes = nil
+		sources := []*DocumentImportSource{}
+		for i := 0; i < 23; i++ {
+			sources = append(sources, NewDocumentImportSourceFromReader(strconv.Itoa(i)+".md", strings.NewReader("content"+strconv.Itoa(i))))
+		}
+		resp, err := documents.Import(ctx, &ImportDatasetsDocumentsReq{DatasetID: 1, Sources: sources, Concurrency: 3})
+		as.Nil(err)
+		as.Len(resp.Succeeded(), 23)
+		as.ElementsMatch([]int{10, 10, 3}, batchSizes)
+		for i, result := range resp.Results {
+			as.Equal(i, result.Index)
+			as.Equal("id_"+strconv.Itoa(i)+".md", result.Document.DocumentID)
+			as.Equal("content"+strconv.Itoa(i), result.Document.Type)
+		}
+	})
+
+	t.Run("split by bytes", func(t *testing.T) {
+		batchSizes = nil
+		dir := t.TempDir()
+		sources := []*DocumentImportSource{}
+		for i := 0; i < 4; i++ {
+			path := filepath.Join(dir, strconv.Itoa(i)+".txt")
+			as.Nil(os.WriteFile(path, []byte(strings.Repeat("a", 30)), 0o600))
+			source, err := NewDocumentImportSourceFromPath(path)
+			as.Nil(err)
+			sources = append(sources, source)
+		}
+		sources = append(sources, NewDocumentImportSourceFromBase(DocumentBaseBuildWebPage("web", "https://example.com", nil)))
+		resp, err := documents.Import(ctx, &ImportDatasetsDocumentsReq{DatasetID: 1, Sources: sources, MaxBatchBytes: 100})
+		as.Nil(err)
+		as.Len(resp.Succeeded(), 5)
+		as.ElementsMatch([]int{2, 3}, batchSizes)
+		as.Equal(strings.Repeat("a", 30), resp.Results[3].Document.Type)
+	})
+
+	t.Run("aggregate failures", func(t *testing.T) {
+		resp, err := documents.Import(ctx, &ImportDatasetsDocumentsReq{
+			DatasetID: 1,
+			BatchSize: 2,
+			Sources: []*DocumentImportSource{
+				NewDocumentImportSourceFromReader("ok.md", strings.NewReader("ok")),
+				{Name: "broken.md", Open: func() (io.ReadCloser, error) { return nil, errors.New("open failed") }},
+				NewDocumentImportSourceFromReader("fail.md", strings.NewReader("fail")),
+				NewDocumentImportSourceFromReader("other.md", strings.NewReader("other")),
+			},
+		})
+		as.Nil(err)
+		as.Len(resp.Succeeded(), 1)
+		failed := resp.Failed()
+		as.Len(failed, 3)
+		as.Equal("broken.md", failed[0].Name)
+		as.EqualError(failed[0].Err, "open failed")
+		_, ok := AsCozeError(failed[1].Err)
+		as.True(ok)
+	})
+	t.Run("stop on cancel", func(t *testing.T) {
+		batchSizes = nil
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		resp, err := documents.Import(ctx, &ImportDatasetsDocumentsReq{
+			DatasetID: 1,
+			BatchSize: 1,
+			Sources: []*DocumentImportSource{
+				NewDocumentImportSourceFromReader("0.md", strings.NewReader("0")),
+				NewDocumentImportSourceFromReader("1.md", strings.NewReader("1")),
+			},
+		})
+		as.Nil(err)
+		as.Empty(batchSizes)
+		as.Len(resp.Failed(), 2)
+		for _, result := range resp.Results {
+			as.ErrorIs(result.Err, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package coze
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WaitProcessed polls the processing progress of documents until none of them is processing. The
+// poll interval starts at opt.PollInterval and doubles up to opt.MaxPollInterval. The documents
+// missing from the progress are taken as processing, so ctx should carry a deadline.
+//
+// If some documents failed, the progress is returned with a *DocumentsProcessError.
+func (r *datasetsDocuments) WaitProcessed(ctx context.Context, datasetID string, documentIDs []string, opt *WaitProcessedDocumentsOption) ([]*DocumentProgress, error) {
+	interval, maxInterval := opt.intervals()
+	for {
+		resp, err := processDocuments(ctx, r.client, &ProcessDocumentsReq{DatasetID: datasetID, DocumentIDs: documentIDs})
+		if err != nil {
+			return nil, err
+		}
+		var progress []*DocumentProgress
+		if resp != nil {
+			progress = resp.Data
+		}
+		if opt != nil && opt.OnProgress != nil {
+			opt.OnProgress(progress)
+		}
+
+		processing := false
+		var failed []*DocumentProgress
+		reported := map[string]bool{}
+		for _, item := range progress {
+			reported[item.DocumentID] = true
+			switch item.Status {
+			case DocumentStatusProcessing:
+				processing = true
+			case DocumentStatusFailed:
+				failed = append(failed, item)
+			}
+		}
+		for _, id := range documentIDs {
+			if !reported[id] {
+				processing = true
+			}
+		}
+		if len(failed) > 0 && (!processing || (opt != nil && opt.FailFast)) {
+			return progress, &DocumentsProcessError{DatasetID: datasetID, Failed: failed}
+		}
+		if !processing {
+			return progress, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return progress, ctx.Err()
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// CreateAndWait creates documents and waits until they are processed, see WaitProcessed.
+func (r *datasetsDocuments) CreateAndWait(ctx context.Context, req *CreateDatasetsDocumentsReq, opt *WaitProcessedDocumentsOption) (*CreateAndWaitDatasetsDocumentsResp, error) {
+	created, err := r.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &CreateAndWaitDatasetsDocumentsResp{CreateDatasetsDocumentsResp: created}
+	documentIDs := make([]string, 0, len(created.DocumentInfos))
+	for _, doc := range created.DocumentInfos {
+		documentIDs = append(documentIDs, doc.DocumentID)
+	}
+	if len(documentIDs) == 0 {
+		return resp, nil
+	}
+	resp.Progress, err = r.WaitProcessed(ctx, strconv.FormatInt(req.DatasetID, 10), documentIDs, opt)
+	return resp, err
+}
+
+// WaitProcessedDocumentsOption represents the options of Documents.WaitProcessed
+type WaitProcessedDocumentsOption struct {
+	// The first poll interval, default is 1s.
+	PollInterval time.Duration
+
+	// The max poll interval, default is 10s.
+	MaxPollInterval time.Duration
+
+	// Return as soon as a document failed instead of waiting for the others.
+	FailFast bool
+
+	// Called with the progress of every poll, including percent and remaining time in seconds.
+	OnProgress func(progress []*DocumentProgress)
+}
+
+func (o *WaitProcessedDocumentsOption) intervals() (time.Duration, time.Duration) {
+	interval, maxInterval := time.Second, 10*time.Second
+	if o != nil && o.PollInterval > 0 {
+		interval = o.PollInterval
+	}
+	if o != nil && o.MaxPollInterval > 0 {
+		maxInterval = o.MaxPollInterval
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	return interval, maxInterval
+}
+
+// CreateAndWaitDatasetsDocumentsResp represents response for creating documents and waiting for them
+type CreateAndWaitDatasetsDocumentsResp struct {
+	*CreateDatasetsDocumentsResp
+
+	// The final progress of the created documents.
+	Progress []*DocumentProgress
+}
+
+// DocumentsProcessError is returned when some documents failed to be processed
+type DocumentsProcessError struct {
+	DatasetID string
+	Failed    []*DocumentProgress
+}
+
+// Error implements the error interface
+func (e *DocumentsProcessError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for _, item := range e.Failed {
+		if item.StatusDescript != "" {
+			names = append(names, fmt.Sprintf("%s(%s): %s", item.DocumentName, item.DocumentID, item.StatusDescript))
+		} else {
+			names = append(names, fmt.Sprintf("%s(%s)", item.DocumentName, item.DocumentID))
+		}
+	}
+	return fmt.Sprintf("%d documents of dataset %s failed to be processed: %s", len(e.Failed), e.DatasetID, strings.Join(names, ", "))
+}
@@ -0,0 +1,125 @@
+package coze
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetsDocumentsWaitProcessed(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	newWaitDocuments := func(statuses ...[]DocumentStatus) *datasetsDocuments {
+		polls := 0
+		return newDatasetsDocuments(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/open_api/knowledge/document/create":
+				return mockResponse(http.StatusOK, &createDatasetsDocumentsResp{
+					CreateDatasetsDocumentsResp: &CreateDatasetsDocumentsResp{DocumentInfos: []*Document{{DocumentID: "doc1"}, {DocumentID: "doc2"}}},
+				})
+			case "/v1/datasets/100/process":
+				current := statuses[polls]
+				if polls < len(statuses)-1 {
+					polls++
+				}
+				progress := []*DocumentProgress{}
+				for i, status := range current {
+					progress = append(progress, &DocumentProgress{
+						DocumentID:   []string{"doc1", "doc2"}[i],
+						DocumentName: []string{"a.md", "b.md"}[i],
+						Status:       status,
+						Progress:     int(status) * 100,
+					})
+				}
+				return mockResponse(http.StatusOK, &processDocumentsResp{Data: &ProcessDocumentsResp{Data: progress}})
+			default:
+				t.Fatalf("unexpected request path: %s", req.URL.Path)
+				return nil, nil
+			}
+		})))
+	}
+
+	t.Run("wait until processed", func(t *testing.T) {
+		documents := newWaitDocuments(
+			[]DocumentStatus{DocumentStatusProcessing, DocumentStatusProcessing},
+			[]DocumentStatus{DocumentStatusCompleted, DocumentStatusProcessing},
+			[]DocumentStatus{DocumentStatusCompleted, DocumentStatusCompleted},
+		)
+		var calls int
+		progress, err := documents.WaitProcessed(ctx, "100", []string{"doc1", "doc2"}, &WaitProcessedDocumentsOption{
+			PollInterval:    time.Millisecond,
+			MaxPollInterval: 2 * time.Millisecond,
+			OnProgress: func(progress []*DocumentProgress) {
+				calls++
+			},
+		})
+		as.Nil(err)
+		as.Equal(3, calls)
+		as.Len(progress, 2)
+		as.Equal(100, progress[1].Progress)
+	})
+
+	t.Run("missing documents are pending", func(t *testing.T) {
+		documents := newWaitDocuments(
+			[]DocumentStatus{},
+			[]DocumentStatus{DocumentStatusCompleted},
+			[]DocumentStatus{DocumentStatusCompleted, DocumentStatusCompleted},
+		)
+		var calls int
+		progress, err := documents.WaitProcessed(ctx, "100", []string{"doc1", "doc2"}, &WaitProcessedDocumentsOption{
+			PollInterval: time.Millisecond,
+			OnProgress: func(progress []*DocumentProgress) {
+				calls++
+			},
+		})
+		as.Nil(err)
+		as.Equal(3, calls)
+		as.Len(progress, 2)
+	})
+
+	t.Run("failed documents", func(t *testing.T) {
+		documents := newWaitDocuments(
+			[]DocumentStatus{DocumentStatusFailed, DocumentStatusProcessing},
+			[]DocumentStatus{DocumentStatusFailed, DocumentStatusCompleted},
+		)
+		progress, err := documents.WaitProcessed(ctx, "100", []string{"doc1", "doc2"}, &WaitProcessedDocumentsOption{PollInterval: time.Millisecond})
+		processErr := &DocumentsProcessError{}
+		as.ErrorAs(err, &processErr)
+		as.Len(processErr.Failed, 1)
+		as.Equal("doc1", processErr.Failed[0].DocumentID)
+		as.Equal(DocumentStatusCompleted, progress[1].Status)
+		as.Contains(err.Error(), "a.md(doc1)")
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		documents := newWaitDocuments(
+			[]DocumentStatus{DocumentStatusFailed, DocumentStatusProcessing},
+		)
+		progress, err := documents.WaitProcessed(ctx, "100", []string{"doc1", "doc2"}, &WaitProcessedDocumentsOption{FailFast: true})
+		as.NotNil(err)
+		as.Equal(DocumentStatusProcessing, progress[1].Status)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		documents := newWaitDocuments([]DocumentStatus{DocumentStatusProcessing})
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := documents.WaitProcessed(ctx, "100", []string{"doc1"}, &WaitProcessedDocumentsOption{PollInterval: time.Millisecond})
+		as.ErrorIs(err, context.DeadlineExceeded)
+	})
+
+	t.Run("create and wait", func(t *testing.T) {
+		documents := newWaitDocuments([]DocumentStatus{DocumentStatusCompleted, DocumentStatusCompleted})
+		resp, err := documents.CreateAndWait(ctx, &CreateDatasetsDocumentsReq{
+			DatasetID:     100,
+			DocumentBases: []*DocumentBase{DocumentBaseBuildLocalFile("a.md", "a", "md"), DocumentBaseBuildLocalFile("b.md", "b", "md")},
+		}, nil)
+		as.Nil(err)
+		as.Len(resp.DocumentInfos, 2)
+		as.Len(resp.Progress, 2)
+	})
+}
@@ -0,0 +1,439 @@
+package coze
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ImageCaptionFormat represents the file format of exported captions
+type ImageCaptionFormat string
+
+const (
+	// ImageCaptionFormatCSV A header row of document_id,name,caption followed by a row per image.
+	ImageCaptionFormatCSV ImageCaptionFormat = "csv"
+	// ImageCaptionFormatJSON An array of ImageCaption objects.
+	ImageCaptionFormatJSON ImageCaptionFormat = "json"
+)
+
+// ImageCaption represents the caption of an image of a dataset
+type ImageCaption struct {
+	DocumentID string `json:"document_id"`
+	Name       string `json:"name,omitempty"`
+	Caption    string `json:"caption"`
+}
+
+// ImageCaptioner generates the caption of an image
+type ImageCaptioner func(ctx context.Context, image *Image) (string, error)
+
+// ListAll returns the images of all the pages
+func (r *datasetsImages) ListAll(ctx context.Context, req *ListDatasetsImagesReq) ([]*Image, error) {
+	listReq := *req
+	if listReq.PageSize == 0 {
+		listReq.PageSize = 100
+	}
+	paged, err := r.List(ctx, &listReq)
+	if err != nil {
+		return nil, err
+	}
+	var images []*Image
+	for paged.Next() {
+		images = append(images, paged.Current())
+	}
+	return images, paged.Err()
+}
+
+// ExportCaptions writes the captions of all the images of a dataset to req.Writer
+func (r *datasetsImages) ExportCaptions(ctx context.Context, req *ExportDatasetImageCaptionsReq) error {
+	images, err := r.ListAll(ctx, &ListDatasetsImagesReq{DatasetID: req.DatasetID, HasCaption: req.HasCaption})
+	if err != nil {
+		return err
+	}
+	captions := make([]*ImageCaption, 0, len(images))
+	for _, image := range images {
+		captions = append(captions, &ImageCaption{DocumentID: image.DocumentID, Name: image.Name, Caption: image.Caption})
+	}
+	return WriteImageCaptions(req.Writer, captions, req.Format)
+}
+
+// ImportCaptions updates the captions of the images of a dataset, only the captions different from
+// the current ones are updated. A document ID repeated in req.Captions fails except its first caption.
+//
+// The returned error is only set if the current captions could not be listed, the failures of the
+// images are reported by the results.
+func (r *datasetsImages) ImportCaptions(ctx context.Context, req *ImportDatasetImageCaptionsReq) (*DatasetImageCaptionsResp, error) {
+	images, err := r.ListAll(ctx, &ListDatasetsImagesReq{DatasetID: req.DatasetID})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Image, len(images))
+	for _, image := range images {
+		byID[image.DocumentID] = image
+	}
+
+	resp := &DatasetImageCaptionsResp{DryRun: req.DryRun}
+	var updates []*DatasetImageCaptionResult
+	seen := map[string]bool{}
+	for _, caption := range req.Captions {
+		result := &DatasetImageCaptionResult{DocumentID: caption.DocumentID, Name: caption.Name, Caption: caption.Caption}
+		resp.Results = append(resp.Results, result)
+		// the updates run concurrently, so the caption set by a duplicate would be random
+		if seen[caption.DocumentID] {
+			result.Err = fmt.Errorf("image %s is duplicated, only its first caption is imported", caption.DocumentID)
+			continue
+		}
+		seen[caption.DocumentID] = true
+		image, ok := byID[caption.DocumentID]
+		if !ok {
+			result.Err = fmt.Errorf("image %s is not found in dataset %s", caption.DocumentID, req.DatasetID)
+			continue
+		}
+		result.Name, result.OldCaption = image.Name, image.Caption
+		if image.Caption != caption.Caption {
+			updates = append(updates, result)
+		}
+	}
+	if !req.DryRun {
+		r.updateCaptions(ctx, req.DatasetID, updates, req.Concurrency)
+	} else {
+		for _, result := range updates {
+			result.Updated = true
+		}
+	}
+	return resp, nil
+}
+
+// GenerateCaptions generates the captions of the images without a caption by req.Captioner, see
+// NewBotImageCaptioner.
+//
+// The returned error is only set if the images could not be listed, the failures of the images are
+// reported by the results.
+func (r *datasetsImages) GenerateCaptions(ctx context.Context, req *GenerateDatasetImageCaptionsReq) (*DatasetImageCaptionsResp, error) {
+	if req.Captioner == nil {
+		return nil, errors.New("captioner is required")
+	}
+	listReq := &ListDatasetsImagesReq{DatasetID: req.DatasetID}
+	if !req.Overwrite {
+		listReq.HasCaption = ptr(false)
+	}
+	images, err := r.ListAll(ctx, listReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &DatasetImageCaptionsResp{DryRun: req.DryRun}
+	for _, image := range images {
+		// the filter of the server is not trusted, an image with a caption is kept unless overwritten
+		if image.Caption != "" && !req.Overwrite {
+			continue
+		}
+		resp.Results = append(resp.Results, &DatasetImageCaptionResult{
+			DocumentID: image.DocumentID,
+			Name:       image.Name,
+			OldCaption: image.Caption,
+			image:      image,
+		})
+	}
+
+	err = runConcurrently(ctx, len(resp.Results), captionConcurrency(req.Concurrency), func(ctx context.Context, i int) error {
+		result := resp.Results[i]
+		caption, err := req.Captioner(ctx, result.image)
+		if err != nil {
+			result.Err = err
+			return nil
+		}
+		result.Caption = strings.TrimSpace(caption)
+		if result.Caption == "" {
+			result.Err = errors.New("empty caption is generated")
+		}
+		return nil
+	})
+	if err != nil {
+		// the images not captioned as ctx is done
+		for _, result := range resp.Results {
+			if result.Caption == "" && result.Err == nil {
+				result.Err = err
+			}
+		}
+	}
+
+	var updates []*DatasetImageCaptionResult
+	for _, result := range resp.Results {
+		if result.Err == nil && result.Caption != result.OldCaption {
+			updates = append(updates, result)
+		}
+	}
+	if !req.DryRun {
+		r.updateCaptions(ctx, req.DatasetID, updates, req.Concurrency)
+	} else {
+		for _, result := range updates {
+			result.Updated = true
+		}
+	}
+	return resp, nil
+}
+
+// ExportDatasetImageCaptionsReq represents request for exporting the captions of a dataset
+type ExportDatasetImageCaptionsReq struct {
+	// The ID of the dataset.
+	DatasetID string
+
+	// The destination of the captions.
+	Writer io.Writer
+
+	// Optional: The format of the captions, default is csv.
+	Format ImageCaptionFormat
+
+	// Optional: Only export the images with or without a caption.
+	HasCaption *bool
+}
+
+// ImportDatasetImageCaptionsReq represents request for importing the captions of a dataset
+type ImportDatasetImageCaptionsReq struct {
+	// The ID of the dataset.
+	DatasetID string
+
+	// The captions to set, see ReadImageCaptions.
+	Captions []*ImageCaption
+
+	// Optional: The max number of concurrent updates, default is 5.
+	Concurrency int
+
+	// Optional: Only compute the changed captions, the dataset is not modified.
+	DryRun bool
+}
+
+// GenerateDatasetImageCaptionsReq represents request for generating the captions of a dataset
+type GenerateDatasetImageCaptionsReq struct {
+	// The ID of the dataset.
+	DatasetID string
+
+	// Generates the caption of an image.
+	Captioner ImageCaptioner
+
+	// Optional: Also regenerate the images with a caption.
+	Overwrite bool
+
+	// Optional: The max number of concurrent generations and updates, default is 5.
+	Concurrency int
+
+	// Optional: Only generate the captions, the dataset is not modified.
+	DryRun bool
+}
+
+// DatasetImageCaptionsResp represents response for importing or generating captions
+type DatasetImageCaptionsResp struct {
+	DryRun  bool
+	Results []*DatasetImageCaptionResult
+}
+
+// Updated returns the results of the updated captions, or the captions to update in a dry run.
+func (r *DatasetImageCaptionsResp) Updated() []*DatasetImageCaptionResult {
+	var res []*DatasetImageCaptionResult
+	for _, result := range r.Results {
+		if result.Updated {
+			res = append(res, result)
+		}
+	}
+	return res
+}
+
+// Failed returns the results of the images failed to be updated
+func (r *DatasetImageCaptionsResp) Failed() []*DatasetImageCaptionResult {
+	var res []*DatasetImageCaptionResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			res = append(res, result)
+		}
+	}
+	return res
+}
+
+// DatasetImageCaptionResult represents the result of the caption of a single image
+type DatasetImageCaptionResult struct {
+	DocumentID string
+	Name       string
+
+	// The caption before the update.
+	OldCaption string
+	Caption    string
+
+	// Whether the caption is updated, false if unchanged or failed.
+	Updated bool
+	Err     error
+
+	image *Image
+}
+
+// WriteImageCaptions writes captions in format, default is csv
+func WriteImageCaptions(w io.Writer, captions []*ImageCaption, format ImageCaptionFormat) error {
+	switch format {
+	case ImageCaptionFormatCSV, "":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"document_id", "name", "caption"}); err != nil {
+			return err
+		}
+		for _, caption := range captions {
+			if err := writer.Write([]string{caption.DocumentID, caption.Name, caption.Caption}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case ImageCaptionFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if captions == nil {
+			captions = []*ImageCaption{}
+		}
+		return encoder.Encode(captions)
+	default:
+		return fmt.Errorf("unknown image caption format %q", format)
+	}
+}
+
+// ReadImageCaptions reads captions written by WriteImageCaptions, the columns of csv are matched by
+// the header row and the name column is optional.
+func ReadImageCaptions(r io.Reader, format ImageCaptionFormat) ([]*ImageCaption, error) {
+	switch format {
+	case ImageCaptionFormatCSV, "":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("read csv header: %w", err)
+		}
+		columns := map[string]int{}
+		for i, name := range header {
+			columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+		}
+		idColumn, ok := columns["document_id"]
+		if !ok {
+			return nil, errors.New("document_id column is required")
+		}
+		captionColumn, ok := columns["caption"]
+		if !ok {
+			return nil, errors.New("caption column is required")
+		}
+		nameColumn, hasName := columns["name"]
+
+		var captions []*ImageCaption
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return captions, nil
+			} else if err != nil {
+				return nil, err
+			}
+			line, _ := reader.FieldPos(0)
+			if idColumn >= len(record) || captionColumn >= len(record) {
+				return nil, fmt.Errorf("line %d: missing columns", line)
+			}
+			caption := &ImageCaption{DocumentID: record[idColumn], Caption: record[captionColumn]}
+			if hasName && nameColumn < len(record) {
+				caption.Name = record[nameColumn]
+			}
+			captions = append(captions, caption)
+		}
+	case ImageCaptionFormatJSON:
+		var captions []*ImageCaption
+		if err := json.NewDecoder(r).Decode(&captions); err != nil {
+			return nil, err
+		}
+		return captions, nil
+	default:
+		return nil, fmt.Errorf("unknown image caption format %q", format)
+	}
+}
+
+// BotImageCaptionerOption represents the options of NewBotImageCaptioner
+type BotImageCaptionerOption struct {
+	// The ID of the bot generating captions, the bot should support image input.
+	BotID string
+
+	// The ID of the user chatting with the bot.
+	UserID string
+
+	// Optional: The question sent with the image, default asks for a short description.
+	Prompt string
+
+	// Returns the image sent to the bot, such as NewImageMessageObjectByURL of a link to the image.
+	ImageObject func(image *Image) (*MessageObjectString, error)
+
+	// Optional: The timeout of a chat in seconds.
+	Timeout *int
+}
+
+const defaultImageCaptionPrompt = "Describe this image in one or two sentences for search, reply with the description only."
+
+// NewBotImageCaptioner creates a captioner asking a bot to describe the images, the answer of the bot
+// is the caption.
+func NewBotImageCaptioner(api *CozeAPI, opt *BotImageCaptionerOption) ImageCaptioner {
+	prompt := opt.Prompt
+	if prompt == "" {
+		prompt = defaultImageCaptionPrompt
+	}
+	return func(ctx context.Context, image *Image) (string, error) {
+		if opt.ImageObject == nil {
+			return "", errors.New("image object is required")
+		}
+		object, err := opt.ImageObject(image)
+		if err != nil {
+			return "", err
+		}
+		poll, err := api.Chat.CreateAndPoll(ctx, &CreateChatsReq{
+			BotID:  opt.BotID,
+			UserID: opt.UserID,
+			Messages: []*Message{
+				BuildUserQuestionObjects([]*MessageObjectString{NewTextMessageObject(prompt), object}, nil),
+			},
+		}, opt.Timeout)
+		if err != nil {
+			return "", err
+		}
+		if poll.Chat.Status != ChatStatusCompleted {
+			return "", fmt.Errorf("chat %s is %s", poll.Chat.ID, poll.Chat.Status)
+		}
+		for _, message := range poll.Messages {
+			if message.Type == MessageTypeAnswer {
+				return message.Content, nil
+			}
+		}
+		return "", errors.New("bot returned no answer")
+	}
+}
+
+func (r *datasetsImages) updateCaptions(ctx context.Context, datasetID string, results []*DatasetImageCaptionResult, concurrency int) {
+	err := runConcurrently(ctx, len(results), captionConcurrency(concurrency), func(ctx context.Context, i int) error {
+		result := results[i]
+		if _, err := r.Update(ctx, &UpdateDatasetImageReq{
+			DatasetID:  datasetID,
+			DocumentID: result.DocumentID,
+			Caption:    ptr(result.Caption),
+		}); err != nil {
+			result.Err = err
+			return nil
+		}
+		result.Updated = true
+		return nil
+	})
+	if err != nil {
+		// the captions not updated as ctx is done
+		for _, result := range results {
+			if !result.Updated && result.Err == nil {
+				result.Err = err
+			}
+		}
+	}
+}
+
+func captionConcurrency(concurrency int) int {
+	if concurrency <= 0 {
+		return 5
+	}
+	return concurrency
+}
@@ -0,0 +1,179 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetsImagesCaptions(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var updated map[string]string
+	newCaptionImages := func() *datasetsImages {
+		updated = map[string]string{}
+		return newDatasetsImages(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			switch {
+			case req.Method == http.MethodGet && req.URL.Path == "/v1/datasets/100/images":
+				images := []*Image{
+					{DocumentID: "1", Name: "cat.png", Caption: "a cat"},
+					{DocumentID: "2", Name: "dog.png"},
+					{DocumentID: "3", Name: "bird, blue.png", Caption: "a \"blue\" bird"},
+				}
+				if req.URL.Query().Get("has_caption") == "false" {
+					images = images[1:2]
+				}
+				return mockResponse(http.StatusOK, &listImagesResp{Data: &ListImagesResp{ImagesInfos: images, TotalCount: len(images)}})
+			case req.Method == http.MethodPut && strings.HasPrefix(req.URL.Path, "/v1/datasets/100/images/"):
+				body := &UpdateDatasetImageReq{}
+				data, _ := io.ReadAll(req.Body)
+				as.Nil(json.Unmarshal(data, body))
+				id := strings.TrimPrefix(req.URL.Path, "/v1/datasets/100/images/")
+				if id == "3" {
+					return mockResponse(http.StatusOK, &updateImageResp{baseResponse: baseResponse{Code: 4000, Msg: "failed"}})
+				}
+				mu.Lock()
+				updated[id] = *body.Caption
+				mu.Unlock()
+				return mockResponse(http.StatusOK, &updateImageResp{})
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL)
+				return nil, nil
+			}
+		})))
+	}
+
+	for _, format := range []ImageCaptionFormat{ImageCaptionFormatCSV, ImageCaptionFormatJSON} {
+		t.Run("export and read "+string(format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			as.Nil(newCaptionImages().ExportCaptions(ctx, &ExportDatasetImageCaptionsReq{DatasetID: "100", Writer: buf, Format: format}))
+			captions, err := ReadImageCaptions(buf, format)
+			as.Nil(err)
+			as.Equal([]*ImageCaption{
+				{DocumentID: "1", Name: "cat.png", Caption: "a cat"},
+				{DocumentID: "2", Name: "dog.png"},
+				{DocumentID: "3", Name: "bird, blue.png", Caption: "a \"blue\" bird"},
+			}, captions)
+		})
+	}
+
+	t.Run("read csv by header", func(t *testing.T) {
+		captions, err := ReadImageCaptions(strings.NewReader("caption,document_id\nhello,1\n"), ImageCaptionFormatCSV)
+		as.Nil(err)
+		as.Equal([]*ImageCaption{{DocumentID: "1", Caption: "hello"}}, captions)
+
+		_, err = ReadImageCaptions(strings.NewReader("name,caption\n"), ImageCaptionFormatCSV)
+		as.NotNil(err)
+	})
+
+	t.Run("import only changed captions", func(t *testing.T) {
+		images := newCaptionImages()
+		captions := []*ImageCaption{
+			{DocumentID: "1", Caption: "a cat"},
+			{DocumentID: "2", Caption: "a dog"},
+			{DocumentID: "3", Caption: "a bird"},
+			{DocumentID: "4", Caption: "missing"},
+		}
+		resp, err := images.ImportCaptions(ctx, &ImportDatasetImageCaptionsReq{DatasetID: "100", Captions: captions, DryRun: true})
+		as.Nil(err)
+		as.Len(resp.Updated(), 2)
+		as.Empty(updated)
+
+		resp, err = images.ImportCaptions(ctx, &ImportDatasetImageCaptionsReq{DatasetID: "100", Captions: captions, Concurrency: 2})
+		as.Nil(err)
+		as.Equal(map[string]string{"2": "a dog"}, updated)
+		as.Len(resp.Updated(), 1)
+		as.Equal("dog.png", resp.Updated()[0].Name)
+		as.Len(resp.Failed(), 2)
+		as.False(resp.Results[0].Updated)
+		as.Nil(resp.Results[0].Err)
+	})
+
+	t.Run("import rejects duplicated images", func(t *testing.T) {
+		images := newCaptionImages()
+		resp, err := images.ImportCaptions(ctx, &ImportDatasetImageCaptionsReq{DatasetID: "100", Captions: []*ImageCaption{
+			{DocumentID: "2", Caption: "a dog"},
+			{DocumentID: "2", Caption: "a puppy"},
+		}})
+		as.Nil(err)
+		as.Equal(map[string]string{"2": "a dog"}, updated)
+		as.True(resp.Results[0].Updated)
+		as.False(resp.Results[1].Updated)
+		as.NotNil(resp.Results[1].Err)
+	})
+
+	t.Run("generate missing captions", func(t *testing.T) {
+		images := newCaptionImages()
+		resp, err := images.GenerateCaptions(ctx, &GenerateDatasetImageCaptionsReq{
+			DatasetID: "100",
+			Captioner: func(ctx context.Context, image *Image) (string, error) {
+				return " generated " + image.Name + "\n", nil
+			},
+		})
+		as.Nil(err)
+		as.Equal(map[string]string{"2": "generated dog.png"}, updated)
+		as.Len(resp.Results, 1)
+
+		resp, err = images.GenerateCaptions(ctx, &GenerateDatasetImageCaptionsReq{
+			DatasetID: "100",
+			Overwrite: true,
+			DryRun:    true,
+			Captioner: func(ctx context.Context, image *Image) (string, error) {
+				if image.DocumentID == "1" {
+					return "", errors.New("captioner failed")
+				}
+				return "new", nil
+			},
+		})
+		as.Nil(err)
+		as.Len(resp.Updated(), 2)
+		as.Len(resp.Failed(), 1)
+
+		_, err = images.GenerateCaptions(ctx, &GenerateDatasetImageCaptionsReq{DatasetID: "100"})
+		as.NotNil(err)
+	})
+
+	t.Run("bot captioner", func(t *testing.T) {
+		core := newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/v3/chat":
+				body := &CreateChatsReq{}
+				data, _ := io.ReadAll(req.Body)
+				as.Nil(json.Unmarshal(data, body))
+				as.Equal("bot", body.BotID)
+				as.Contains(body.Messages[0].Content, "https://example.com/dog.png")
+				return mockResponse(http.StatusOK, &createChatsResp{Chat: &CreateChatsResp{Chat: Chat{ID: "chat", ConversationID: "conv"}}})
+			case "/v3/chat/retrieve":
+				return mockResponse(http.StatusOK, &retrieveChatsResp{Chat: &RetrieveChatsResp{Chat: Chat{ID: "chat", ConversationID: "conv", Status: ChatStatusCompleted}}})
+			case "/v3/chat/message/list":
+				return mockResponse(http.StatusOK, &listChatsMessagesResp{ListChatsMessagesResp: &ListChatsMessagesResp{Messages: []*Message{
+					{Type: MessageTypeFollowUp, Content: "more?"},
+					{Type: MessageTypeAnswer, Content: "a dog"},
+				}}})
+			default:
+				t.Fatalf("unexpected request: %s", req.URL)
+				return nil, nil
+			}
+		}))
+		captioner := NewBotImageCaptioner(&CozeAPI{Chat: newChats(core)}, &BotImageCaptionerOption{
+			BotID:  "bot",
+			UserID: "user",
+			ImageObject: func(image *Image) (*MessageObjectString, error) {
+				return NewImageMessageObjectByURL("https://example.com/" + image.Name), nil
+			},
+		})
+		caption, err := captioner(ctx, &Image{DocumentID: "2", Name: "dog.png"})
+		as.Nil(err)
+		as.Equal("a dog", caption)
+	})
+}
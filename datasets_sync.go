@@ -0,0 +1,419 @@
+package coze
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sync makes the documents of a dataset match the files of req.FS: new files are uploaded, changed
+// files are replaced and documents without a file are deleted. Documents are matched to files by
+// name, which is the slash separated path of the file in req.FS.
+//
+// Whether a file is changed is decided by its SHA-256 recorded in req.Manifest, or by its size when
+// the file is not in the manifest. A document matched by size only is unverified, it's not recorded
+// in the manifest, so the following syncs don't take it as verified. Set req.ReplaceUnverified to
+// replace it instead, which records its hash.
+func (r *datasets) Sync(ctx context.Context, req *SyncDatasetsReq) (*SyncDatasetsResp, error) {
+	datasetID, err := strconv.ParseInt(req.DatasetID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dataset id %q: %w", req.DatasetID, err)
+	}
+
+	locals, err := req.scan()
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := r.listAllDocuments(ctx, datasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SyncDatasetsResp{DryRun: req.DryRun}
+	resp.Actions = req.plan(locals, remotes)
+	if req.DryRun {
+		return resp, nil
+	}
+
+	created, err := r.applySyncActions(ctx, datasetID, req, resp.Actions)
+	// the actions applied before a failure are recorded too, so the next sync doesn't redo them
+	if req.Manifest != nil {
+		req.Manifest.update(resp.Actions, locals)
+	}
+	if err != nil {
+		return resp, err
+	}
+	if req.Wait && len(created) > 0 {
+		resp.Progress, err = r.Documents.WaitProcessed(ctx, req.DatasetID, created, req.WaitOption)
+		if err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// SyncDatasetsReq represents request for synchronizing a dataset with local files
+type SyncDatasetsReq struct {
+	// The ID of the dataset.
+	DatasetID string
+
+	// The files to synchronize, use os.DirFS for a local directory.
+	FS fs.FS
+
+	// Optional: Glob patterns matched against the base name of the files, such as "*.md", all the
+	// files are synchronized if empty. Hidden files and directories are always skipped.
+	Patterns []string
+
+	// Optional: The chunk strategy of the created documents.
+	ChunkStrategy *DocumentChunkStrategy
+
+	// Optional: The content hashes of the files synchronized before, it is updated by Sync.
+	Manifest *DatasetSyncManifest
+
+	// Optional: Keep the documents without a file instead of deleting them.
+	KeepRemoved bool
+
+	// Optional: Replace the documents not in the manifest instead of comparing their sizes, a file
+	// edited to the same size is not detected by the size.
+	ReplaceUnverified bool
+
+	// Optional: Only compute the actions, the dataset is not modified.
+	DryRun bool
+
+	// Optional: Wait until the created documents are processed.
+	Wait bool
+
+	// Optional: The options of waiting, see Documents.WaitProcessed.
+	WaitOption *WaitProcessedDocumentsOption
+}
+
+// SyncDatasetsResp represents response for synchronizing a dataset
+type SyncDatasetsResp struct {
+	DryRun  bool
+	Actions []*DatasetSyncAction
+
+	// The processing progress of the created documents, only set if SyncDatasetsReq.Wait is true.
+	Progress []*DocumentProgress
+}
+
+// DatasetSyncActionType represents what Sync does to a document
+type DatasetSyncActionType string
+
+const (
+	DatasetSyncActionCreate    DatasetSyncActionType = "create"
+	DatasetSyncActionReplace   DatasetSyncActionType = "replace"
+	DatasetSyncActionDelete    DatasetSyncActionType = "delete"
+	DatasetSyncActionUnchanged DatasetSyncActionType = "unchanged"
+)
+
+// DatasetSyncAction represents the action on a single document
+type DatasetSyncAction struct {
+	Type DatasetSyncActionType
+	Name string
+
+	// The ID of the existing document, empty for created documents.
+	DocumentID string
+
+	// The ID of the uploaded document, only set after created or replaced documents are uploaded.
+	NewDocumentID string
+
+	// Whether an unchanged document is matched by its size only, as it's not in the manifest.
+	Unverified bool
+
+	// Whether the document is uploaded or deleted, a replaced document is applied once uploaded.
+	applied bool
+}
+
+// String returns a one line description of the action, such as "+ docs/intro.md".
+func (a *DatasetSyncAction) String() string {
+	switch a.Type {
+	case DatasetSyncActionCreate:
+		return "+ " + a.Name
+	case DatasetSyncActionReplace:
+		return "~ " + a.Name
+	case DatasetSyncActionDelete:
+		return "- " + a.Name
+	default:
+		return "  " + a.Name
+	}
+}
+
+// Changed returns the actions modifying the dataset
+func (r *SyncDatasetsResp) Changed() []*DatasetSyncAction {
+	var res []*DatasetSyncAction
+	for _, action := range r.Actions {
+		if action.Type != DatasetSyncActionUnchanged {
+			res = append(res, action)
+		}
+	}
+	return res
+}
+
+// String returns the changed actions, one per line
+func (r *SyncDatasetsResp) String() string {
+	lines := []string{}
+	for _, action := range r.Changed() {
+		lines = append(lines, action.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DatasetSyncManifest records the content of the documents uploaded by Sync
+type DatasetSyncManifest struct {
+	Documents map[string]*DatasetSyncManifestEntry `json:"documents"`
+}
+
+// DatasetSyncManifestEntry represents a document uploaded by Sync
+type DatasetSyncManifestEntry struct {
+	DocumentID string `json:"document_id"`
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+}
+
+// LoadDatasetSyncManifest reads a manifest saved by DatasetSyncManifest.Save, a missing file is an
+// empty manifest.
+func LoadDatasetSyncManifest(filePath string) (*DatasetSyncManifest, error) {
+	manifest := &DatasetSyncManifest{Documents: map[string]*DatasetSyncManifestEntry{}}
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Documents == nil {
+		manifest.Documents = map[string]*DatasetSyncManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// Save writes the manifest as JSON
+func (m *DatasetSyncManifest) Save(filePath string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0o644)
+}
+
+func (m *DatasetSyncManifest) update(actions []*DatasetSyncAction, locals map[string]*datasetSyncFile) {
+	if m.Documents == nil {
+		m.Documents = map[string]*DatasetSyncManifestEntry{}
+	}
+	for _, action := range actions {
+		if action.Type != DatasetSyncActionUnchanged && !action.applied {
+			continue
+		}
+		switch action.Type {
+		case DatasetSyncActionDelete:
+			if entry := m.Documents[action.Name]; entry != nil && entry.DocumentID == action.DocumentID {
+				delete(m.Documents, action.Name)
+			}
+		case DatasetSyncActionUnchanged:
+			// the hash of the local file is not the one of the document matched by size only
+			if action.Unverified {
+				continue
+			}
+			local := locals[action.Name]
+			m.Documents[action.Name] = &DatasetSyncManifestEntry{DocumentID: action.DocumentID, SHA256: local.hash, Size: local.size}
+		default:
+			local := locals[action.Name]
+			m.Documents[action.Name] = &DatasetSyncManifestEntry{DocumentID: action.NewDocumentID, SHA256: local.hash, Size: local.size}
+		}
+	}
+}
+
+func (m *DatasetSyncManifest) get(name string) *DatasetSyncManifestEntry {
+	if m == nil {
+		return nil
+	}
+	return m.Documents[name]
+}
+
+type datasetSyncFile struct {
+	name string
+	hash string
+	size int64
+}
+
+func (req *SyncDatasetsReq) scan() (map[string]*datasetSyncFile, error) {
+	files := map[string]*datasetSyncFile{}
+	err := fs.WalkDir(req.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !req.match(d.Name()) {
+			return nil
+		}
+		f, err := req.FS.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		size, err := io.Copy(h, f)
+		if err != nil {
+			return err
+		}
+		files[name] = &datasetSyncFile{
+			name: name,
+			hash: hex.EncodeToString(h.Sum(nil)),
+			size: size,
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (req *SyncDatasetsReq) match(name string) bool {
+	if len(req.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range req.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// plan returns the actions sorted by name, duplicated documents of the same name are deleted.
+func (req *SyncDatasetsReq) plan(locals map[string]*datasetSyncFile, remotes []*Document) []*DatasetSyncAction {
+	var actions []*DatasetSyncAction
+	matched := map[string]bool{}
+	for _, doc := range remotes {
+		local, ok := locals[doc.Name]
+		if !ok || matched[doc.Name] {
+			if !req.KeepRemoved || ok {
+				actions = append(actions, &DatasetSyncAction{Type: DatasetSyncActionDelete, Name: doc.Name, DocumentID: doc.DocumentID})
+			}
+			continue
+		}
+		matched[doc.Name] = true
+		action := &DatasetSyncAction{Type: DatasetSyncActionUnchanged, Name: doc.Name, DocumentID: doc.DocumentID}
+		if entry := req.Manifest.get(doc.Name); entry != nil && entry.DocumentID == doc.DocumentID {
+			if entry.SHA256 != local.hash {
+				action.Type = DatasetSyncActionReplace
+			}
+		} else if req.ReplaceUnverified || int64(doc.Size) != local.size {
+			action.Type = DatasetSyncActionReplace
+		} else {
+			action.Unverified = true
+		}
+		actions = append(actions, action)
+	}
+	for name := range locals {
+		if !matched[name] {
+			actions = append(actions, &DatasetSyncAction{Type: DatasetSyncActionCreate, Name: name})
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Name < actions[j].Name
+	})
+	return actions
+}
+
+// syncDeleteBatchSize is the max number of documents deleted by a single request of Sync
+var syncDeleteBatchSize = 100
+
+// applySyncActions uploads the created and replaced documents before deleting the replaced and
+// removed ones, it returns the IDs of the uploaded documents.
+func (r *datasets) applySyncActions(ctx context.Context, datasetID int64, req *SyncDatasetsReq, actions []*DatasetSyncAction) ([]string, error) {
+	var uploads []*DatasetSyncAction
+	var sources []*DocumentImportSource
+	var deletes []int64
+	var deleteActions []*DatasetSyncAction
+	for _, action := range actions {
+		switch action.Type {
+		case DatasetSyncActionCreate, DatasetSyncActionReplace:
+			source, err := NewDocumentImportSourceFromFS(req.FS, action.Name)
+			if err != nil {
+				return nil, err
+			}
+			uploads = append(uploads, action)
+			sources = append(sources, source)
+		}
+		switch action.Type {
+		case DatasetSyncActionReplace, DatasetSyncActionDelete:
+			id, err := strconv.ParseInt(action.DocumentID, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid document id %q: %w", action.DocumentID, err)
+			}
+			deletes = append(deletes, id)
+			deleteActions = append(deleteActions, action)
+		}
+	}
+
+	var created []string
+	if len(sources) > 0 {
+		resp, err := r.Documents.Import(ctx, &ImportDatasetsDocumentsReq{
+			DatasetID:     datasetID,
+			Sources:       sources,
+			ChunkStrategy: req.ChunkStrategy,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var uploadErr error
+		for i, result := range resp.Results {
+			if result.Err != nil {
+				if uploadErr == nil {
+					uploadErr = fmt.Errorf("upload %s: %w", result.Name, result.Err)
+				}
+				continue
+			}
+			uploads[i].NewDocumentID = result.Document.DocumentID
+			uploads[i].applied = true
+			created = append(created, result.Document.DocumentID)
+		}
+		// nothing is deleted if an upload failed, so no document is lost
+		if uploadErr != nil {
+			return created, uploadErr
+		}
+	}
+
+	for start := 0; start < len(deletes); start += syncDeleteBatchSize {
+		end := start + syncDeleteBatchSize
+		if end > len(deletes) {
+			end = len(deletes)
+		}
+		if _, err := r.Documents.Delete(ctx, &DeleteDatasetsDocumentsReq{DocumentIDs: deletes[start:end]}); err != nil {
+			return created, err
+		}
+		for _, action := range deleteActions[start:end] {
+			if action.Type == DatasetSyncActionDelete {
+				action.applied = true
+			}
+		}
+	}
+	return created, nil
+}
+
+func (r *datasets) listAllDocuments(ctx context.Context, datasetID int64) ([]*Document, error) {
+	paged, err := r.Documents.List(ctx, &ListDatasetsDocumentsReq{DatasetID: datasetID, Size: 100})
+	if err != nil {
+		return nil, err
+	}
+	var docs []*Document
+	for paged.Next() {
+		docs = append(docs, paged.Current())
+	}
+	return docs, paged.Err()
+}
@@ -0,0 +1,207 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetsSync(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	files := fstest.MapFS{
+		"intro.md":         {Data: []byte("hello")},
+		"guides/setup.md":  {Data: []byte("changed")},
+		"guides/same.md":   {Data: []byte("same")},
+		"guides/image.png": {Data: []byte("png")},
+		".git/config":      {Data: []byte("git")},
+	}
+	var created []*DocumentBase
+	var deleted []int64
+	var processed []string
+	var deleteBatches int
+	var failDelete int64
+	newSyncDatasets := func() *datasets {
+		created, deleted, processed = nil, nil, nil
+		deleteBatches, failDelete = 0, 0
+		return newDatasets(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			switch req.URL.Path {
+			case "/open_api/knowledge/document/list":
+				return mockResponse(http.StatusOK, &listDatasetsDocumentsResp{
+					ListDatasetsDocumentsResp: &ListDatasetsDocumentsResp{
+						Total: 3,
+						DocumentInfos: []*Document{
+							{DocumentID: "1", Name: "guides/setup.md", Size: 3},
+							{DocumentID: "2", Name: "guides/same.md", Size: 4},
+							{DocumentID: "3", Name: "removed.md", Size: 4},
+						},
+					},
+				})
+			case "/open_api/knowledge/document/create":
+				reqBody := &CreateDatasetsDocumentsReq{}
+				as.Nil(json.Unmarshal(body, reqBody))
+				as.Equal(int64(100), reqBody.DatasetID)
+				created = append(created, reqBody.DocumentBases...)
+				docs := []*Document{}
+				for i := range reqBody.DocumentBases {
+					docs = append(docs, &Document{DocumentID: "new" + reqBody.DocumentBases[i].Name})
+				}
+				return mockResponse(http.StatusOK, &createDatasetsDocumentsResp{
+					CreateDatasetsDocumentsResp: &CreateDatasetsDocumentsResp{DocumentInfos: docs},
+				})
+			case "/open_api/knowledge/document/delete":
+				reqBody := &DeleteDatasetsDocumentsReq{}
+				as.Nil(json.Unmarshal(body, reqBody))
+				deleteBatches++
+				for _, id := range reqBody.DocumentIDs {
+					if id == failDelete {
+						return mockResponse(http.StatusOK, &deleteDatasetsDocumentsResp{baseResponse: baseResponse{Code: 1, Msg: "delete failed"}})
+					}
+				}
+				deleted = append(deleted, reqBody.DocumentIDs...)
+				return mockResponse(http.StatusOK, &deleteDatasetsDocumentsResp{})
+			case "/v1/datasets/100/process":
+				reqBody := &ProcessDocumentsReq{}
+				as.Nil(json.Unmarshal(body, reqBody))
+				processed = reqBody.DocumentIDs
+				progress := []*DocumentProgress{}
+				for _, id := range reqBody.DocumentIDs {
+					progress = append(progress, &DocumentProgress{DocumentID: id, Status: DocumentStatusCompleted})
+				}
+				return mockResponse(http.StatusOK, &processDocumentsResp{Data: &ProcessDocumentsResp{Data: progress}})
+			default:
+				t.Fatalf("unexpected request path: %s", req.URL.Path)
+				return nil, nil
+			}
+		})))
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		datasets := newSyncDatasets()
+		resp, err := datasets.Sync(ctx, &SyncDatasetsReq{
+			DatasetID: "100",
+			FS:        files,
+			Patterns:  []string{"*.md"},
+			DryRun:    true,
+		})
+		as.Nil(err)
+		as.Equal("~ guides/setup.md\n+ intro.md\n- removed.md", resp.String())
+		as.Len(resp.Actions, 4)
+		as.Empty(created)
+		as.Empty(deleted)
+	})
+
+	t.Run("sync and wait", func(t *testing.T) {
+		datasets := newSyncDatasets()
+		manifest := &DatasetSyncManifest{}
+		resp, err := datasets.Sync(ctx, &SyncDatasetsReq{
+			DatasetID: "100",
+			FS:        files,
+			Patterns:  []string{"*.md"},
+			Manifest:  manifest,
+			Wait:      true,
+		})
+		as.Nil(err)
+		as.Len(created, 2)
+		as.Equal("guides/setup.md", created[0].Name)
+		as.Equal("md", *created[0].SourceInfo.FileType)
+		as.Equal("intro.md", created[1].Name)
+		as.ElementsMatch([]int64{1, 3}, deleted)
+		as.Equal([]string{"newguides/setup.md", "newintro.md"}, processed)
+		as.Len(resp.Progress, 2)
+
+		as.Equal("newintro.md", manifest.Documents["intro.md"].DocumentID)
+		// same.md is matched by size only, its hash is not recorded
+		as.NotContains(manifest.Documents, "guides/same.md")
+		as.NotContains(manifest.Documents, "removed.md")
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		as.Nil(manifest.Save(path))
+		loaded, err := LoadDatasetSyncManifest(path)
+		as.Nil(err)
+		as.Equal(manifest, loaded)
+	})
+
+	t.Run("manifest detects same size changes", func(t *testing.T) {
+		datasets := newSyncDatasets()
+		manifest := &DatasetSyncManifest{Documents: map[string]*DatasetSyncManifestEntry{
+			"guides/same.md": {DocumentID: "2", SHA256: "outdated", Size: 4},
+		}}
+		resp, err := datasets.Sync(ctx, &SyncDatasetsReq{
+			DatasetID:   "100",
+			FS:          files,
+			Patterns:    []string{"same.md"},
+			Manifest:    manifest,
+			KeepRemoved: true,
+			DryRun:      true,
+		})
+		as.Nil(err)
+		as.Equal("~ guides/same.md", resp.String())
+	})
+
+	t.Run("same size edit is not verified by size", func(t *testing.T) {
+		// same.md is edited to another content of the same size before the first sync
+		datasets := newSyncDatasets()
+		manifest := &DatasetSyncManifest{}
+		req := &SyncDatasetsReq{
+			DatasetID:   "100",
+			FS:          files,
+			Patterns:    []string{"same.md"},
+			Manifest:    manifest,
+			KeepRemoved: true,
+		}
+		resp, err := datasets.Sync(ctx, req)
+		as.Nil(err)
+		as.Equal(DatasetSyncActionUnchanged, resp.Actions[0].Type)
+		as.True(resp.Actions[0].Unverified)
+		as.Empty(manifest.Documents)
+
+		// the next sync doesn't take the document as verified
+		resp, err = datasets.Sync(ctx, req)
+		as.Nil(err)
+		as.True(resp.Actions[0].Unverified)
+
+		req.ReplaceUnverified = true
+		resp, err = datasets.Sync(ctx, req)
+		as.Nil(err)
+		as.Equal("~ guides/same.md", resp.String())
+		as.Equal("newguides/same.md", manifest.Documents["guides/same.md"].DocumentID)
+		as.NotEmpty(manifest.Documents["guides/same.md"].SHA256)
+	})
+
+	t.Run("failed delete keeps the applied actions", func(t *testing.T) {
+		syncDeleteBatchSize = 1
+		defer func() { syncDeleteBatchSize = 100 }()
+		datasets := newSyncDatasets()
+		failDelete = 3
+		manifest := &DatasetSyncManifest{Documents: map[string]*DatasetSyncManifestEntry{
+			"removed.md": {DocumentID: "3", SHA256: "removed", Size: 4},
+		}}
+		_, err := datasets.Sync(ctx, &SyncDatasetsReq{
+			DatasetID: "100",
+			FS:        files,
+			Patterns:  []string{"*.md"},
+			Manifest:  manifest,
+		})
+		as.NotNil(err)
+		as.Equal(2, deleteBatches)
+		as.Equal([]int64{1}, deleted)
+		as.Equal("newintro.md", manifest.Documents["intro.md"].DocumentID)
+		as.Equal("newguides/setup.md", manifest.Documents["guides/setup.md"].DocumentID)
+		// the document is not deleted, its entry is kept
+		as.Equal("3", manifest.Documents["removed.md"].DocumentID)
+	})
+
+	t.Run("invalid dataset id", func(t *testing.T) {
+		_, err := newSyncDatasets().Sync(ctx, &SyncDatasetsReq{DatasetID: "abc", FS: files})
+		as.NotNil(err)
+	})
+}
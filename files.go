@@ -2,8 +2,12 @@ package coze
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
 	"io"
 	"net/http"
+	"os"
 )
 
 func (r *files) Upload(ctx context.Context, req *UploadFilesReq) (*UploadFilesResp, error) {
@@ -18,6 +22,51 @@ func (r *files) Upload(ctx context.Context, req *UploadFilesReq) (*UploadFilesRe
 	return response.Data, err
 }
 
+// UploadWithOption uploads a file like Upload, but the multipart body is streamed so the file is
+// never loaded into memory. The upload is not resumable, a failed upload has to be sent again
+// from the start with a new reader.
+func (r *files) UploadWithOption(ctx context.Context, req *UploadFilesReq, opt *UploadFilesOption) (*UploadFilesResp, error) {
+	var reader *uploadReader
+	body := req
+	if req.File != nil {
+		reader = newUploadReader(req.File, opt.onProgress())
+		body = &UploadFilesReq{File: NewUploadFile(reader, req.File.Name())}
+	}
+	request := &RawRequestReq{
+		Method:     http.MethodPost,
+		URL:        "/v1/files/upload",
+		Body:       body,
+		IsFile:     true,
+		streamFile: true,
+	}
+	response := new(uploadFilesResp)
+	err := r.core.rawRequest(ctx, request, response)
+	if err == nil && reader != nil && opt != nil && opt.OnChecksum != nil {
+		opt.OnChecksum(reader.checksum())
+	}
+	return response.Data, err
+}
+
+// UploadMany uploads files with at most opt.Concurrency concurrent streamed uploads, the responses
+// are in the order of reqs. It stops at the first failed upload and returns its error.
+func (r *files) UploadMany(ctx context.Context, reqs []*UploadFilesReq, opt *UploadManyFilesOption) ([]*UploadFilesResp, error) {
+	concurrency := 4
+	if opt != nil && opt.Concurrency > 0 {
+		concurrency = opt.Concurrency
+	}
+
+	res := make([]*UploadFilesResp, len(reqs))
+	err := runConcurrently(ctx, len(reqs), concurrency, func(ctx context.Context, i int) error {
+		resp, err := r.UploadWithOption(ctx, reqs[i], nil)
+		if err != nil {
+			return err
+		}
+		res[i] = resp
+		return nil
+	})
+	return res, err
+}
+
 func (r *files) Retrieve(ctx context.Context, req *RetrieveFilesReq) (*RetrieveFilesResp, error) {
 	request := &RawRequestReq{
 		Method: http.MethodGet,
@@ -62,6 +111,29 @@ type UploadFilesReq struct {
 	File FileTypes `json:"file"`
 }
 
+// UploadFilesOption represents the client side options of Files.UploadWithOption
+type UploadFilesOption struct {
+	// Optional: Called while the file is sent with the bytes sent and the total bytes of the file,
+	// total is -1 if the size of File is unknown.
+	OnProgress func(sent, total int64)
+
+	// Optional: Called with the hex encoded SHA-256 of the sent content once the upload succeeded.
+	OnChecksum func(sha256 string)
+}
+
+func (o *UploadFilesOption) onProgress() func(sent, total int64) {
+	if o == nil {
+		return nil
+	}
+	return o.OnProgress
+}
+
+// UploadManyFilesOption represents the options of Files.UploadMany
+type UploadManyFilesOption struct {
+	// The max number of concurrent uploads, default is 4.
+	Concurrency int
+}
+
 func NewUploadFile(reader io.Reader, fileName string) FileTypes {
 	return &implFileInterface{
 		Reader:   reader,
@@ -103,3 +175,60 @@ type files struct {
 func newFiles(core *core) *files {
 	return &files{core: core}
 }
+
+// uploadReader reports the progress and computes the checksum of an uploaded file
+type uploadReader struct {
+	reader     io.Reader
+	hash       hash.Hash
+	sent       int64
+	total      int64
+	onProgress func(sent, total int64)
+}
+
+func newUploadReader(reader io.Reader, onProgress func(sent, total int64)) *uploadReader {
+	return &uploadReader{
+		reader:     reader,
+		hash:       sha256.New(),
+		total:      readerSize(reader),
+		onProgress: onProgress,
+	}
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.hash.Write(p[:n])
+		r.sent += int64(n)
+		if r.onProgress != nil {
+			r.onProgress(r.sent, r.total)
+		}
+	}
+	return n, err
+}
+
+func (r *uploadReader) checksum() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// readerSize returns the size of the remaining content of reader, or -1 if unknown
+func readerSize(reader io.Reader) int64 {
+	if r, ok := reader.(*implFileInterface); ok {
+		return readerSize(r.Reader)
+	}
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,385 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// UploadPath validates and uploads a local file, the file is streamed like Files.UploadWithOption.
+func (r *files) UploadPath(ctx context.Context, filePath string, opt *UploadFileOption) (*UploadFilesResp, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	file, err := opt.validate(filepath.Base(filePath), info.Size(), head[:n], "")
+	if err != nil {
+		return nil, err
+	}
+	return r.uploadValidated(ctx, file, f, opt)
+}
+
+// UploadBytes validates and uploads data named name, the extension is inferred from the content
+// if name has none.
+func (r *files) UploadBytes(ctx context.Context, data []byte, name string, opt *UploadFileOption) (*UploadFilesResp, error) {
+	file, err := opt.validate(name, int64(len(data)), data, "")
+	if err != nil {
+		return nil, err
+	}
+	return r.uploadValidated(ctx, file, bytes.NewReader(data), opt)
+}
+
+// UploadURL downloads a remote file and uploads it, the download is streamed into the upload and
+// aborted as soon as it exceeds the size limit of the target.
+func (r *files) UploadURL(ctx context.Context, fileURL string, opt *UploadFileOption) (*UploadFilesResp, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = ""
+	}
+	// a name without extension is checked once the content type is known
+	if filepath.Ext(name) != "" {
+		if err := opt.validateExtension(name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := opt.downloadClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("download %s failed: %s", fileURL, resp.Status)
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	// the size is checked again while streaming if the server doesn't declare it
+	size := resp.ContentLength
+	if size < int64(n) {
+		size = int64(n)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	file, err := opt.validate(name, size, head, contentType)
+	if err != nil {
+		return nil, err
+	}
+	body := io.MultiReader(bytes.NewReader(head), resp.Body)
+	if maxSize := opt.maxSize(); maxSize > 0 {
+		body = &maxSizeReader{reader: body, remaining: maxSize, err: opt.validationError(file.name, maxSize+1, file.mimeType, ErrFileTooLarge)}
+	}
+	return r.uploadValidated(ctx, file, body, opt)
+}
+
+// maxSizeReader fails with err once more than remaining bytes are read
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+	err       error
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, r.err
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, r.err
+	}
+	return n, err
+}
+
+// defaultDownloadClient downloads the files of UploadURL, it has no timeout since a large file may
+// take long, the download is cancelled by the context instead
+var defaultDownloadClient HTTPClient = &http.Client{}
+
+func (r *files) uploadValidated(ctx context.Context, file *validatedFile, reader io.Reader, opt *UploadFileOption) (*UploadFilesResp, error) {
+	var uploadOpt *UploadFilesOption
+	if opt != nil {
+		uploadOpt = &opt.UploadFilesOption
+	}
+	return r.UploadWithOption(ctx, &UploadFilesReq{File: NewUploadFile(reader, file.name)}, uploadOpt)
+}
+
+// FileUploadTarget represents what an uploaded file is used for, each target accepts different file
+// types and sizes.
+type FileUploadTarget string
+
+const (
+	// FileUploadTargetChat The file is attached to a chat message, the default target.
+	FileUploadTargetChat FileUploadTarget = "chat"
+	// FileUploadTargetDatasetDocument The file is a document of a text dataset.
+	FileUploadTargetDatasetDocument FileUploadTarget = "dataset_document"
+	// FileUploadTargetDatasetImage The file is an image of an image dataset.
+	FileUploadTargetDatasetImage FileUploadTarget = "dataset_image"
+)
+
+type fileUploadRule struct {
+	maxSize    int64
+	extensions []string
+	mimePrefix string
+}
+
+var fileUploadRules = map[FileUploadTarget]*fileUploadRule{
+	FileUploadTargetChat: {
+		maxSize: 512 << 20,
+		extensions: []string{
+			"doc", "docx", "xls", "xlsx", "ppt", "pptx", "pdf", "numbers", "csv", "txt", "md", "json",
+			"jpg", "jpeg", "png", "gif", "webp", "heic", "heif", "bmp", "pcd", "tiff",
+			"mp3", "wav", "ogg", "m4a", "aac", "mp4", "avi", "mov", "mkv", "zip", "rar",
+		},
+	},
+	FileUploadTargetDatasetDocument: {
+		maxSize:    100 << 20,
+		extensions: []string{"pdf", "txt", "doc", "docx", "md", "csv", "xls", "xlsx", "json"},
+	},
+	FileUploadTargetDatasetImage: {
+		maxSize:    20 << 20,
+		extensions: []string{"jpg", "jpeg", "png", "webp", "gif", "bmp"},
+		mimePrefix: "image/",
+	},
+}
+
+// UploadFileOption represents the options of Files.UploadPath, Files.UploadBytes and Files.UploadURL
+type UploadFileOption struct {
+	// The use of the file, default is FileUploadTargetChat.
+	Target FileUploadTarget
+
+	// Optional: Overrides the max size in bytes of the target.
+	MaxSize int64
+
+	// Optional: Overrides the allowed extensions of the target, without the leading dot.
+	AllowedExtensions []string
+
+	// Optional: The progress and checksum callbacks of the upload.
+	UploadFilesOption
+
+	// Optional: The client downloading the file of Files.UploadURL. The default client has no
+	// timeout, the download is cancelled by the context.
+	DownloadClient HTTPClient
+}
+
+var (
+	// ErrFileEmpty is returned for an empty file
+	ErrFileEmpty = errors.New("file is empty")
+	// ErrFileTooLarge is returned for a file exceeding the max size of its target
+	ErrFileTooLarge = errors.New("file is too large")
+	// ErrFileExtensionNotAllowed is returned for a file whose extension is not accepted by its target
+	ErrFileExtensionNotAllowed = errors.New("file extension is not allowed")
+	// ErrFileTypeNotAllowed is returned for a file whose content is not accepted by its target
+	ErrFileTypeNotAllowed = errors.New("file type is not allowed")
+)
+
+// FileValidationError is returned when a file is rejected before it is uploaded, Err is one of
+// ErrFileEmpty, ErrFileTooLarge, ErrFileExtensionNotAllowed and ErrFileTypeNotAllowed.
+type FileValidationError struct {
+	Name     string
+	Target   FileUploadTarget
+	Size     int64
+	MaxSize  int64
+	MIMEType string
+	Err      error
+}
+
+// Error implements the error interface
+func (e *FileValidationError) Error() string {
+	return fmt.Sprintf("invalid file %q for %s: %s (size=%d, max_size=%d, mime_type=%s)",
+		e.Name, e.Target, e.Err, e.Size, e.MaxSize, e.MIMEType)
+}
+
+// Unwrap returns the underlying error
+func (e *FileValidationError) Unwrap() error {
+	return e.Err
+}
+
+// AsFileValidationError checks if the error is of type FileValidationError
+func AsFileValidationError(err error) (*FileValidationError, bool) {
+	var validationErr *FileValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr, true
+	}
+	return nil, false
+}
+
+type validatedFile struct {
+	name     string
+	mimeType string
+}
+
+func (o *UploadFileOption) target() FileUploadTarget {
+	if o == nil || o.Target == "" {
+		return FileUploadTargetChat
+	}
+	return o.Target
+}
+
+func (o *UploadFileOption) rule() *fileUploadRule {
+	if rule, ok := fileUploadRules[o.target()]; ok {
+		return rule
+	}
+	return &fileUploadRule{}
+}
+
+func (o *UploadFileOption) downloadClient() HTTPClient {
+	if o != nil && o.DownloadClient != nil {
+		return o.DownloadClient
+	}
+	return defaultDownloadClient
+}
+
+func (o *UploadFileOption) maxSize() int64 {
+	if o != nil && o.MaxSize > 0 {
+		return o.MaxSize
+	}
+	return o.rule().maxSize
+}
+
+func (o *UploadFileOption) extensions() []string {
+	if o != nil && len(o.AllowedExtensions) > 0 {
+		return o.AllowedExtensions
+	}
+	return o.rule().extensions
+}
+
+// validate checks size, extension and content of a file, contentType is the declared type of the
+// content, it is sniffed from head if empty.
+func (o *UploadFileOption) validate(name string, size int64, head []byte, contentType string) (*validatedFile, error) {
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+	if size == 0 {
+		return nil, o.validationError(name, size, contentType, ErrFileEmpty)
+	}
+	if maxSize := o.maxSize(); maxSize > 0 && size > maxSize {
+		return nil, o.validationError(name, size, contentType, ErrFileTooLarge)
+	}
+	if filepath.Ext(name) == "" {
+		name += extensionByType(contentType)
+	}
+	if err := o.validateExtension(name, contentType); err != nil {
+		return nil, err
+	}
+	if prefix := o.rule().mimePrefix; prefix != "" && !strings.HasPrefix(contentType, prefix) {
+		return nil, o.validationError(name, size, contentType, ErrFileTypeNotAllowed)
+	}
+	return &validatedFile{name: name, mimeType: contentType}, nil
+}
+
+// validateExtension checks the extension of name, a name without extension is only accepted if
+// the target allows any extension. validate names a file after its MIME type beforehand.
+func (o *UploadFileOption) validateExtension(name, mimeType string) error {
+	extensions := o.extensions()
+	if len(extensions) == 0 {
+		return nil
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if ext == "" {
+		return o.validationError(name, 0, mimeType, ErrFileExtensionNotAllowed)
+	}
+	for _, allowed := range extensions {
+		if strings.EqualFold(strings.TrimPrefix(allowed, "."), ext) {
+			return nil
+		}
+	}
+	return o.validationError(name, 0, mimeType, ErrFileExtensionNotAllowed)
+}
+
+func (o *UploadFileOption) validationError(name string, size int64, mimeType string, err error) error {
+	return &FileValidationError{
+		Name:     name,
+		Target:   o.target(),
+		Size:     size,
+		MaxSize:  o.maxSize(),
+		MIMEType: mimeType,
+		Err:      err,
+	}
+}
+
+// uploadExtensions maps the MIME types to the extensions accepted by the upload targets, unlike
+// mime.ExtensionsByType it never gives an extension like .bin which fails the validation
+var uploadExtensions = map[string]string{
+	"application/pdf":    ".pdf",
+	"application/json":   ".json",
+	"application/zip":    ".zip",
+	"application/msword": ".doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"application/vnd.ms-excel": ".xls",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.ms-powerpoint":                                             ".ppt",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"application/vnd.rar":          ".rar",
+	"application/x-rar-compressed": ".rar",
+	"text/plain":                   ".txt",
+	"text/csv":                     ".csv",
+	"text/markdown":                ".md",
+	"image/jpeg":                   ".jpg",
+	"image/png":                    ".png",
+	"image/gif":                    ".gif",
+	"image/webp":                   ".webp",
+	"image/bmp":                    ".bmp",
+	"image/heic":                   ".heic",
+	"image/heif":                   ".heif",
+	"image/tiff":                   ".tiff",
+	"audio/mpeg":                   ".mp3",
+	"audio/wave":                   ".wav",
+	"audio/wav":                    ".wav",
+	"audio/x-wav":                  ".wav",
+	"audio/ogg":                    ".ogg",
+	"application/ogg":              ".ogg",
+	"audio/mp4":                    ".m4a",
+	"audio/x-m4a":                  ".m4a",
+	"audio/aac":                    ".aac",
+	"video/mp4":                    ".mp4",
+	"video/x-msvideo":              ".avi",
+	"video/avi":                    ".avi",
+	"video/quicktime":              ".mov",
+	"video/x-matroska":             ".mkv",
+}
+
+// extensionByType returns the extension of a MIME type, or empty if it's not an uploadable type
+func extensionByType(contentType string) string {
+	return uploadExtensions[contentType]
+}
@@ -0,0 +1,140 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newLocalFilesMock(t *testing.T, uploaded *string) *files {
+	return newFiles(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Host + req.URL.Path {
+		case "example.com/cat.png", "example.com/download":
+			header := http.Header{"Content-Type": []string{"image/png"}}
+			if req.URL.Path == "/download" {
+				header.Set("Content-Disposition", `attachment; filename="remote.png"`)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader(string(testPNG)))}, nil
+		case "example.com/large.txt":
+			return &http.Response{StatusCode: http.StatusOK, ContentLength: -1, Body: io.NopCloser(strings.NewReader(strings.Repeat("a", 2000)))}, nil
+		case "example.com/missing.png":
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader(""))}, nil
+		case "api.coze.cn/v1/files/upload":
+			_, header, err := req.FormFile("file")
+			if err != nil {
+				return nil, err
+			}
+			*uploaded = header.Filename
+			return mockResponse(http.StatusOK, &uploadFilesResp{Data: &UploadFilesResp{FileInfo: FileInfo{ID: "file1"}}})
+		default:
+			t.Fatalf("unexpected request: %s", req.URL)
+			return nil, nil
+		}
+	})))
+}
+
+func TestFilesLocal(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+	uploaded := ""
+	files := newLocalFilesMock(t, &uploaded)
+	download := func(opt *UploadFileOption) *UploadFileOption {
+		if opt == nil {
+			opt = &UploadFileOption{}
+		}
+		opt.DownloadClient = files.core.client
+		return opt
+	}
+
+	t.Run("upload path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "doc.txt")
+		as.Nil(os.WriteFile(path, []byte("hello"), 0o600))
+		resp, err := files.UploadPath(ctx, path, &UploadFileOption{Target: FileUploadTargetDatasetDocument})
+		as.Nil(err)
+		as.Equal("file1", resp.ID)
+		as.Equal("doc.txt", uploaded)
+	})
+
+	t.Run("upload bytes infers extension", func(t *testing.T) {
+		_, err := files.UploadBytes(ctx, testPNG, "cat", &UploadFileOption{Target: FileUploadTargetDatasetImage})
+		as.Nil(err)
+		as.Equal("cat.png", uploaded)
+	})
+
+	t.Run("upload url", func(t *testing.T) {
+		_, err := files.UploadURL(ctx, "https://example.com/cat.png", download(nil))
+		as.Nil(err)
+		as.Equal("cat.png", uploaded)
+
+		_, err = files.UploadURL(ctx, "https://example.com/download", download(&UploadFileOption{Target: FileUploadTargetDatasetImage}))
+		as.Nil(err)
+		as.Equal("remote.png", uploaded)
+
+		_, err = files.UploadURL(ctx, "https://example.com/missing.png", download(nil))
+		as.NotNil(err)
+	})
+
+	t.Run("upload url streams within the max size", func(t *testing.T) {
+		_, err := files.UploadURL(ctx, "https://example.com/large.txt", download(&UploadFileOption{MaxSize: 4000}))
+		as.Nil(err)
+		as.Equal("large.txt", uploaded)
+
+		// the size is unknown before the download, the upload is aborted once it's exceeded
+		_, err = files.UploadURL(ctx, "https://example.com/large.txt", download(&UploadFileOption{MaxSize: 1000}))
+		as.True(errors.Is(err, ErrFileTooLarge))
+	})
+
+	t.Run("unknown content type has no extension", func(t *testing.T) {
+		uploaded = ""
+		_, err := files.UploadBytes(ctx, []byte{0, 1, 2, 3}, "blob", nil)
+		validationErr, ok := AsFileValidationError(err)
+		as.True(ok)
+		as.Equal(ErrFileExtensionNotAllowed, validationErr.Err)
+		as.Equal("application/octet-stream", validationErr.MIMEType)
+		as.Empty(uploaded)
+
+		// a target without extension whitelist accepts it
+		_, err = files.UploadBytes(ctx, []byte{0, 1, 2, 3}, "blob", &UploadFileOption{Target: "custom"})
+		as.Nil(err)
+		as.Equal("blob", uploaded)
+		as.Equal(".docx", extensionByType("application/vnd.openxmlformats-officedocument.wordprocessingml.document"))
+		as.Empty(extensionByType("application/octet-stream"))
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		uploaded = ""
+		_, err := files.UploadBytes(ctx, []byte("hello"), "doc.exe", nil)
+		as.True(errors.Is(err, ErrFileExtensionNotAllowed))
+
+		_, err = files.UploadBytes(ctx, nil, "doc.txt", nil)
+		as.True(errors.Is(err, ErrFileEmpty))
+
+		_, err = files.UploadBytes(ctx, []byte("hello"), "cat.png", &UploadFileOption{Target: FileUploadTargetDatasetImage})
+		validationErr, ok := AsFileValidationError(err)
+		as.True(ok)
+		as.Equal(ErrFileTypeNotAllowed, validationErr.Err)
+		as.Equal(FileUploadTargetDatasetImage, validationErr.Target)
+
+		_, err = files.UploadBytes(ctx, []byte("hello"), "doc.txt", &UploadFileOption{MaxSize: 4})
+		validationErr, ok = AsFileValidationError(err)
+		as.True(ok)
+		as.Equal(ErrFileTooLarge, validationErr.Err)
+		as.Equal(int64(5), validationErr.Size)
+
+		_, err = files.UploadURL(ctx, "https://example.com/cat.png", download(&UploadFileOption{MaxSize: 4}))
+		as.True(errors.Is(err, ErrFileTooLarge))
+
+		_, err = files.UploadURL(ctx, "https://example.com/setup.exe", nil)
+		as.True(errors.Is(err, ErrFileExtensionNotAllowed))
+		as.Empty(uploaded)
+	})
+}
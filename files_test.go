@@ -2,10 +2,15 @@ package coze
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +21,9 @@ func TestFiles(t *testing.T) {
 		files := newFiles(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
 			as.Equal(http.MethodPost, req.Method)
 			as.Equal("/v1/files/upload", req.URL.Path)
+			// the body is buffered, so it can be sent again
+			as.True(req.ContentLength > 0)
+			as.NotNil(req.GetBody)
 			return mockResponse(http.StatusOK, &uploadFilesResp{
 				Data: &UploadFilesResp{
 					FileInfo: FileInfo{
@@ -95,4 +103,69 @@ func TestFiles(t *testing.T) {
 		_, err := uploadReq.Read(buffer)
 		as.Nil(err)
 	})
+
+	t.Run("upload file with progress and checksum", func(t *testing.T) {
+		files := newFiles(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			file, header, err := req.FormFile("file")
+			as.Nil(err)
+			as.Equal("test.txt", header.Filename)
+			content, _ := io.ReadAll(file)
+			as.Equal("test file content", string(content))
+			return mockResponse(http.StatusOK, &uploadFilesResp{
+				Data: &UploadFilesResp{FileInfo: FileInfo{ID: "file1"}},
+			})
+		})))
+		var sent, total int64
+		checksum := ""
+		resp, err := files.UploadWithOption(context.Background(), &UploadFilesReq{
+			File: NewUploadFile(strings.NewReader("test file content"), "test.txt"),
+		}, &UploadFilesOption{
+			OnProgress: func(s, t int64) {
+				sent, total = s, t
+			},
+			OnChecksum: func(sha256 string) {
+				checksum = sha256
+			},
+		})
+		as.Nil(err)
+		as.Equal("file1", resp.ID)
+		as.Equal(int64(17), sent)
+		as.Equal(int64(17), total)
+		sum := sha256.Sum256([]byte("test file content"))
+		as.Equal(hex.EncodeToString(sum[:]), checksum)
+	})
+
+	t.Run("upload many files in order", func(t *testing.T) {
+		files := newFiles(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			file, _, err := req.FormFile("file")
+			as.Nil(err)
+			content, _ := io.ReadAll(file)
+			i, _ := strconv.Atoi(string(content))
+			time.Sleep(time.Duration(5-i) * time.Millisecond)
+			return mockResponse(http.StatusOK, &uploadFilesResp{
+				Data: &UploadFilesResp{FileInfo: FileInfo{ID: "file" + string(content)}},
+			})
+		})))
+		reqs := []*UploadFilesReq{}
+		for i := 0; i < 5; i++ {
+			reqs = append(reqs, &UploadFilesReq{File: NewUploadFile(strings.NewReader(strconv.Itoa(i)), "test.txt")})
+		}
+		resps, err := files.UploadMany(context.Background(), reqs, &UploadManyFilesOption{Concurrency: 2})
+		as.Nil(err)
+		as.Len(resps, 5)
+		for i, resp := range resps {
+			as.Equal("file"+strconv.Itoa(i), resp.ID)
+		}
+	})
+
+	t.Run("upload many files with error", func(t *testing.T) {
+		files := newFiles(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("test error")
+		})))
+		_, err := files.UploadMany(context.Background(), []*UploadFilesReq{
+			{File: NewUploadFile(strings.NewReader("a"), "a.txt")},
+			{File: NewUploadFile(strings.NewReader("b"), "b.txt")},
+		}, nil)
+		as.NotNil(err)
+	})
 }
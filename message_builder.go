@@ -0,0 +1,216 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MessageBuilder builds multimodal user questions, local files and readers are uploaded by
+// Files.Upload when the message is built.
+//
+//	msg, err := coze.NewMessageBuilder(&api).
+//		AddText("what is in the picture?").
+//		AddPath(coze.MessageObjectStringTypeImage, "cat.png").
+//		AddURL(coze.MessageObjectStringTypeFile, "https://example.com/doc.pdf").
+//		Build(ctx)
+//
+// Identical contents are uploaded once, also across several builds of the same builder.
+type MessageBuilder struct {
+	files       *files
+	items       []*messageBuilderItem
+	metaData    map[string]string
+	concurrency int
+
+	mu       sync.Mutex
+	uploaded map[string]string // content hash -> file id
+}
+
+type messageBuilderItem struct {
+	object *MessageObjectString
+	path   string
+	reader io.Reader
+	data   []byte // the buffered content of reader
+	name   string
+}
+
+// NewMessageBuilder creates an empty MessageBuilder, uploads are made by api.Files.
+func NewMessageBuilder(api *CozeAPI) *MessageBuilder {
+	return &MessageBuilder{
+		files:       api.Files,
+		concurrency: 4,
+		uploaded:    map[string]string{},
+	}
+}
+
+// AddText adds a text object
+func (b *MessageBuilder) AddText(text string) *MessageBuilder {
+	b.items = append(b.items, &messageBuilderItem{object: NewTextMessageObject(text)})
+	return b
+}
+
+// AddPath adds a local file, typ is one of file, image and audio.
+func (b *MessageBuilder) AddPath(typ MessageObjectStringType, path string) *MessageBuilder {
+	b.items = append(b.items, &messageBuilderItem{
+		object: &MessageObjectString{Type: typ},
+		path:   path,
+		name:   filepath.Base(path),
+	})
+	return b
+}
+
+// AddReader adds the content of reader named name, typ is one of file, image and audio. The reader
+// is consumed when the message is built.
+func (b *MessageBuilder) AddReader(typ MessageObjectStringType, reader io.Reader, name string) *MessageBuilder {
+	b.items = append(b.items, &messageBuilderItem{
+		object: &MessageObjectString{Type: typ},
+		reader: reader,
+		name:   name,
+	})
+	return b
+}
+
+// AddURL adds an online file, typ is one of file, image and audio.
+func (b *MessageBuilder) AddURL(typ MessageObjectStringType, fileURL string) *MessageBuilder {
+	b.items = append(b.items, &messageBuilderItem{object: &MessageObjectString{Type: typ, FileURL: fileURL}})
+	return b
+}
+
+// AddFileID adds an uploaded file, typ is one of file, image and audio.
+func (b *MessageBuilder) AddFileID(typ MessageObjectStringType, fileID string) *MessageBuilder {
+	b.items = append(b.items, &messageBuilderItem{object: &MessageObjectString{Type: typ, FileID: fileID}})
+	return b
+}
+
+// WithMetaData sets the meta data of the built message
+func (b *MessageBuilder) WithMetaData(metaData map[string]string) *MessageBuilder {
+	b.metaData = metaData
+	return b
+}
+
+// WithConcurrency sets the max number of concurrent uploads, default is 4.
+func (b *MessageBuilder) WithConcurrency(concurrency int) *MessageBuilder {
+	if concurrency > 0 {
+		b.concurrency = concurrency
+	}
+	return b
+}
+
+// Build uploads the local contents and returns a user question for CreateChatsReq.Messages
+func (b *MessageBuilder) Build(ctx context.Context) (*Message, error) {
+	objects, err := b.BuildObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildUserQuestionObjects(objects, b.metaData), nil
+}
+
+// BuildObjects uploads the local contents and returns the objects in the order they were added, the
+// result can be passed to CreateMessageReq.SetObjectContext.
+func (b *MessageBuilder) BuildObjects(ctx context.Context) ([]*MessageObjectString, error) {
+	if len(b.items) == 0 {
+		return nil, errors.New("message builder is empty")
+	}
+
+	// hash the local contents first, so identical contents share a single upload
+	uploads := map[string][]*MessageObjectString{}
+	sources := map[string]*messageBuilderItem{}
+	var order []string
+	objects := make([]*MessageObjectString, 0, len(b.items))
+	for _, item := range b.items {
+		object := *item.object
+		objects = append(objects, &object)
+		if item.path == "" && item.reader == nil {
+			continue
+		}
+		hash, err := item.hash()
+		if err != nil {
+			return nil, err
+		}
+		if fileID, ok := b.uploadedFileID(hash); ok {
+			object.FileID = fileID
+			continue
+		}
+		if _, ok := uploads[hash]; !ok {
+			order = append(order, hash)
+			sources[hash] = item
+		}
+		uploads[hash] = append(uploads[hash], &object)
+	}
+
+	err := runConcurrently(ctx, len(order), b.concurrency, func(ctx context.Context, i int) error {
+		hash := order[i]
+		fileID, err := b.upload(ctx, sources[hash])
+		if err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.uploaded[hash] = fileID
+		b.mu.Unlock()
+		for _, object := range uploads[hash] {
+			object.FileID = fileID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (b *MessageBuilder) uploadedFileID(hash string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fileID, ok := b.uploaded[hash]
+	return fileID, ok
+}
+
+func (b *MessageBuilder) upload(ctx context.Context, item *messageBuilderItem) (string, error) {
+	var reader io.Reader = bytes.NewReader(item.data)
+	if item.path != "" {
+		f, err := os.Open(item.path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		reader = f
+	}
+	resp, err := b.files.Upload(ctx, &UploadFilesReq{File: NewUploadFile(reader, item.name)})
+	if err != nil {
+		return "", fmt.Errorf("upload %s: %w", item.name, err)
+	}
+	return resp.ID, nil
+}
+
+// hash returns the sha256 of the content, a reader is buffered so it can be uploaded later.
+func (item *messageBuilderItem) hash() (string, error) {
+	h := sha256.New()
+	if item.path != "" {
+		f, err := os.Open(item.path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if item.data == nil {
+		data, err := io.ReadAll(item.reader)
+		if err != nil {
+			return "", err
+		}
+		item.data = data
+	}
+	h.Write(item.data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,102 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMessageBuilderAPI(fn func(content string) (*http.Response, error)) *CozeAPI {
+	return &CozeAPI{Files: newFiles(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+		file, _, err := req.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		content, _ := io.ReadAll(file)
+		return fn(string(content))
+	})))}
+}
+
+func TestMessageBuilder(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("upload and dedup", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cat.png")
+		as.Nil(os.WriteFile(path, []byte("cat"), 0o600))
+
+		var mu sync.Mutex
+		uploads := map[string]int{}
+		api := newMessageBuilderAPI(func(content string) (*http.Response, error) {
+			mu.Lock()
+			uploads[content]++
+			mu.Unlock()
+			return mockResponse(http.StatusOK, &uploadFilesResp{
+				Data: &UploadFilesResp{FileInfo: FileInfo{ID: "file_" + content}},
+			})
+		})
+		builder := NewMessageBuilder(api).
+			AddText("compare them").
+			AddPath(MessageObjectStringTypeImage, path).
+			AddReader(MessageObjectStringTypeImage, strings.NewReader("cat"), "copy.png").
+			AddReader(MessageObjectStringTypeFile, strings.NewReader("doc"), "doc.txt").
+			AddURL(MessageObjectStringTypeFile, "https://example.com/a.pdf").
+			WithMetaData(map[string]string{"k": "v"})
+
+		msg, err := builder.Build(ctx)
+		as.Nil(err)
+		as.Equal(MessageRoleUser, msg.Role)
+		as.Equal(MessageContentTypeObjectString, msg.ContentType)
+		as.Equal("v", msg.MetaData["k"])
+
+		objects := []*MessageObjectString{}
+		as.Nil(json.Unmarshal([]byte(msg.Content), &objects))
+		as.Equal([]*MessageObjectString{
+			NewTextMessageObject("compare them"),
+			NewImageMessageObjectByID("file_cat"),
+			NewImageMessageObjectByID("file_cat"),
+			NewFileMessageObjectByID("file_doc"),
+			NewFileMessageObjectByURL("https://example.com/a.pdf"),
+		}, objects)
+		as.Equal(map[string]int{"cat": 1, "doc": 1}, uploads)
+
+		// the uploaded contents are reused by later builds
+		objects, err = builder.BuildObjects(ctx)
+		as.Nil(err)
+		as.Len(objects, 5)
+		as.Equal("file_doc", objects[3].FileID)
+		as.Equal(map[string]int{"cat": 1, "doc": 1}, uploads)
+	})
+
+	t.Run("upload failed", func(t *testing.T) {
+		api := newMessageBuilderAPI(func(content string) (*http.Response, error) {
+			return nil, errors.New("network error")
+		})
+		_, err := NewMessageBuilder(api).
+			AddReader(MessageObjectStringTypeFile, strings.NewReader("doc"), "doc.txt").
+			Build(ctx)
+		as.NotNil(err)
+		as.Contains(err.Error(), "doc.txt")
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		_, err := NewMessageBuilder(&CozeAPI{}).
+			AddPath(MessageObjectStringTypeFile, filepath.Join(t.TempDir(), "missing")).
+			Build(ctx)
+		as.True(os.IsNotExist(err))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := NewMessageBuilder(&CozeAPI{}).Build(ctx)
+		as.NotNil(err)
+	})
+}
@@ -0,0 +1,202 @@
+package coze
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MessageContentError is returned when the content of a message can not be decoded as requested
+type MessageContentError struct {
+	MessageID   string
+	Type        MessageType
+	ContentType MessageContentType
+	Err         error
+}
+
+// Error implements the error interface
+func (e *MessageContentError) Error() string {
+	return fmt.Sprintf("decode content of message %s (type=%s, content_type=%s): %s",
+		e.MessageID, e.Type, e.ContentType, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *MessageContentError) Unwrap() error {
+	return e.Err
+}
+
+// MessageFunctionCall represents the content of a function_call message
+type MessageFunctionCall struct {
+	// The name of the function.
+	Name string `json:"name"`
+
+	// The arguments of the function, a JSON object.
+	Arguments json.RawMessage `json:"arguments"`
+
+	// The name of the plugin providing the function.
+	PluginName string `json:"plugin_name,omitempty"`
+
+	// The name of the plugin API.
+	APIName string `json:"api_name,omitempty"`
+}
+
+// DecodeArguments decodes the arguments of the function call into v
+func (r *MessageFunctionCall) DecodeArguments(v any) error {
+	return json.Unmarshal(r.Arguments, v)
+}
+
+// MessageVerboseType represents the type of verbose message
+type MessageVerboseType string
+
+const (
+	// MessageVerboseTypeKnowledgeRecall The result of the knowledge recall, see
+	// MessageVerbose.KnowledgeRecall.
+	MessageVerboseTypeKnowledgeRecall MessageVerboseType = "knowledge_recall"
+
+	// MessageVerboseTypeGenerateAnswerFinish The answer of the chat is completed.
+	MessageVerboseTypeGenerateAnswerFinish MessageVerboseType = "generate_answer_finish"
+)
+
+// MessageVerbose represents the content of a verbose message
+type MessageVerbose struct {
+	MsgType MessageVerboseType `json:"msg_type"`
+
+	// The data of the message, a JSON string whose format depends on MsgType.
+	Data string `json:"data"`
+}
+
+// KnowledgeRecall decodes the data of a knowledge_recall verbose message
+func (r *MessageVerbose) KnowledgeRecall() (*MessageKnowledgeRecall, error) {
+	if r.MsgType != MessageVerboseTypeKnowledgeRecall {
+		return nil, fmt.Errorf("verbose message type is %s, not %s", r.MsgType, MessageVerboseTypeKnowledgeRecall)
+	}
+	recall := &MessageKnowledgeRecall{}
+	if err := json.Unmarshal([]byte(r.Data), recall); err != nil {
+		return nil, err
+	}
+	return recall, nil
+}
+
+// MessageKnowledgeRecall represents the knowledge recalled for a chat
+type MessageKnowledgeRecall struct {
+	// The recalled slices.
+	Chunks []*MessageKnowledgeRecallChunk `json:"chunks"`
+
+	// The original query of the recall.
+	OriReq string `json:"ori_req"`
+}
+
+// MessageKnowledgeRecallChunk represents a recalled slice
+type MessageKnowledgeRecallChunk struct {
+	// The content of the slice.
+	Slice string `json:"slice"`
+
+	// The relevance score of the slice.
+	Score float64 `json:"score"`
+
+	Meta *MessageKnowledgeRecallMeta `json:"meta"`
+}
+
+// MessageKnowledgeRecallMeta represents where a recalled slice comes from
+type MessageKnowledgeRecallMeta struct {
+	Dataset  *MessageKnowledgeRecallDataset  `json:"dataset,omitempty"`
+	Document *MessageKnowledgeRecallDocument `json:"document,omitempty"`
+	Link     *MessageKnowledgeRecallLink     `json:"link,omitempty"`
+}
+
+// MessageKnowledgeRecallDataset represents the dataset of a recalled slice
+type MessageKnowledgeRecallDataset struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// MessageKnowledgeRecallDocument represents the document of a recalled slice
+type MessageKnowledgeRecallDocument struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	SourceType int    `json:"source_type"`
+}
+
+// MessageKnowledgeRecallLink represents the web page of a recalled slice
+type MessageKnowledgeRecallLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// ObjectContent decodes the parts of an object_string message
+func (m *Message) ObjectContent() ([]*MessageObjectString, error) {
+	if err := m.expectContentType(MessageContentTypeObjectString); err != nil {
+		return nil, err
+	}
+	objects := []*MessageObjectString{}
+	if err := m.decodeContent(&objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// DecodeCard decodes the JSON of a card message into v
+func (m *Message) DecodeCard(v any) error {
+	if err := m.expectContentType(MessageContentTypeCard); err != nil {
+		return err
+	}
+	return m.decodeContent(v)
+}
+
+// FunctionCall decodes the content of a function_call message
+func (m *Message) FunctionCall() (*MessageFunctionCall, error) {
+	if err := m.expectType(MessageTypeFunctionCall); err != nil {
+		return nil, err
+	}
+	call := &MessageFunctionCall{}
+	if err := m.decodeContent(call); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// Verbose decodes the content of a verbose message
+func (m *Message) Verbose() (*MessageVerbose, error) {
+	if err := m.expectType(MessageTypeVerbose); err != nil {
+		return nil, err
+	}
+	verbose := &MessageVerbose{}
+	if err := m.decodeContent(verbose); err != nil {
+		return nil, err
+	}
+	return verbose, nil
+}
+
+// DecodeContent decodes the content of the message as JSON into v, whatever its type is.
+func (m *Message) DecodeContent(v any) error {
+	return m.decodeContent(v)
+}
+
+func (m *Message) expectContentType(contentType MessageContentType) error {
+	if m.ContentType == contentType {
+		return nil
+	}
+	return m.contentError(fmt.Errorf("content type is not %s", contentType))
+}
+
+func (m *Message) expectType(typ MessageType) error {
+	if m.Type == typ {
+		return nil
+	}
+	return m.contentError(fmt.Errorf("message type is not %s", typ))
+}
+
+func (m *Message) decodeContent(v any) error {
+	if err := json.Unmarshal([]byte(m.Content), v); err != nil {
+		return m.contentError(err)
+	}
+	return nil
+}
+
+func (m *Message) contentError(err error) error {
+	return &MessageContentError{
+		MessageID:   m.ID,
+		Type:        m.Type,
+		ContentType: m.ContentType,
+		Err:         err,
+	}
+}
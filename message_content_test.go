@@ -0,0 +1,80 @@
+package coze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageContent(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("object content", func(t *testing.T) {
+		msg := BuildUserQuestionObjects([]*MessageObjectString{
+			NewTextMessageObject("hello"),
+			NewImageMessageObjectByID("file1"),
+		}, nil)
+		objects, err := msg.ObjectContent()
+		as.Nil(err)
+		as.Len(objects, 2)
+		as.Equal("file1", objects[1].FileID)
+
+		_, err = BuildUserQuestionText("hello", nil).ObjectContent()
+		contentErr := &MessageContentError{}
+		as.ErrorAs(err, &contentErr)
+		as.Equal(MessageContentTypeText, contentErr.ContentType)
+	})
+
+	t.Run("card", func(t *testing.T) {
+		msg := &Message{ContentType: MessageContentTypeCard, Content: `{"card_type":2,"template_id":"t1"}`}
+		card := struct {
+			CardType   int    `json:"card_type"`
+			TemplateID string `json:"template_id"`
+		}{}
+		as.Nil(msg.DecodeCard(&card))
+		as.Equal("t1", card.TemplateID)
+
+		msg.Content = "not json"
+		as.NotNil(msg.DecodeCard(&card))
+	})
+
+	t.Run("function call", func(t *testing.T) {
+		msg := &Message{
+			ID:          "msg1",
+			Type:        MessageTypeFunctionCall,
+			ContentType: MessageContentTypeText,
+			Content:     `{"name":"get_weather","arguments":{"city":"beijing"},"plugin_name":"weather","api_name":"get_weather"}`,
+		}
+		call, err := msg.FunctionCall()
+		as.Nil(err)
+		as.Equal("get_weather", call.Name)
+		as.Equal("weather", call.PluginName)
+		args := map[string]string{}
+		as.Nil(call.DecodeArguments(&args))
+		as.Equal("beijing", args["city"])
+
+		_, err = msg.Verbose()
+		as.NotNil(err)
+	})
+
+	t.Run("verbose knowledge recall", func(t *testing.T) {
+		msg := &Message{
+			Type:        MessageTypeVerbose,
+			ContentType: MessageContentTypeText,
+			Content:     `{"msg_type":"knowledge_recall","data":"{\"chunks\":[{\"slice\":\"coze is a bot platform\",\"score\":0.8,\"meta\":{\"dataset\":{\"id\":\"d1\",\"name\":\"docs\"},\"document\":{\"id\":\"doc1\",\"name\":\"intro.md\",\"source_type\":0}}}],\"ori_req\":\"what is coze\"}"}`,
+		}
+		verbose, err := msg.Verbose()
+		as.Nil(err)
+		as.Equal(MessageVerboseTypeKnowledgeRecall, verbose.MsgType)
+		recall, err := verbose.KnowledgeRecall()
+		as.Nil(err)
+		as.Equal("what is coze", recall.OriReq)
+		as.Len(recall.Chunks, 1)
+		as.Equal("coze is a bot platform", recall.Chunks[0].Slice)
+		as.Equal("docs", recall.Chunks[0].Meta.Dataset.Name)
+		as.Equal("intro.md", recall.Chunks[0].Meta.Document.Name)
+
+		_, err = (&MessageVerbose{MsgType: MessageVerboseTypeGenerateAnswerFinish}).KnowledgeRecall()
+		as.NotNil(err)
+	})
+}
@@ -32,6 +32,7 @@ type RawRequestReq struct {
 	NoNeedToken bool
 	Headers     map[string]string
 	options     []CozeAPIOption
+	streamFile  bool // stream the file through a pipe instead of buffering it, needs IsFile
 }
 
 func (r *core) rawRequest(ctx context.Context, req *RawRequestReq, resp interface{}) (err error) {
@@ -115,7 +116,7 @@ func (r *core) parseRawHttpRequest(ctx context.Context, req *RawRequestReq) (*ra
 	}
 
 	// 2 body
-	if err := rawHttpReq.parseRawRequestReqBody(req.Body, req.IsFile); err != nil {
+	if err := rawHttpReq.parseRawRequestReqBody(req.Body, req.IsFile, req.streamFile); err != nil {
 		return nil, err
 	}
 
@@ -132,8 +133,15 @@ func (r *core) doRequest(ctx context.Context, rawHttpReq *rawHttpRequest, realRe
 
 	req, err := http.NewRequestWithContext(ctx, rawHttpReq.Method, rawHttpReq.URL, rawHttpReq.Body)
 	if err != nil {
+		if closer, ok := rawHttpReq.Body.(io.Closer); ok {
+			_ = closer.Close()
+		}
 		return nil, "", err
 	}
+	if closer, ok := rawHttpReq.Body.(io.Closer); ok {
+		// stops the writer of a streamed body if the client did not read it to the end
+		defer closer.Close()
+	}
 	for k, v := range rawHttpReq.Headers {
 		req.Header.Set(k, v)
 	}
@@ -264,7 +272,7 @@ func (r *rawHttpRequest) parseHeader(ctx context.Context, ins *core, req *RawReq
 	return nil
 }
 
-func (r *rawHttpRequest) parseRawRequestReqBody(body interface{}, isFile bool) error {
+func (r *rawHttpRequest) parseRawRequestReqBody(body interface{}, isFile, streamFile bool) error {
 	var reader io.Reader
 	fileKey := ""
 	fileName := ""
@@ -321,7 +329,11 @@ func (r *rawHttpRequest) parseRawRequestReqBody(body interface{}, isFile bool) e
 	}
 
 	if isFile {
-		contentType, bod, err := newFileUploadRequest(fileData, fileKey, fileName, reader)
+		newBody := newFileUploadRequest
+		if streamFile {
+			newBody = newFileUploadStream
+		}
+		contentType, bod, err := newBody(fileData, fileKey, fileName, reader)
 		if err != nil {
 			return err
 		}
@@ -349,25 +361,40 @@ type rawHttpRequest struct {
 func newFileUploadRequest(params map[string]string, filekey, fileName string, reader io.Reader) (string, io.Reader, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
+	if err := writeFileUploadBody(writer, params, filekey, fileName, reader); err != nil {
+		return "", nil, err
+	}
+	return writer.FormDataContentType(), body, nil
+}
+
+// newFileUploadStream returns a multipart body streamed through a pipe, so the file is never
+// loaded into memory. The pipe is closed with the error of the writer, if any. The request has
+// no Content-Length, and doRequest closes the pipe once the request is done.
+func newFileUploadStream(params map[string]string, filekey, fileName string, reader io.Reader) (string, io.Reader, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeFileUploadBody(writer, params, filekey, fileName, reader))
+	}()
+	return writer.FormDataContentType(), pr, nil
+}
+
+func writeFileUploadBody(writer *multipart.Writer, params map[string]string, filekey, fileName string, reader io.Reader) error {
 	if reader != nil {
 		part, err := writer.CreateFormFile(filekey, fileName)
 		if err != nil {
-			return "", nil, err
+			return err
 		}
 		if _, err = io.Copy(part, reader); err != nil {
-			return "", nil, err
+			return err
 		}
 	}
 	for key, val := range params {
 		if err := writer.WriteField(key, val); err != nil {
-			return "", nil, err
+			return err
 		}
 	}
-	if err := writer.Close(); err != nil {
-		return "", nil, err
-	}
-
-	return writer.FormDataContentType(), body, nil
+	return writer.Close()
 }
 
 type readerSetter interface {
@@ -0,0 +1,284 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Session is a multi-turn chat between a user and a bot. The conversation is created lazily by the
+// first turn, and the messages of the current section are cached locally, see Messages and Sync.
+//
+// A Session is safe for concurrent use, but turns of the same session should not overlap.
+type Session struct {
+	chat          *chat
+	conversations *conversations
+	opt           SessionOption
+
+	mu             sync.Mutex
+	conversationID string
+	sectionID      string
+	messages       []*Message
+}
+
+// SessionOption represents the options of a Session
+type SessionOption struct {
+	// The ID of the bot the session chats with.
+	BotID string `json:"bot_id"`
+
+	// The user who chats with the bot.
+	UserID string `json:"user_id"`
+
+	// Optional: The ID of an existing conversation, a new conversation is created by the first turn
+	// if not set.
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	// Optional: Additional information of the conversation created by the session.
+	MetaData map[string]string `json:"meta_data,omitempty"`
+
+	// Optional: Specify a connector ID of the conversation and the chats.
+	ConnectorID string `json:"connector_id,omitempty"`
+
+	// Optional: The customized variables of every turn.
+	CustomVariables map[string]string `json:"custom_variables,omitempty"`
+}
+
+// sessionState is the serialized form of a Session
+type sessionState struct {
+	SessionOption
+	SectionID string     `json:"section_id,omitempty"`
+	Messages  []*Message `json:"messages,omitempty"`
+}
+
+// NewSession creates a session of opt.BotID and opt.UserID, no request is sent until the first turn.
+func NewSession(api *CozeAPI, opt *SessionOption) *Session {
+	s := &Session{
+		chat:          api.Chat,
+		conversations: api.Conversations,
+	}
+	if opt != nil {
+		s.opt = *opt
+		s.conversationID = opt.ConversationID
+	}
+	return s
+}
+
+// RestoreSession restores a session serialized by Session.MarshalJSON
+func RestoreSession(api *CozeAPI, data []byte) (*Session, error) {
+	s := NewSession(api, nil)
+	if err := s.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ConversationID returns the ID of the conversation, it is empty before the first turn of a new
+// session.
+func (s *Session) ConversationID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conversationID
+}
+
+// SectionID returns the ID of the current context section, if known.
+func (s *Session) SectionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sectionID
+}
+
+// Messages returns the cached questions and answers of the current section in chronological order.
+func (s *Session) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+// Send sends a turn and waits for the chat to finish, the questions and answers of the turn are
+// added to the message cache.
+func (s *Session) Send(ctx context.Context, messages ...*Message) (*ChatPoll, error) {
+	req, err := s.newChatReq(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.chat.CreateAndPoll(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.appendMessages(messages...)
+	s.appendMessages(resp.Messages...)
+	return resp, nil
+}
+
+// Stream sends a turn and streams the reply, the questions are added to the message cache when the
+// turn is sent and the answers when they are completed.
+func (s *Session) Stream(ctx context.Context, messages ...*Message) (Stream[ChatEvent], error) {
+	req, err := s.newChatReq(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := s.chat.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.appendMessages(messages...)
+	return &sessionStream{Stream: stream, session: s}, nil
+}
+
+// Sync replaces the message cache with the messages of the current section stored by the server.
+func (s *Session) Sync(ctx context.Context) error {
+	conversationID := s.ConversationID()
+	if conversationID == "" {
+		return nil
+	}
+	paged, err := s.conversations.Messages.List(ctx, &ListConversationsMessagesReq{
+		ConversationID: conversationID,
+		Order:          ptr("asc"),
+		Limit:          50,
+	})
+	if err != nil {
+		return err
+	}
+	var messages []*Message
+	for paged.Next() {
+		messages = append(messages, paged.Current())
+	}
+	if err := paged.Err(); err != nil {
+		return err
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedAt < messages[j].CreatedAt
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	for _, message := range messages {
+		if s.sectionID != "" && message.SectionID != "" && message.SectionID != s.sectionID {
+			continue
+		}
+		if isSessionMessage(message) {
+			s.messages = append(s.messages, message)
+		}
+	}
+	return nil
+}
+
+// Reset clears the context of the conversation, later turns start a new section and the message cache
+// is emptied.
+func (s *Session) Reset(ctx context.Context) error {
+	conversationID := s.ConversationID()
+	if conversationID == "" {
+		s.mu.Lock()
+		s.messages = nil
+		s.mu.Unlock()
+		return nil
+	}
+	resp, err := s.conversations.Clear(ctx, &ClearConversationsReq{ConversationID: conversationID})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sectionID = resp.ID
+	s.messages = nil
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, the session can be restored by RestoreSession.
+func (s *Session) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := sessionState{
+		SessionOption: s.opt,
+		SectionID:     s.sectionID,
+		Messages:      s.messages,
+	}
+	state.ConversationID = s.conversationID
+	return json.Marshal(state)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Session) UnmarshalJSON(data []byte) error {
+	state := sessionState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opt = state.SessionOption
+	s.conversationID = state.ConversationID
+	s.sectionID = state.SectionID
+	s.messages = state.Messages
+	return nil
+}
+
+func (s *Session) newChatReq(ctx context.Context, messages []*Message) (*CreateChatsReq, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("session turn requires at least one message")
+	}
+	conversationID, err := s.ensureConversation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &CreateChatsReq{
+		ConversationID:  conversationID,
+		BotID:           s.opt.BotID,
+		UserID:          s.opt.UserID,
+		Messages:        messages,
+		CustomVariables: s.opt.CustomVariables,
+		ConnectorID:     s.opt.ConnectorID,
+	}, nil
+}
+
+func (s *Session) ensureConversation(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conversationID != "" {
+		return s.conversationID, nil
+	}
+	resp, err := s.conversations.Create(ctx, &CreateConversationsReq{
+		BotID:       s.opt.BotID,
+		MetaData:    s.opt.MetaData,
+		ConnectorID: s.opt.ConnectorID,
+	})
+	if err != nil {
+		return "", err
+	}
+	s.conversationID = resp.ID
+	s.sectionID = resp.LastSectionID
+	return s.conversationID, nil
+}
+
+func (s *Session) appendMessages(messages ...*Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, message := range messages {
+		if isSessionMessage(message) {
+			s.messages = append(s.messages, message)
+		}
+	}
+}
+
+// isSessionMessage reports whether message is kept in the message cache, only questions and answers
+// are stored in the conversation by the server.
+func isSessionMessage(message *Message) bool {
+	return message != nil && (message.Type == MessageTypeQuestion || message.Type == MessageTypeAnswer)
+}
+
+// sessionStream caches the completed answers of a streaming turn
+type sessionStream struct {
+	Stream[ChatEvent]
+	session *Session
+}
+
+func (r *sessionStream) Recv() (*ChatEvent, error) {
+	event, err := r.Stream.Recv()
+	if err == nil && event != nil && event.Event == ChatEventConversationMessageCompleted {
+		r.session.appendMessages(event.Message)
+	}
+	return event, err
+}
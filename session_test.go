@@ -0,0 +1,136 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSessionAPI(fn func(req *http.Request) (*http.Response, error)) *CozeAPI {
+	core := newCoreWithTransport(newMockTransport(fn))
+	return &CozeAPI{Chat: newChats(core), Conversations: newConversations(core)}
+}
+
+func TestSession(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	created := 0
+	api := newSessionAPI(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/v1/conversation/create":
+			created++
+			return mockResponse(http.StatusOK, &createConversationsResp{
+				Conversation: &CreateConversationsResp{Conversation: Conversation{ID: "conversation1", LastSectionID: "section1"}},
+			})
+		case "/v3/chat":
+			as.Equal("conversation1", req.URL.Query().Get("conversation_id"))
+			body, _ := io.ReadAll(req.Body)
+			reqBody := &CreateChatsReq{}
+			as.Nil(json.Unmarshal(body, reqBody))
+			as.Equal("bot1", reqBody.BotID)
+			as.Equal("user1", reqBody.UserID)
+			if reqBody.Stream != nil && *reqBody.Stream {
+				return mockStreamResponse(`event: conversation.message.completed
+data: {"id":"msg3","conversation_id":"conversation1","role":"assistant","type":"answer","content":"fine"}
+
+event: conversation.message.completed
+data: {"id":"msg4","conversation_id":"conversation1","role":"assistant","type":"follow_up","content":"and you?"}
+
+event: done
+data:
+`)
+			}
+			return mockResponse(http.StatusOK, &createChatsResp{
+				Chat: &CreateChatsResp{Chat: Chat{ID: "chat1", ConversationID: "conversation1", Status: ChatStatusCompleted}},
+			})
+		case "/v3/chat/retrieve":
+			return mockResponse(http.StatusOK, &retrieveChatsResp{
+				Chat: &RetrieveChatsResp{Chat: Chat{ID: "chat1", ConversationID: "conversation1", Status: ChatStatusCompleted}},
+			})
+		case "/v3/chat/message/list":
+			return mockResponse(http.StatusOK, &listChatsMessagesResp{
+				ListChatsMessagesResp: &ListChatsMessagesResp{Messages: []*Message{
+					{ID: "msg2", Role: MessageRoleAssistant, Type: MessageTypeAnswer, Content: "hi"},
+					{ID: "function_call", Role: MessageRoleAssistant, Type: MessageTypeFunctionCall, Content: "{}"},
+				}},
+			})
+		case "/v1/conversation/message/list":
+			return mockResponse(http.StatusOK, &listConversationsMessagesResp{
+				ListConversationsMessagesResp: &ListConversationsMessagesResp{Messages: []*Message{
+					{ID: "msg1", Role: MessageRoleUser, Type: MessageTypeQuestion, Content: "hello", SectionID: "section1", CreatedAt: 1},
+					{ID: "msg0", Role: MessageRoleUser, Type: MessageTypeQuestion, Content: "old", SectionID: "section0", CreatedAt: 0},
+					{ID: "msg2", Role: MessageRoleAssistant, Type: MessageTypeAnswer, Content: "hi", SectionID: "section1", CreatedAt: 2},
+				}},
+			})
+		case "/v1/conversations/conversation1/clear":
+			return mockResponse(http.StatusOK, &clearConversationsResp{
+				Data: &ClearConversationsResp{ID: "section2", ConversationID: "conversation1"},
+			})
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	session := NewSession(api, &SessionOption{BotID: "bot1", UserID: "user1"})
+	as.Empty(session.ConversationID())
+
+	resp, err := session.Send(ctx, BuildUserQuestionText("hello", nil))
+	as.Nil(err)
+	as.Equal(ChatStatusCompleted, resp.Chat.Status)
+	as.Equal("conversation1", session.ConversationID())
+	as.Equal("section1", session.SectionID())
+	messages := session.Messages()
+	as.Len(messages, 2)
+	as.Equal("hello", messages[0].Content)
+	as.Equal("hi", messages[1].Content)
+
+	stream, err := session.Stream(ctx, BuildUserQuestionText("how are you", nil))
+	as.Nil(err)
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		as.Nil(err)
+	}
+	as.Nil(stream.Close())
+	messages = session.Messages()
+	as.Len(messages, 4)
+	as.Equal("fine", messages[3].Content)
+	as.Equal(1, created)
+
+	t.Run("sync", func(t *testing.T) {
+		as.Nil(session.Sync(ctx))
+		messages := session.Messages()
+		as.Len(messages, 2)
+		as.Equal("msg1", messages[0].ID)
+		as.Equal("msg2", messages[1].ID)
+	})
+
+	t.Run("marshal and restore", func(t *testing.T) {
+		data, err := json.Marshal(session)
+		as.Nil(err)
+		restored, err := RestoreSession(api, data)
+		as.Nil(err)
+		as.Equal("conversation1", restored.ConversationID())
+		as.Equal("section1", restored.SectionID())
+		as.Equal(session.Messages(), restored.Messages())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		as.Nil(session.Reset(ctx))
+		as.Equal("section2", session.SectionID())
+		as.Empty(session.Messages())
+	})
+
+	t.Run("empty turn", func(t *testing.T) {
+		_, err := session.Send(ctx)
+		as.NotNil(err)
+	})
+}
@@ -0,0 +1,335 @@
+package coze
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UsageTracker is the hook notified of the token usage of chats and workflow runs, see
+// WithUsageTracker.
+type UsageTracker interface {
+	// Check is called before a chat or workflow call starts, returning an error aborts the call.
+	Check(ctx context.Context, scope UsageScope) error
+	// Record is called when a chat or workflow call reports its usage.
+	Record(ctx context.Context, record *UsageRecord)
+}
+
+// UsageScope identifies whom a usage belongs to, empty fields are unknown.
+type UsageScope struct {
+	BotID          string `json:"bot_id,omitempty"`
+	WorkflowID     string `json:"workflow_id,omitempty"`
+	UserID         string `json:"user_id,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// match reports whether every non-empty field of s equals the field of other
+func (s UsageScope) match(other UsageScope) bool {
+	return (s.BotID == "" || s.BotID == other.BotID) &&
+		(s.WorkflowID == "" || s.WorkflowID == other.WorkflowID) &&
+		(s.UserID == "" || s.UserID == other.UserID) &&
+		(s.ConversationID == "" || s.ConversationID == other.ConversationID)
+}
+
+// UsageSource represents which API reported a usage
+type UsageSource string
+
+const (
+	UsageSourceChat           UsageSource = "chat"
+	UsageSourceChatStream     UsageSource = "chat_stream"
+	UsageSourceWebSocketChat  UsageSource = "websocket_chat"
+	UsageSourceWorkflow       UsageSource = "workflow"
+	UsageSourceWorkflowStream UsageSource = "workflow_stream"
+)
+
+// UsageRecord represents the usage reported by a single chat or workflow run
+type UsageRecord struct {
+	UsageScope
+	Source UsageSource `json:"source"`
+	// The ID of the chat, only set for chats.
+	ChatID string `json:"chat_id,omitempty"`
+	// The ID of the workflow execution, only set for workflow runs.
+	ExecuteID    string    `json:"execute_id,omitempty"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	TotalTokens  int       `json:"total_tokens"`
+	Cost         float64   `json:"cost"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UsageSummary represents the aggregated usage of several records
+type UsageSummary struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+func (s *UsageSummary) add(record *UsageRecord) {
+	s.Calls++
+	s.InputTokens += record.InputTokens
+	s.OutputTokens += record.OutputTokens
+	s.TotalTokens += record.TotalTokens
+	s.Cost += record.Cost
+}
+
+func (s *UsageSummary) merge(other *UsageSummary) {
+	s.Calls += other.Calls
+	s.InputTokens += other.InputTokens
+	s.OutputTokens += other.OutputTokens
+	s.TotalTokens += other.TotalTokens
+	s.Cost += other.Cost
+}
+
+// UsageDimension represents a field of UsageScope usage can be grouped by
+type UsageDimension string
+
+const (
+	UsageDimensionBot          UsageDimension = "bot_id"
+	UsageDimensionWorkflow     UsageDimension = "workflow_id"
+	UsageDimensionUser         UsageDimension = "user_id"
+	UsageDimensionConversation UsageDimension = "conversation_id"
+)
+
+func (s UsageScope) get(dimension UsageDimension) string {
+	switch dimension {
+	case UsageDimensionBot:
+		return s.BotID
+	case UsageDimensionWorkflow:
+		return s.WorkflowID
+	case UsageDimensionUser:
+		return s.UserID
+	case UsageDimensionConversation:
+		return s.ConversationID
+	default:
+		return ""
+	}
+}
+
+// UsageBudget limits the usage of a scope, the empty fields of Scope match any value and a zero
+// limit is not enforced.
+type UsageBudget struct {
+	Scope     UsageScope
+	MaxTokens int
+	MaxCost   float64
+}
+
+// UsageBudgetExceededError is returned by calls aborted because a budget is exceeded
+type UsageBudgetExceededError struct {
+	Budget *UsageBudget
+	Usage  UsageSummary
+}
+
+// Error implements the error interface
+func (e *UsageBudgetExceededError) Error() string {
+	return fmt.Sprintf("usage budget exceeded, scope=%s, tokens=%d/%d, cost=%g/%g",
+		mustToJson(e.Budget.Scope), e.Usage.TotalTokens, e.Budget.MaxTokens, e.Usage.Cost, e.Budget.MaxCost)
+}
+
+// AsUsageBudgetExceededError checks if the error is of type UsageBudgetExceededError
+func AsUsageBudgetExceededError(err error) (*UsageBudgetExceededError, bool) {
+	var budgetErr *UsageBudgetExceededError
+	if errors.As(err, &budgetErr) {
+		return budgetErr, true
+	}
+	return nil, false
+}
+
+// UsageAggregator is an in-memory UsageTracker, it aggregates usage by scope and source and
+// enforces budgets.
+type UsageAggregator struct {
+	mu      sync.RWMutex
+	usage   map[usageKey]*UsageSummary
+	budgets []*UsageBudget
+}
+
+type usageKey struct {
+	UsageScope
+	Source UsageSource
+}
+
+var _ UsageTracker = (*UsageAggregator)(nil)
+
+// NewUsageAggregator creates an empty UsageAggregator
+func NewUsageAggregator() *UsageAggregator {
+	return &UsageAggregator{usage: map[usageKey]*UsageSummary{}}
+}
+
+// SetBudget adds a budget, replacing the budget of the same scope
+func (a *UsageAggregator) SetBudget(budget *UsageBudget) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, b := range a.budgets {
+		if b.Scope == budget.Scope {
+			a.budgets[i] = budget
+			return
+		}
+	}
+	a.budgets = append(a.budgets, budget)
+}
+
+// Check implements UsageTracker, it returns *UsageBudgetExceededError when a budget matching scope
+// is exceeded.
+func (a *UsageAggregator) Check(ctx context.Context, scope UsageScope) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, budget := range a.budgets {
+		if !budget.Scope.match(scope) {
+			continue
+		}
+		usage := a.query(budget.Scope)
+		if (budget.MaxTokens > 0 && usage.TotalTokens >= budget.MaxTokens) ||
+			(budget.MaxCost > 0 && usage.Cost >= budget.MaxCost) {
+			return &UsageBudgetExceededError{Budget: budget, Usage: usage}
+		}
+	}
+	return nil
+}
+
+// Record implements UsageTracker
+func (a *UsageAggregator) Record(ctx context.Context, record *UsageRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := usageKey{UsageScope: record.UsageScope, Source: record.Source}
+	summary, ok := a.usage[key]
+	if !ok {
+		summary = &UsageSummary{}
+		a.usage[key] = summary
+	}
+	summary.add(record)
+}
+
+// Query returns the usage of the records matching filter, the empty fields of filter match any value.
+func (a *UsageAggregator) Query(filter UsageScope) UsageSummary {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.query(filter)
+}
+
+// QueryBy returns the usage of the records matching filter grouped by dimension.
+func (a *UsageAggregator) QueryBy(dimension UsageDimension, filter UsageScope) map[string]UsageSummary {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	res := map[string]UsageSummary{}
+	for key, summary := range a.usage {
+		if !filter.match(key.UsageScope) {
+			continue
+		}
+		group := res[key.get(dimension)]
+		group.merge(summary)
+		res[key.get(dimension)] = group
+	}
+	return res
+}
+
+// Reset drops all recorded usage, budgets are kept.
+func (a *UsageAggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usage = map[usageKey]*UsageSummary{}
+}
+
+func (a *UsageAggregator) query(filter UsageScope) UsageSummary {
+	res := UsageSummary{}
+	for key, summary := range a.usage {
+		if !filter.match(key.UsageScope) {
+			continue
+		}
+		res.merge(summary)
+	}
+	return res
+}
+
+func (r *core) checkUsage(ctx context.Context, scope UsageScope) error {
+	if r.usageTracker == nil {
+		return nil
+	}
+	return r.usageTracker.Check(ctx, scope)
+}
+
+func (r *core) recordUsage(ctx context.Context, record *UsageRecord) {
+	if r.usageTracker == nil {
+		return
+	}
+	record.CreatedAt = time.Now()
+	r.usageTracker.Record(ctx, record)
+}
+
+func (r *core) recordChatUsage(ctx context.Context, source UsageSource, chat *Chat, userID string) {
+	if chat == nil || chat.Usage == nil {
+		return
+	}
+	r.recordUsage(ctx, &UsageRecord{
+		UsageScope: UsageScope{
+			BotID:          chat.BotID,
+			UserID:         userID,
+			ConversationID: chat.ConversationID,
+		},
+		Source:       source,
+		ChatID:       chat.ID,
+		InputTokens:  chat.Usage.InputCount,
+		OutputTokens: chat.Usage.OutputCount,
+		TotalTokens:  chat.Usage.TokenCount,
+	})
+}
+
+// chatUsageProcessor wraps parseChatEvent to record the usage of conversation.chat.completed
+func (r *core) chatUsageProcessor(userID string) eventProcessor[ChatEvent] {
+	if r.usageTracker == nil {
+		return parseChatEvent
+	}
+	return func(ctx context.Context, core *core, line []byte, reader *bufio.Reader) (*ChatEvent, bool, error) {
+		event, isDone, err := parseChatEvent(ctx, core, line, reader)
+		if err == nil && event != nil && event.Event == ChatEventConversationChatCompleted {
+			r.recordChatUsage(ctx, UsageSourceChatStream, event.Chat, userID)
+		}
+		return event, isDone, err
+	}
+}
+
+// workflowUsageProcessor wraps parseWorkflowEvent to record the usage carried by message events
+func (r *core) workflowUsageProcessor(scope UsageScope) eventProcessor[WorkflowEvent] {
+	if r.usageTracker == nil {
+		return parseWorkflowEvent
+	}
+	return func(ctx context.Context, core *core, line []byte, reader *bufio.Reader) (*WorkflowEvent, bool, error) {
+		event, isDone, err := parseWorkflowEvent(ctx, core, line, reader)
+		if err == nil && event != nil && event.Message != nil && event.Message.Usage != nil {
+			r.recordUsage(ctx, &UsageRecord{
+				UsageScope:   scope,
+				Source:       UsageSourceWorkflowStream,
+				InputTokens:  event.Message.Usage.InputCount,
+				OutputTokens: event.Message.Usage.OutputCount,
+				TotalTokens:  event.Message.Usage.TokenCount,
+			})
+		}
+		return event, isDone, err
+	}
+}
+
+func (r *RunWorkflowsReq) usageScope() UsageScope {
+	return UsageScope{
+		BotID:      r.BotID,
+		WorkflowID: r.WorkflowID,
+		UserID:     r.Ext["user_id"],
+	}
+}
+
+func (r *core) recordWorkflowUsage(ctx context.Context, req *RunWorkflowsReq, resp *RunWorkflowsResp) {
+	if resp == nil || req.IsAsync {
+		return
+	}
+	cost, _ := strconv.ParseFloat(resp.Cost, 64)
+	r.recordUsage(ctx, &UsageRecord{
+		UsageScope:  req.usageScope(),
+		Source:      UsageSourceWorkflow,
+		ExecuteID:   resp.ExecuteID,
+		TotalTokens: resp.Token,
+		Cost:        cost,
+	})
+}
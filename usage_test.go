@@ -0,0 +1,172 @@
+package coze
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCoreWithUsageTracker(tracker UsageTracker, fn func(req *http.Request) (*http.Response, error)) *core {
+	core := newCoreWithTransport(newMockTransport(fn))
+	core.usageTracker = tracker
+	return core
+}
+
+// countingUsageTracker counts the Check calls
+type countingUsageTracker struct {
+	checks int32
+}
+
+func (t *countingUsageTracker) Check(ctx context.Context, scope UsageScope) error {
+	atomic.AddInt32(&t.checks, 1)
+	return nil
+}
+
+func (t *countingUsageTracker) Record(ctx context.Context, record *UsageRecord) {}
+
+func TestUsageAggregator(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	aggregator := NewUsageAggregator()
+	aggregator.Record(ctx, &UsageRecord{
+		UsageScope:  UsageScope{BotID: "bot1", UserID: "user1"},
+		Source:      UsageSourceChat,
+		TotalTokens: 10,
+		Cost:        0.1,
+	})
+	aggregator.Record(ctx, &UsageRecord{
+		UsageScope:  UsageScope{BotID: "bot1", UserID: "user2"},
+		Source:      UsageSourceChatStream,
+		TotalTokens: 20,
+	})
+	aggregator.Record(ctx, &UsageRecord{
+		UsageScope:  UsageScope{WorkflowID: "workflow1", UserID: "user1"},
+		Source:      UsageSourceWorkflow,
+		TotalTokens: 5,
+		Cost:        0.2,
+	})
+
+	total := aggregator.Query(UsageScope{})
+	as.Equal(3, total.Calls)
+	as.Equal(35, total.TotalTokens)
+	as.InDelta(0.3, total.Cost, 1e-9)
+	as.Equal(30, aggregator.Query(UsageScope{BotID: "bot1"}).TotalTokens)
+
+	byUser := aggregator.QueryBy(UsageDimensionUser, UsageScope{})
+	as.Equal(15, byUser["user1"].TotalTokens)
+	as.Equal(20, byUser["user2"].TotalTokens)
+
+	t.Run("budget", func(t *testing.T) {
+		aggregator.SetBudget(&UsageBudget{Scope: UsageScope{UserID: "user1"}, MaxTokens: 100})
+		as.Nil(aggregator.Check(ctx, UsageScope{BotID: "bot1", UserID: "user1"}))
+
+		aggregator.SetBudget(&UsageBudget{Scope: UsageScope{UserID: "user1"}, MaxCost: 0.3})
+		err := aggregator.Check(ctx, UsageScope{BotID: "bot1", UserID: "user1"})
+		budgetErr, ok := AsUsageBudgetExceededError(err)
+		as.True(ok)
+		as.InDelta(0.3, budgetErr.Usage.Cost, 1e-9)
+		as.Nil(aggregator.Check(ctx, UsageScope{BotID: "bot1", UserID: "user2"}))
+
+		aggregator.Reset()
+		as.Nil(aggregator.Check(ctx, UsageScope{BotID: "bot1", UserID: "user1"}))
+	})
+}
+
+func TestUsageTracking(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("chat create", func(t *testing.T) {
+		aggregator := NewUsageAggregator()
+		chats := newChats(newCoreWithUsageTracker(aggregator, func(req *http.Request) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &createChatsResp{
+				Chat: &CreateChatsResp{Chat: Chat{
+					ID:             "chat1",
+					ConversationID: "conversation1",
+					BotID:          "bot1",
+					Status:         ChatStatusCompleted,
+					Usage:          &ChatUsage{TokenCount: 30, OutputCount: 10, InputCount: 20},
+				}},
+			})
+		}))
+		_, err := chats.Create(ctx, &CreateChatsReq{BotID: "bot1", UserID: "user1"})
+		as.Nil(err)
+		summary := aggregator.Query(UsageScope{UserID: "user1", ConversationID: "conversation1"})
+		as.Equal(1, summary.Calls)
+		as.Equal(30, summary.TotalTokens)
+		as.Equal(20, summary.InputTokens)
+
+		aggregator.SetBudget(&UsageBudget{Scope: UsageScope{BotID: "bot1"}, MaxTokens: 30})
+		_, err = chats.Create(ctx, &CreateChatsReq{BotID: "bot1", UserID: "user1"})
+		_, ok := AsUsageBudgetExceededError(err)
+		as.True(ok)
+		_, err = chats.Stream(ctx, &CreateChatsReq{BotID: "bot1", UserID: "user1"})
+		_, ok = AsUsageBudgetExceededError(err)
+		as.True(ok)
+	})
+
+	t.Run("chat stream", func(t *testing.T) {
+		aggregator := NewUsageAggregator()
+		chats := newChats(newCoreWithUsageTracker(aggregator, func(req *http.Request) (*http.Response, error) {
+			return mockStreamResponse(`event: conversation.chat.completed
+data: {"id":"chat1","conversation_id":"conversation1","bot_id":"bot1","status":"completed","usage":{"token_count":12,"output_count":4,"input_count":8}}
+
+event: done
+data:
+`)
+		}))
+		stream, err := chats.Stream(ctx, &CreateChatsReq{BotID: "bot1", UserID: "user1"})
+		as.Nil(err)
+		defer stream.Close()
+		for {
+			_, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			as.Nil(err)
+		}
+		usage := aggregator.QueryBy(UsageDimensionUser, UsageScope{BotID: "bot1"})
+		as.Equal(12, usage["user1"].TotalTokens)
+		as.Equal(4, usage["user1"].OutputTokens)
+	})
+
+	t.Run("workflow create", func(t *testing.T) {
+		aggregator := NewUsageAggregator()
+		runs := newWorkflowRun(newCoreWithUsageTracker(aggregator, func(req *http.Request) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{
+				RunWorkflowsResp: &RunWorkflowsResp{ExecuteID: "exec1", Data: "{}", Token: 50, Cost: "0.25"},
+			})
+		}))
+		_, err := runs.Create(ctx, &RunWorkflowsReq{WorkflowID: "workflow1", Ext: map[string]string{"user_id": "user1"}})
+		as.Nil(err)
+		summary := aggregator.Query(UsageScope{WorkflowID: "workflow1", UserID: "user1"})
+		as.Equal(50, summary.TotalTokens)
+		as.InDelta(0.25, summary.Cost, 1e-9)
+	})
+
+	t.Run("websocket chat checks before responses", func(t *testing.T) {
+		tracker := &countingUsageTracker{}
+		core := newFakeWebSocketCore()
+		core.usageTracker = tracker
+		conn := newFakeWebSocketConn()
+		chat := newWebsocketChatClient(ctx, core, &CreateWebsocketChatReq{
+			BotID:                 ptr("bot1"),
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+		})
+		as.Nil(chat.Connect())
+		defer chat.Close()
+		checks := atomic.LoadInt32(&tracker.checks)
+
+		as.Nil(chat.ChatUpdate(&WebSocketChatUpdateEventData{ChatConfig: &WebSocketChatConfig{UserID: ptr("user1")}}))
+		for i := 0; i < 3; i++ {
+			as.Nil(chat.InputAudioBufferAppend(&WebSocketInputAudioBufferAppendEventData{Delta: []byte{1}}))
+		}
+		as.Nil(chat.InputAudioBufferComplete(&WebSocketInputAudioBufferCompleteEventData{}))
+		as.Equal(checks+2, atomic.LoadInt32(&tracker.checks))
+	})
+}
@@ -0,0 +1,403 @@
+package coze
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AudioSink receives the output audio of a WebSocket client in the order of the events, add it by
+// AddAudioSink of WebSocketAudioSpeech or WebSocketChat.
+type AudioSink interface {
+	// Write receives the audio of speech.audio.update or conversation.audio.delta
+	Write(p []byte) (int, error)
+	// StartSentence receives the text of conversation.audio.sentence_start, the following audio is
+	// the speech of it
+	StartSentence(text string) error
+	// CompleteAudio is called on speech.audio.completed or conversation.audio.completed
+	CompleteAudio() error
+}
+
+// audioFormatSetter is an AudioSink depending on the format of the audio, the format is set by
+// speech.updated and chat.updated
+type audioFormatSetter interface {
+	SetFormat(format PCMFormat) error
+}
+
+// audioErrorCloser is an AudioSink closed with the error of the connection when it's lost
+type audioErrorCloser interface {
+	CloseWithError(err error) error
+}
+
+// AddAudioSink passes the audio of speech.audio.update to sink, it returns the func removing it
+func (c *WebSocketAudioSpeech) AddAudioSink(sink AudioSink) (remove func()) {
+	return addAudioSink(c.ws, sink, map[WebSocketEventType]EventHandler{
+		WebSocketEventTypeSpeechUpdated: func(event IWebSocketEvent) error {
+			if data := event.(*WebSocketSpeechUpdatedEvent).Data; data != nil {
+				return setAudioSinkFormat(sink, data.OutputAudio)
+			}
+			return nil
+		},
+		WebSocketEventTypeSpeechAudioUpdate: func(event IWebSocketEvent) error {
+			if data := event.(*WebSocketSpeechAudioUpdateEvent).Data; data != nil {
+				_, err := sink.Write(data.Delta)
+				return err
+			}
+			return nil
+		},
+		WebSocketEventTypeSpeechAudioCompleted: func(event IWebSocketEvent) error {
+			return sink.CompleteAudio()
+		},
+	})
+}
+
+// AddAudioSink passes the audio of conversation.audio.delta and the sentences of
+// conversation.audio.sentence_start to sink, it returns the func removing it
+func (c *WebSocketChat) AddAudioSink(sink AudioSink) (remove func()) {
+	return addAudioSink(c.ws, sink, map[WebSocketEventType]EventHandler{
+		WebSocketEventTypeChatUpdated: func(event IWebSocketEvent) error {
+			if data := event.(*WebSocketChatUpdatedEvent).Data; data != nil {
+				return setAudioSinkFormat(sink, data.OutputAudio)
+			}
+			return nil
+		},
+		WebSocketEventTypeConversationAudioSentenceStart: func(event IWebSocketEvent) error {
+			if data := event.(*WebSocketConversationAudioSentenceStartEvent).Data; data != nil {
+				return sink.StartSentence(data.Text)
+			}
+			return nil
+		},
+		WebSocketEventTypeConversationAudioDelta: func(event IWebSocketEvent) error {
+			if data := event.(*WebSocketConversationAudioDeltaEvent).Data; data != nil {
+				_, err := sink.Write(data.Content)
+				return err
+			}
+			return nil
+		},
+		WebSocketEventTypeConversationAudioCompleted: func(event IWebSocketEvent) error {
+			return sink.CompleteAudio()
+		},
+	})
+}
+
+func addAudioSink(ws *websocketClient, sink AudioSink, handlers map[WebSocketEventType]EventHandler) func() {
+	if closer, ok := sink.(audioErrorCloser); ok {
+		handlers[WebSocketEventTypeClosed] = func(event IWebSocketEvent) error {
+			err := errors.New("websocket closed")
+			if data := event.(*WebSocketClosedEvent).Data; data != nil {
+				err = fmt.Errorf("websocket closed: %d %s", data.Code, data.Reason)
+			}
+			return closer.CloseWithError(err)
+		}
+	}
+	removes := make([]func(), 0, len(handlers))
+	for eventType, handler := range handlers {
+		removes = append(removes, ws.AddEventHandler(eventType, handler))
+	}
+	return func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}
+}
+
+func setAudioSinkFormat(sink AudioSink, outputAudio *WebSocketOutputAudio) error {
+	setter, ok := sink.(audioFormatSetter)
+	if !ok || outputAudio == nil {
+		return nil
+	}
+	format, err := outputAudio.PCMFormat()
+	if err != nil {
+		return err
+	}
+	return setter.SetFormat(format)
+}
+
+// AudioWAVSink writes the audio into a wav. The header is written before the first audio, its
+// sizes are updated on the completion of the audio if w is an io.WriteSeeker like *os.File,
+// otherwise they are left unknown. The audio of the following replies is appended to the wav.
+//
+//	f, _ := os.Create("reply.wav")
+//	defer f.Close()
+//	chat.AddAudioSink(coze.NewAudioWAVSink(f, coze.PCMFormat{}))
+type AudioWAVSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format PCMFormat
+	header bool  // the header is written
+	offset int64 // the offset of the header in w
+	size   int64 // the bytes of the audio
+}
+
+// NewAudioWAVSink creates an AudioWAVSink writing w, format is the format of the output audio,
+// it's replaced by the one of speech.updated or chat.updated before the first audio
+func NewAudioWAVSink(w io.Writer, format PCMFormat) *AudioWAVSink {
+	return &AudioWAVSink{w: w, format: format.withDefaults()}
+}
+
+// SetFormat sets the format of the audio, it fails if the header is written in another format
+func (s *AudioWAVSink) SetFormat(format PCMFormat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	format = format.withDefaults()
+	if s.header && format != s.format {
+		return fmt.Errorf("wav header is written in %+v, can't change to %+v", s.format, format)
+	}
+	s.format = format
+	return nil
+}
+
+// Format returns the format of the audio
+func (s *AudioWAVSink) Format() PCMFormat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.format
+}
+
+// Duration returns the duration of the audio written so far
+func (s *AudioWAVSink) Duration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.format.Duration(s.size)
+}
+
+func (s *AudioWAVSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeHeader(); err != nil {
+		return 0, err
+	}
+	n, err := s.w.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// StartSentence does nothing, the sentences of a wav are continuous
+func (s *AudioWAVSink) StartSentence(text string) error {
+	return nil
+}
+
+// CompleteAudio writes the sizes of the audio into the header if w is an io.WriteSeeker
+func (s *AudioWAVSink) CompleteAudio() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	seeker, ok := s.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	end, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := seeker.Seek(s.offset, io.SeekStart); err != nil {
+		return err
+	}
+	if err := WriteWAVHeader(seeker, s.format, s.size); err != nil {
+		return err
+	}
+	_, err = seeker.Seek(end, io.SeekStart)
+	return err
+}
+
+func (s *AudioWAVSink) writeHeader() error {
+	if s.header {
+		return nil
+	}
+	if seeker, ok := s.w.(io.WriteSeeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		s.offset = offset
+	}
+	if err := WriteWAVHeader(s.w, s.format, -1); err != nil {
+		return err
+	}
+	s.header = true
+	return nil
+}
+
+// AudioPipe is an AudioSink read as an io.Reader, like the input of a player or an encoder. Unlike
+// io.Pipe, Write doesn't wait for Read, so a slow reader doesn't hold up the events of the client.
+// The audio of all the replies is read continuously, Read returns io.EOF after the pipe is closed
+// by Close, or the error of the connection if it's lost.
+//
+//	pipe := coze.NewAudioPipe()
+//	chat.AddAudioSink(pipe)
+//	go play(pipe)
+//	...
+//	chat.Close()
+//	pipe.Close()
+type AudioPipe struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	err  error // returned by Read after buf is drained
+}
+
+// NewAudioPipe creates an AudioPipe
+func NewAudioPipe() *AudioPipe {
+	p := &AudioPipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Read reads the audio, it waits for more audio until the pipe is closed
+func (p *AudioPipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.buf.Len() == 0 && p.err == nil {
+		p.cond.Wait()
+	}
+	if p.buf.Len() > 0 {
+		return p.buf.Read(b)
+	}
+	return 0, p.err
+}
+
+func (p *AudioPipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return 0, io.ErrClosedPipe
+	}
+	n, _ := p.buf.Write(b)
+	p.cond.Broadcast()
+	return n, nil
+}
+
+// StartSentence does nothing, the sentences are read continuously
+func (p *AudioPipe) StartSentence(text string) error {
+	return nil
+}
+
+// CompleteAudio does nothing, the pipe is kept open for the audio of the following replies
+func (p *AudioPipe) CompleteAudio() error {
+	return nil
+}
+
+// Close closes the pipe, Read returns io.EOF after the buffered audio. Call it after the client is
+// closed, the pipe is closed with the error of the connection if it's lost.
+func (p *AudioPipe) Close() error {
+	return p.CloseWithError(nil)
+}
+
+// CloseWithError closes the pipe, Read returns err after the buffered audio, or io.EOF if err is
+// nil. Only the first close takes effect.
+func (p *AudioPipe) CloseWithError(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	p.err = err
+	p.cond.Broadcast()
+	return nil
+}
+
+// AudioSentence is the audio of a sentence of conversation.audio.sentence_start
+type AudioSentence struct {
+	Text  string
+	Audio []byte
+}
+
+// AudioSentenceSink splits the audio into sentences by conversation.audio.sentence_start, like
+// showing the subtitle of the audio being played. A sentence ends at the start of the next one or
+// on the completion of the audio. The audio without a sentence_start before it, like the audio of
+// speech.audio.update, is a sentence without text.
+//
+// The ended sentences are retained until they are taken by TakeSentences, turn it off by
+// SetRetention on a long-lived client consuming the sentences by onSentence.
+type AudioSentenceSink struct {
+	mu         sync.Mutex
+	onSentence func(sentence *AudioSentence) error
+	current    *AudioSentence
+	sentences  []*AudioSentence
+	noRetain   bool
+}
+
+// NewAudioSentenceSink creates an AudioSentenceSink, onSentence is called with each sentence when
+// it ends, it may be nil
+func NewAudioSentenceSink(onSentence func(sentence *AudioSentence) error) *AudioSentenceSink {
+	return &AudioSentenceSink{onSentence: onSentence}
+}
+
+// Sentences returns the retained sentences
+func (s *AudioSentenceSink) Sentences() []*AudioSentence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*AudioSentence(nil), s.sentences...)
+}
+
+// TakeSentences returns the retained sentences and removes them from the sink
+func (s *AudioSentenceSink) TakeSentences() []*AudioSentence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sentences := s.sentences
+	s.sentences = nil
+	return sentences
+}
+
+// SetRetention sets whether the ended sentences are retained, default is true. The retained
+// sentences are dropped when it's turned off.
+func (s *AudioSentenceSink) SetRetention(retain bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noRetain = !retain
+	if s.noRetain {
+		s.sentences = nil
+	}
+}
+
+func (s *AudioSentenceSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = &AudioSentence{}
+	}
+	s.current.Audio = append(s.current.Audio, p...)
+	return len(p), nil
+}
+
+// StartSentence ends the current sentence and starts a new one of text
+func (s *AudioSentenceSink) StartSentence(text string) error {
+	s.mu.Lock()
+	ended := s.end()
+	s.current = &AudioSentence{Text: text}
+	s.mu.Unlock()
+	return s.notify(ended)
+}
+
+// CompleteAudio ends the current sentence
+func (s *AudioSentenceSink) CompleteAudio() error {
+	s.mu.Lock()
+	ended := s.end()
+	s.mu.Unlock()
+	return s.notify(ended)
+}
+
+func (s *AudioSentenceSink) end() *AudioSentence {
+	ended := s.current
+	if ended != nil && !s.noRetain {
+		s.sentences = append(s.sentences, ended)
+	}
+	s.current = nil
+	return ended
+}
+
+// notify is called without the lock, so onSentence may call Sentences
+func (s *AudioSentenceSink) notify(sentence *AudioSentence) error {
+	if sentence == nil || s.onSentence == nil {
+		return nil
+	}
+	return s.onSentence(sentence)
+}
@@ -0,0 +1,268 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ AudioSink = (*AudioWAVSink)(nil)
+	_ AudioSink = (*AudioPipe)(nil)
+	_ AudioSink = (*AudioSentenceSink)(nil)
+)
+
+func TestWriteWAVHeader(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("round trip", func(t *testing.T) {
+		format := PCMFormat{SampleRate: 16000, Channels: 2, BitDepth: 16}
+		buf := &bytes.Buffer{}
+		as.Nil(WriteWAVHeader(buf, format, 8))
+		as.Equal(buildWAV(format, nil, false)[8:36], buf.Bytes()[8:36])
+		actual, size, err := readWAVHeader(buf)
+		as.Nil(err)
+		as.Equal(format, actual)
+		as.Equal(int64(8), size)
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		as.Nil(WriteWAVHeader(buf, PCMFormat{}, -1))
+		actual, size, err := readWAVHeader(buf)
+		as.Nil(err)
+		as.Equal(PCMFormat{SampleRate: 24000, Channels: 1, BitDepth: 16}, actual)
+		as.Equal(int64(-1), size)
+	})
+
+	t.Run("output audio", func(t *testing.T) {
+		format, err := (&WebSocketOutputAudio{
+			Codec:     ptr("pcm"),
+			PCMConfig: &WebSocketPCMConfig{SampleRate: ptr(16000)},
+		}).PCMFormat()
+		as.Nil(err)
+		as.Equal(PCMFormat{SampleRate: 16000, Channels: 1, BitDepth: 16}, format)
+
+		format, err = (*WebSocketOutputAudio)(nil).PCMFormat()
+		as.Nil(err)
+		as.Equal(24000, format.SampleRate)
+
+		_, err = (&WebSocketOutputAudio{Codec: ptr("opus")}).PCMFormat()
+		as.EqualError(err, "unsupported output audio codec: opus")
+	})
+}
+
+func TestAudioWAVSink(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("file", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "audio.wav")
+		f, err := os.Create(name)
+		as.Nil(err)
+		defer f.Close()
+
+		sink := NewAudioWAVSink(f, PCMFormat{})
+		as.Nil(sink.SetFormat(PCMFormat{SampleRate: 16000}))
+		_, err = sink.Write([]byte{1, 2, 3, 4})
+		as.Nil(err)
+		_, err = sink.Write([]byte{5, 6})
+		as.Nil(err)
+		as.Nil(sink.CompleteAudio())
+		as.NotNil(sink.SetFormat(PCMFormat{SampleRate: 8000}))
+		as.Nil(sink.SetFormat(PCMFormat{SampleRate: 16000}))
+
+		// the audio of the next reply is appended
+		_, err = sink.Write([]byte{7, 8})
+		as.Nil(err)
+		as.Nil(sink.CompleteAudio())
+		as.Equal(250*time.Microsecond, sink.Duration())
+
+		content, err := os.ReadFile(name)
+		as.Nil(err)
+		r := bytes.NewReader(content)
+		format, size, err := readWAVHeader(r)
+		as.Nil(err)
+		as.Equal(16000, format.SampleRate)
+		as.Equal(int64(8), size)
+		audio, _ := io.ReadAll(r)
+		as.Equal([]byte{1, 2, 3, 4, 5, 6, 7, 8}, audio)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		sink := NewAudioWAVSink(buf, PCMFormat{})
+		_, err := sink.Write([]byte{1, 2})
+		as.Nil(err)
+		as.Nil(sink.CompleteAudio())
+		format, size, err := readWAVHeader(buf)
+		as.Nil(err)
+		as.Equal(24000, format.SampleRate)
+		as.Equal(int64(-1), size)
+		as.Equal([]byte{1, 2}, buf.Bytes())
+	})
+}
+
+func TestAudioPipe(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("read", func(t *testing.T) {
+		pipe := NewAudioPipe()
+		done := make(chan []byte)
+		go func() {
+			audio, err := io.ReadAll(pipe)
+			as.Nil(err)
+			done <- audio
+		}()
+		for i := 0; i < 3; i++ {
+			_, err := pipe.Write([]byte{byte(i)})
+			as.Nil(err)
+		}
+		as.Nil(pipe.CompleteAudio())
+		// the audio of the next reply
+		_, err := pipe.Write([]byte{3})
+		as.Nil(err)
+		as.Nil(pipe.CompleteAudio())
+		as.Nil(pipe.Close())
+		as.Equal([]byte{0, 1, 2, 3}, <-done)
+
+		_, err = pipe.Write([]byte{4})
+		as.ErrorIs(err, io.ErrClosedPipe)
+	})
+
+	t.Run("close with error", func(t *testing.T) {
+		pipe := NewAudioPipe()
+		_, _ = pipe.Write([]byte{1})
+		as.Nil(pipe.CloseWithError(errors.New("lost")))
+		as.Nil(pipe.Close())
+		audio, err := io.ReadAll(pipe)
+		as.Equal([]byte{1}, audio)
+		as.EqualError(err, "lost")
+	})
+}
+
+func TestAudioSentenceSink(t *testing.T) {
+	as := assert.New(t)
+
+	var texts []string
+	sink := NewAudioSentenceSink(func(sentence *AudioSentence) error {
+		texts = append(texts, sentence.Text)
+		return nil
+	})
+	_, _ = sink.Write([]byte{0})
+	as.Nil(sink.StartSentence("hello"))
+	_, _ = sink.Write([]byte{1})
+	_, _ = sink.Write([]byte{2})
+	as.Nil(sink.StartSentence("world"))
+	_, _ = sink.Write([]byte{3})
+	as.Equal([]string{"", "hello"}, texts)
+	as.Nil(sink.CompleteAudio())
+	as.Nil(sink.CompleteAudio())
+
+	as.Equal([]string{"", "hello", "world"}, texts)
+	as.Equal([]*AudioSentence{
+		{Audio: []byte{0}},
+		{Text: "hello", Audio: []byte{1, 2}},
+		{Text: "world", Audio: []byte{3}},
+	}, sink.Sentences())
+
+	as.Len(sink.TakeSentences(), 3)
+	as.Empty(sink.Sentences())
+
+	sink.SetRetention(false)
+	as.Nil(sink.StartSentence("again"))
+	_, _ = sink.Write([]byte{4})
+	as.Nil(sink.CompleteAudio())
+	as.Equal([]string{"", "hello", "world", "again"}, texts)
+	as.Empty(sink.Sentences())
+}
+
+func TestWebSocketAddAudioSink(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("chat", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newWebsocketChatClient(ctx, newFakeWebSocketCore(), &CreateWebsocketChatReq{
+			BotID:                 ptr("bot"),
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+		})
+		name := filepath.Join(t.TempDir(), "chat.wav")
+		f, err := os.Create(name)
+		as.Nil(err)
+		defer f.Close()
+		wav := NewAudioWAVSink(f, PCMFormat{})
+		sentences := NewAudioSentenceSink(nil)
+		pipe := NewAudioPipe()
+		chat.AddAudioSink(wav)
+		chat.AddAudioSink(sentences)
+		remove := chat.AddAudioSink(pipe)
+		as.Nil(chat.Connect())
+		defer chat.Close()
+
+		delta := func(audio []byte) []byte {
+			return []byte(fmt.Sprintf(`{"event_type":"conversation.audio.delta","data":{"content":"%s","content_type":"audio"}}`, base64.StdEncoding.EncodeToString(audio)))
+		}
+		conn.incoming <- []byte(`{"event_type":"chat.updated","data":{"output_audio":{"codec":"pcm","pcm_config":{"sample_rate":16000}}}}`)
+		conn.incoming <- []byte(`{"event_type":"conversation.audio.sentence_start","data":{"text":"hello"}}`)
+		conn.incoming <- delta([]byte{1, 2})
+		conn.incoming <- []byte(`{"event_type":"conversation.audio.sentence_start","data":{"text":"world"}}`)
+		conn.incoming <- delta([]byte{3, 4})
+		conn.incoming <- []byte(`{"event_type":"conversation.audio.completed"}`)
+
+		audio := make([]byte, 4)
+		_, err = io.ReadFull(pipe, audio)
+		as.Nil(err)
+		as.Equal([]byte{1, 2, 3, 4}, audio)
+		as.Nil(chat.Wait(WebSocketEventTypeConversationAudioCompleted))
+
+		// the pipe is kept open for the next reply
+		conn.incoming <- delta([]byte{5})
+		_, err = io.ReadFull(pipe, audio[:1])
+		as.Nil(err)
+		as.Equal(byte(5), audio[0])
+		remove()
+		as.Nil(pipe.Close())
+		rest, err := io.ReadAll(pipe)
+		as.Nil(err)
+		as.Empty(rest)
+
+		as.Equal([]*AudioSentence{
+			{Text: "hello", Audio: []byte{1, 2}},
+			{Text: "world", Audio: []byte{3, 4}},
+		}, sentences.Sentences())
+
+		content, err := os.ReadFile(name)
+		as.Nil(err)
+		format, size, err := readWAVHeader(bytes.NewReader(content))
+		as.Nil(err)
+		as.Equal(16000, format.SampleRate)
+		as.Equal(int64(4), size)
+	})
+
+	t.Run("speech", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		speech := newWebSocketAudioSpeechClient(ctx, newFakeWebSocketCore(), &CreateWebsocketAudioSpeechReq{
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+		})
+		pipe := NewAudioPipe()
+		speech.AddAudioSink(pipe)
+		as.Nil(speech.Connect())
+		defer speech.Close()
+
+		conn.incoming <- []byte(`{"event_type":"speech.audio.update","data":{"delta":"AQI="}}`)
+		conn.incoming <- errors.New("connection reset")
+
+		audio, err := io.ReadAll(pipe)
+		as.Equal([]byte{1, 2}, audio)
+		as.ErrorContains(err, "websocket closed")
+	})
+}
@@ -14,11 +14,11 @@ type WebSocketAudioSpeech struct {
 
 func newWebSocketAudioSpeechClient(ctx context.Context, core *core, req *CreateWebsocketAudioSpeechReq) *WebSocketAudioSpeech {
 	ws := newWebSocketClient(mergeWebSocketClientOption(req.WebSocketClientOption, &WebSocketClientOption{
-		ctx:                ctx,
-		core:               core,
-		path:               "/v1/audio/speech",
-		query:              req.toQuery(),
-		responseEventTypes: audioSpeechResponseEventTypes,
+		ctx:              ctx,
+		core:             core,
+		path:             "/v1/audio/speech",
+		query:            req.toQuery(),
+		replayEventTypes: []WebSocketEventType{WebSocketEventTypeSpeechUpdate},
 	}))
 
 	return &WebSocketAudioSpeech{
@@ -33,7 +33,8 @@ func (c *WebSocketAudioSpeech) Connect() error {
 	return c.ws.Connect()
 }
 
-// Close closes the WebSocket connection
+// Close closes the WebSocket connection after the received events are handled. It waits for the
+// handler calling it, so call it in a goroutine from a handler other than OnClosed.
 func (c *WebSocketAudioSpeech) Close() error {
 	return c.ws.Close()
 }
@@ -43,6 +44,11 @@ func (c *WebSocketAudioSpeech) IsConnected() bool {
 	return c.ws.IsConnected()
 }
 
+// Stats returns the queue depths and event counters of the connection
+func (c *WebSocketAudioSpeech) Stats() WebSocketClientStats {
+	return c.ws.Stats()
+}
+
 func (c *WebSocketAudioSpeech) SpeechUpdate(data *WebSocketSpeechUpdateEventData) error {
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeSpeechUpdate, data).(WebSocketSpeechUpdateEvent))
 }
@@ -52,6 +58,12 @@ func (c *WebSocketAudioSpeech) InputTextBufferAppend(data *WebSocketInputTextBuf
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeInputTextBufferAppend, data).(WebSocketInputTextBufferAppendEvent))
 }
 
+// InputTextBufferAppendContext waits while the send queue is full and returns after the event is written, so
+// streaming faster than the connection slows the caller down instead of failing.
+func (c *WebSocketAudioSpeech) InputTextBufferAppendContext(ctx context.Context, data *WebSocketInputTextBufferAppendEventData) error {
+	return c.ws.sendEventContext(ctx, newWebSocketEvent(WebSocketEventTypeInputTextBufferAppend, data).(WebSocketInputTextBufferAppendEvent))
+}
+
 // InputTextBufferComplete completes the input text buffer
 func (c *WebSocketAudioSpeech) InputTextBufferComplete(data *WebSocketInputTextBufferCompleteEventData) error {
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeInputTextBufferComplete, data).(WebSocketInputTextBufferCompleteEvent))
@@ -68,11 +80,58 @@ func (c *WebSocketAudioSpeech) Wait(eventTypes ...WebSocketEventType) error {
 	return c.ws.WaitForEvent(eventTypes, false)
 }
 
-// OnEvent registers an event handler
+// WaitForEvent waits for the events of req until ctx is done, it returns the matched event, or the
+// last one of WaitAll. Use Since, ChatID or EventID to wait for a later occurrence, like the speech.audio.completed of the second text.
+func (c *WebSocketAudioSpeech) WaitForEvent(ctx context.Context, req *WebSocketWaitReq) (IWebSocketEvent, error) {
+	return c.ws.WaitForEventContext(ctx, req)
+}
+
+// WaitMark returns the mark of the events received so far, for WebSocketWaitReq.Since
+func (c *WebSocketAudioSpeech) WaitMark() WebSocketWaitMark {
+	return c.ws.WaitMark()
+}
+
+// ResetWait makes the following Wait and WaitForEvent calls ignore the events received so far,
+// call it before starting a new turn on the same connection
+func (c *WebSocketAudioSpeech) ResetWait() {
+	c.ws.ResetWait()
+}
+
+// Events subscribes to the received events of eventTypes, or all the events if eventTypes is
+// empty. Consume it by Recv, C, or All with Go 1.23, and close it when it's no longer consumed.
+func (c *WebSocketAudioSpeech) Events(ctx context.Context, eventTypes ...WebSocketEventType) *WebSocketEvents {
+	return c.ws.subscribe(ctx, eventTypes)
+}
+
+// OnEvent registers an event handler, it replaces the handler set by the previous OnEvent or
+// On<Event> call of eventType, the handlers added by AddEventHandler are kept
 func (c *WebSocketAudioSpeech) OnEvent(eventType WebSocketEventType, handler EventHandler) {
 	c.ws.OnEvent(eventType, handler)
 }
 
+// AddEventHandler adds a handler of eventType which runs after the registered ones, unlike OnEvent
+// it doesn't replace them. It returns the func removing the handler.
+func (c *WebSocketAudioSpeech) AddEventHandler(eventType WebSocketEventType, handler EventHandler) (remove func()) {
+	return c.ws.AddEventHandler(eventType, handler)
+}
+
+// AddWildcardEventHandler adds a handler of all the events which runs after the handlers of the
+// event type. It returns the func removing the handler.
+func (c *WebSocketAudioSpeech) AddWildcardEventHandler(handler EventHandler) (remove func()) {
+	return c.ws.AddWildcardEventHandler(handler)
+}
+
+// RemoveEventHandlers removes all the handlers of eventType, including the one set by OnEvent
+func (c *WebSocketAudioSpeech) RemoveEventHandlers(eventType WebSocketEventType) {
+	c.ws.RemoveEventHandlers(eventType)
+}
+
+// Use adds middlewares wrapping the handlers of every event, like WebSocketLoggingMiddleware and
+// WebSocketRecoverMiddleware
+func (c *WebSocketAudioSpeech) Use(middlewares ...WebSocketMiddleware) {
+	c.ws.Use(middlewares...)
+}
+
 func registerAudioSpeechEventHandler[T any](c *WebSocketAudioSpeech, eventType WebSocketEventType, handler func(ctx context.Context, cli *WebSocketAudioSpeech, event *T) error) {
 	c.ws.OnEvent(eventType, func(event IWebSocketEvent) error {
 		return handler(c.ctx, c, (any)(event).(*T))
@@ -91,6 +150,16 @@ func (c *WebSocketAudioSpeech) OnClosed(handler func(ctx context.Context, cli *W
 	registerAudioSpeechEventHandler(c, WebSocketEventTypeClosed, handler)
 }
 
+// OnReconnecting registers the handler of the lost connection, see WebSocketClientOption.Reconnect.
+func (c *WebSocketAudioSpeech) OnReconnecting(handler func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketReconnectingEvent) error) {
+	registerAudioSpeechEventHandler(c, WebSocketEventTypeReconnecting, handler)
+}
+
+// OnReconnected registers the handler of the restored connection, see WebSocketClientOption.Reconnect.
+func (c *WebSocketAudioSpeech) OnReconnected(handler func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketReconnectedEvent) error) {
+	registerAudioSpeechEventHandler(c, WebSocketEventTypeReconnected, handler)
+}
+
 func (c *WebSocketAudioSpeech) OnSpeechCreated(handler func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketSpeechCreatedEvent) error) {
 	registerAudioSpeechEventHandler(c, WebSocketEventTypeSpeechCreated, handler)
 }
@@ -151,9 +151,9 @@ func TestWebSocketAudioSpeech(t *testing.T) {
 
 		// Helper to trigger handlers
 		triggerHandler := func(eventType WebSocketEventType, event IWebSocketEvent) {
-			h, ok := client.ws.handlers.Load(eventType)
-			assert.True(t, ok)
-			err := h.(EventHandler)(event)
+			h := client.ws.getHandler(eventType)
+			assert.NotNil(t, h)
+			err := h(event)
 			assert.NoError(t, err)
 		}
 
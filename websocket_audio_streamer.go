@@ -0,0 +1,127 @@
+package coze
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// WebSocketAudioInput is a WebSocket client accepting input audio, like WebSocketChat and
+// WebSocketAudioTranscription
+type WebSocketAudioInput interface {
+	InputAudioBufferAppendContext(ctx context.Context, data *WebSocketInputAudioBufferAppendEventData) error
+	InputAudioBufferComplete(data *WebSocketInputAudioBufferCompleteEventData) error
+}
+
+// AudioStreamerOption configures AudioStreamer
+type AudioStreamerOption struct {
+	// The format of the pcm, the header of a wav overrides it.
+	Format PCMFormat
+	// The duration of the audio of each input_audio_buffer.append, default is 100ms.
+	ChunkDuration time.Duration
+	// Send the chunks at the speed of playback, like capturing from a microphone. By default, the
+	// chunks are sent as fast as the connection allows.
+	RealTime bool
+	// Don't send input_audio_buffer.complete after the audio, like streaming more audio later.
+	NoComplete bool
+}
+
+// AudioStreamer sends audio to a WebSocket client in chunks of input_audio_buffer.append, and
+// completes the input with input_audio_buffer.complete.
+//
+//	streamer := coze.NewAudioStreamer(chat, &coze.AudioStreamerOption{RealTime: true})
+//	err := streamer.Stream(ctx, file)
+type AudioStreamer struct {
+	input WebSocketAudioInput
+	opt   AudioStreamerOption
+}
+
+// NewAudioStreamer creates an AudioStreamer of input, opt may be nil
+func NewAudioStreamer(input WebSocketAudioInput, opt *AudioStreamerOption) *AudioStreamer {
+	s := &AudioStreamer{input: input}
+	if opt != nil {
+		s.opt = *opt
+	}
+	if s.opt.ChunkDuration <= 0 {
+		s.opt.ChunkDuration = 100 * time.Millisecond
+	}
+	return s
+}
+
+// Stream sends the audio of r until EOF, then input_audio_buffer.complete. r is pcm of the
+// format of the option, or a wav whose header is stripped. It returns the error of ctx if ctx is
+// done before, and the complete is not sent.
+func (s *AudioStreamer) Stream(ctx context.Context, r io.Reader) error {
+	reader := bufio.NewReader(r)
+	format := s.opt.Format
+	var audio io.Reader = reader
+	if magic, _ := reader.Peek(4); string(magic) == "RIFF" {
+		wavFormat, size, err := readWAVHeader(reader)
+		if err != nil {
+			return err
+		}
+		format = wavFormat
+		if size >= 0 {
+			// the chunks after the data, like LIST, are not audio
+			audio = io.LimitReader(reader, size)
+		}
+	}
+	format = format.withDefaults()
+
+	blockAlign := format.BlockAlign()
+	chunkSize := int(int64(format.BytesPerSecond()) * int64(s.opt.ChunkDuration) / int64(time.Second))
+	chunkSize -= chunkSize % blockAlign
+	if chunkSize < blockAlign {
+		chunkSize = blockAlign
+	}
+
+	start := time.Now()
+	var sent int64
+	for {
+		chunk := make([]byte, chunkSize)
+		n, err := io.ReadFull(audio, chunk)
+		if n > 0 {
+			if s.opt.RealTime {
+				if err := sleepContext(ctx, time.Until(start.Add(format.Duration(sent)))); err != nil {
+					return err
+				}
+			}
+			if err := s.input.InputAudioBufferAppendContext(ctx, &WebSocketInputAudioBufferAppendEventData{Delta: chunk[:n]}); err != nil {
+				return err
+			}
+			sent += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if s.opt.NoComplete {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.input.InputAudioBufferComplete(&WebSocketInputAudioBufferCompleteEventData{})
+}
+
+// sleepContext sleeps d, it returns the error of ctx if ctx is done before
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,220 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ WebSocketAudioInput = (*WebSocketChat)(nil)
+	_ WebSocketAudioInput = (*WebSocketAudioTranscription)(nil)
+)
+
+type recordAudioInput struct {
+	mu        sync.Mutex
+	chunks    [][]byte
+	times     []time.Time
+	completed int
+	appendErr error
+}
+
+func (r *recordAudioInput) InputAudioBufferAppendContext(ctx context.Context, data *WebSocketInputAudioBufferAppendEventData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.appendErr != nil {
+		return r.appendErr
+	}
+	r.chunks = append(r.chunks, data.Delta)
+	r.times = append(r.times, time.Now())
+	return nil
+}
+
+func (r *recordAudioInput) InputAudioBufferComplete(data *WebSocketInputAudioBufferCompleteEventData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed++
+	return nil
+}
+
+func (r *recordAudioInput) audio() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return bytes.Join(r.chunks, nil)
+}
+
+// buildWAV builds a wav of the pcm, extra chunks are written before fmt and after data
+func buildWAV(format PCMFormat, pcm []byte, extra bool) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(0))
+	buf.WriteString("WAVE")
+	if extra {
+		buf.WriteString("LIST")
+		_ = binary.Write(buf, binary.LittleEndian, uint32(3))
+		buf.Write([]byte{1, 2, 3, 0})
+	}
+	buf.WriteString("fmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(format.Channels))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(format.SampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(format.BytesPerSecond()))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(format.BlockAlign()))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(format.BitDepth))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	if extra {
+		buf.WriteString("LIST")
+		_ = binary.Write(buf, binary.LittleEndian, uint32(4))
+		buf.Write([]byte{9, 9, 9, 9})
+	}
+	return buf.Bytes()
+}
+
+func TestReadWAVHeader(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("testdata", func(t *testing.T) {
+		f, err := os.Open("testdata/websocket_speech_success.wav")
+		as.Nil(err)
+		defer f.Close()
+		format, err := ReadWAVHeader(f)
+		as.Nil(err)
+		as.Equal(PCMFormat{SampleRate: 24000, Channels: 1, BitDepth: 16}, format)
+	})
+
+	t.Run("skip chunks", func(t *testing.T) {
+		format := PCMFormat{SampleRate: 16000, Channels: 2, BitDepth: 16}
+		r := bytes.NewReader(buildWAV(format, []byte{1, 2, 3, 4}, true))
+		actual, size, err := readWAVHeader(r)
+		as.Nil(err)
+		as.Equal(format, actual)
+		as.Equal(int64(4), size)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ReadWAVHeader(bytes.NewReader([]byte("not a wav file")))
+		as.NotNil(err)
+
+		wav := buildWAV(PCMFormat{SampleRate: 16000, Channels: 1, BitDepth: 16}, nil, false)
+		binary.LittleEndian.PutUint16(wav[20:22], 3) // float
+		_, err = ReadWAVHeader(bytes.NewReader(wav))
+		as.Contains(err.Error(), "unsupported wav audio format: 3")
+
+		_, err = ReadWAVHeader(bytes.NewReader(wav[:30]))
+		as.NotNil(err)
+	})
+
+	t.Run("format", func(t *testing.T) {
+		format := PCMFormat{}
+		as.Equal(48000, format.BytesPerSecond())
+		as.Equal(time.Second, format.Duration(48000))
+		as.Equal(4, PCMFormat{Channels: 2, BitDepth: 16}.BlockAlign())
+		input := PCMFormat{SampleRate: 16000}.InputAudio()
+		as.Equal("pcm", *input.Format)
+		as.Equal(16000, *input.SampleRate)
+		as.Equal(1, *input.Channel)
+		as.Equal(16, *input.BitDepth)
+	})
+}
+
+func TestAudioStreamer(t *testing.T) {
+	as := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("wav", func(t *testing.T) {
+		format := PCMFormat{SampleRate: 16000, Channels: 1, BitDepth: 16}
+		pcm := make([]byte, 16000) // 500ms
+		for i := range pcm {
+			pcm[i] = byte(i)
+		}
+		input := &recordAudioInput{}
+		as.Nil(NewAudioStreamer(input, nil).Stream(ctx, bytes.NewReader(buildWAV(format, pcm, true))))
+		as.Len(input.chunks, 5)
+		as.Len(input.chunks[0], 3200)
+		as.Equal(pcm, input.audio())
+		as.Equal(1, input.completed)
+	})
+
+	t.Run("pcm", func(t *testing.T) {
+		input := &recordAudioInput{}
+		streamer := NewAudioStreamer(input, &AudioStreamerOption{
+			Format:        PCMFormat{SampleRate: 8000},
+			ChunkDuration: 20 * time.Millisecond,
+			NoComplete:    true,
+		})
+		pcm := make([]byte, 1000)
+		as.Nil(streamer.Stream(ctx, bytes.NewReader(pcm)))
+		as.Len(input.chunks, 4)
+		as.Len(input.chunks[0], 320)
+		as.Len(input.chunks[3], 40)
+		as.Equal(0, input.completed)
+	})
+
+	t.Run("real time", func(t *testing.T) {
+		input := &recordAudioInput{}
+		streamer := NewAudioStreamer(input, &AudioStreamerOption{
+			Format:        PCMFormat{SampleRate: 8000},
+			ChunkDuration: 50 * time.Millisecond,
+			RealTime:      true,
+		})
+		start := time.Now()
+		as.Nil(streamer.Stream(ctx, bytes.NewReader(make([]byte, 3200)))) // 200ms
+		as.Len(input.chunks, 4)
+		as.GreaterOrEqual(time.Since(start), 150*time.Millisecond)
+		as.GreaterOrEqual(input.times[3].Sub(input.times[0]), 150*time.Millisecond)
+		as.Equal(1, input.completed)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		input := &recordAudioInput{}
+		streamer := NewAudioStreamer(input, &AudioStreamerOption{RealTime: true})
+		ctx, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+		defer cancel()
+		err := streamer.Stream(ctx, bytes.NewReader(make([]byte, 48000))) // 1s
+		as.ErrorIs(err, context.DeadlineExceeded)
+		as.Less(len(input.chunks), 10)
+		as.Equal(0, input.completed)
+	})
+
+	t.Run("append error", func(t *testing.T) {
+		input := &recordAudioInput{appendErr: errors.New("send failed")}
+		err := NewAudioStreamer(input, nil).Stream(ctx, bytes.NewReader(make([]byte, 100)))
+		as.EqualError(err, "send failed")
+		as.Equal(0, input.completed)
+	})
+
+	t.Run("chat", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newWebsocketChatClient(ctx, newFakeWebSocketCore(), &CreateWebsocketChatReq{
+			BotID:                 ptr("bot"),
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+		})
+		as.Nil(chat.Connect())
+		defer chat.Close()
+
+		as.Nil(NewAudioStreamer(chat, nil).Stream(ctx, bytes.NewReader(make([]byte, 9600))))
+		var types []WebSocketEventType
+		for len(types) < 3 {
+			message := <-conn.written
+			event := map[string]any{}
+			as.Nil(json.Unmarshal(message, &event))
+			types = append(types, WebSocketEventType(event["event_type"].(string)))
+		}
+		as.Equal([]WebSocketEventType{
+			WebSocketEventTypeInputAudioBufferAppend,
+			WebSocketEventTypeInputAudioBufferAppend,
+			WebSocketEventTypeInputAudioBufferComplete,
+		}, types)
+	})
+}
@@ -15,11 +15,11 @@ type WebSocketAudioTranscription struct {
 
 func newWebSocketAudioTranscriptionClient(ctx context.Context, core *core, req *CreateWebsocketAudioTranscriptionReq) *WebSocketAudioTranscription {
 	ws := newWebSocketClient(mergeWebSocketClientOption(req.WebSocketClientOption, &WebSocketClientOption{
-		ctx:                ctx,
-		core:               core,
-		path:               "/v1/audio/transcriptions",
-		query:              req.toQuery(),
-		responseEventTypes: audioTranscriptionResponseEventTypes,
+		ctx:              ctx,
+		core:             core,
+		path:             "/v1/audio/transcriptions",
+		query:            req.toQuery(),
+		replayEventTypes: []WebSocketEventType{WebSocketEventTypeTranscriptionsUpdate},
 	}))
 
 	return &WebSocketAudioTranscription{
@@ -34,7 +34,8 @@ func (c *WebSocketAudioTranscription) Connect() error {
 	return c.ws.Connect()
 }
 
-// Close closes the WebSocket connection
+// Close closes the WebSocket connection after the received events are handled. It waits for the
+// handler calling it, so call it in a goroutine from a handler other than OnClosed.
 func (c *WebSocketAudioTranscription) Close() error {
 	return c.ws.Close()
 }
@@ -44,6 +45,11 @@ func (c *WebSocketAudioTranscription) IsConnected() bool {
 	return c.ws.IsConnected()
 }
 
+// Stats returns the queue depths and event counters of the connection
+func (c *WebSocketAudioTranscription) Stats() WebSocketClientStats {
+	return c.ws.Stats()
+}
+
 func (c *WebSocketAudioTranscription) TranscriptionsUpdate(data *WebSocketTranscriptionsUpdateEventData) error {
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeTranscriptionsUpdate, data).(WebSocketTranscriptionsUpdateEvent))
 }
@@ -52,6 +58,12 @@ func (c *WebSocketAudioTranscription) InputAudioBufferAppend(data *WebSocketInpu
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeInputAudioBufferAppend, data).(WebSocketInputAudioBufferAppendEvent))
 }
 
+// InputAudioBufferAppendContext waits while the send queue is full and returns after the event is written, so
+// streaming faster than the connection slows the caller down instead of failing.
+func (c *WebSocketAudioTranscription) InputAudioBufferAppendContext(ctx context.Context, data *WebSocketInputAudioBufferAppendEventData) error {
+	return c.ws.sendEventContext(ctx, newWebSocketEvent(WebSocketEventTypeInputAudioBufferAppend, data).(WebSocketInputAudioBufferAppendEvent))
+}
+
 func (c *WebSocketAudioTranscription) InputAudioBufferComplete(data *WebSocketInputAudioBufferCompleteEventData) error {
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeInputAudioBufferComplete, data).(WebSocketInputAudioBufferCompleteEvent))
 }
@@ -71,11 +83,58 @@ func (c *WebSocketAudioTranscription) Wait(eventTypes ...WebSocketEventType) err
 	return c.ws.WaitForEvent(eventTypes, false)
 }
 
-// OnEvent registers an event handler
+// WaitForEvent waits for the events of req until ctx is done, it returns the matched event, or the
+// last one of WaitAll. Use Since, ChatID or EventID to wait for a later occurrence, like the transcriptions.message.completed of the second audio.
+func (c *WebSocketAudioTranscription) WaitForEvent(ctx context.Context, req *WebSocketWaitReq) (IWebSocketEvent, error) {
+	return c.ws.WaitForEventContext(ctx, req)
+}
+
+// WaitMark returns the mark of the events received so far, for WebSocketWaitReq.Since
+func (c *WebSocketAudioTranscription) WaitMark() WebSocketWaitMark {
+	return c.ws.WaitMark()
+}
+
+// ResetWait makes the following Wait and WaitForEvent calls ignore the events received so far,
+// call it before starting a new turn on the same connection
+func (c *WebSocketAudioTranscription) ResetWait() {
+	c.ws.ResetWait()
+}
+
+// Events subscribes to the received events of eventTypes, or all the events if eventTypes is
+// empty. Consume it by Recv, C, or All with Go 1.23, and close it when it's no longer consumed.
+func (c *WebSocketAudioTranscription) Events(ctx context.Context, eventTypes ...WebSocketEventType) *WebSocketEvents {
+	return c.ws.subscribe(ctx, eventTypes)
+}
+
+// OnEvent registers an event handler, it replaces the handler set by the previous OnEvent or
+// On<Event> call of eventType, the handlers added by AddEventHandler are kept
 func (c *WebSocketAudioTranscription) OnEvent(eventType WebSocketEventType, handler EventHandler) {
 	c.ws.OnEvent(eventType, handler)
 }
 
+// AddEventHandler adds a handler of eventType which runs after the registered ones, unlike OnEvent
+// it doesn't replace them. It returns the func removing the handler.
+func (c *WebSocketAudioTranscription) AddEventHandler(eventType WebSocketEventType, handler EventHandler) (remove func()) {
+	return c.ws.AddEventHandler(eventType, handler)
+}
+
+// AddWildcardEventHandler adds a handler of all the events which runs after the handlers of the
+// event type. It returns the func removing the handler.
+func (c *WebSocketAudioTranscription) AddWildcardEventHandler(handler EventHandler) (remove func()) {
+	return c.ws.AddWildcardEventHandler(handler)
+}
+
+// RemoveEventHandlers removes all the handlers of eventType, including the one set by OnEvent
+func (c *WebSocketAudioTranscription) RemoveEventHandlers(eventType WebSocketEventType) {
+	c.ws.RemoveEventHandlers(eventType)
+}
+
+// Use adds middlewares wrapping the handlers of every event, like WebSocketLoggingMiddleware and
+// WebSocketRecoverMiddleware
+func (c *WebSocketAudioTranscription) Use(middlewares ...WebSocketMiddleware) {
+	c.ws.Use(middlewares...)
+}
+
 func registerAudioTranscriptionEventHandler[T any](c *WebSocketAudioTranscription, eventType WebSocketEventType, handler func(ctx context.Context, cli *WebSocketAudioTranscription, event *T) error) {
 	c.ws.OnEvent(eventType, func(event IWebSocketEvent) error {
 		return handler(c.ctx, c, (any)(event).(*T))
@@ -94,6 +153,16 @@ func (c *WebSocketAudioTranscription) OnClosed(handler func(ctx context.Context,
 	registerAudioTranscriptionEventHandler(c, WebSocketEventTypeClosed, handler)
 }
 
+// OnReconnecting registers the handler of the lost connection, see WebSocketClientOption.Reconnect.
+func (c *WebSocketAudioTranscription) OnReconnecting(handler func(ctx context.Context, cli *WebSocketAudioTranscription, event *WebSocketReconnectingEvent) error) {
+	registerAudioTranscriptionEventHandler(c, WebSocketEventTypeReconnecting, handler)
+}
+
+// OnReconnected registers the handler of the restored connection, see WebSocketClientOption.Reconnect.
+func (c *WebSocketAudioTranscription) OnReconnected(handler func(ctx context.Context, cli *WebSocketAudioTranscription, event *WebSocketReconnectedEvent) error) {
+	registerAudioTranscriptionEventHandler(c, WebSocketEventTypeReconnected, handler)
+}
+
 func (c *WebSocketAudioTranscription) OnTranscriptionsCreated(handler func(ctx context.Context, cli *WebSocketAudioTranscription, event *WebSocketTranscriptionsCreatedEvent) error) {
 	registerAudioTranscriptionEventHandler(c, WebSocketEventTypeTranscriptionsCreated, handler)
 }
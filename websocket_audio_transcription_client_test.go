@@ -168,9 +168,9 @@ func TestWebSocketAudioTranscription(t *testing.T) {
 
 		// Helper to trigger handlers
 		triggerHandler := func(eventType WebSocketEventType, event IWebSocketEvent) {
-			h, ok := client.ws.handlers.Load(eventType)
-			assert.True(t, ok)
-			err := h.(EventHandler)(event)
+			h := client.ws.getHandler(eventType)
+			assert.NotNil(t, h)
+			err := h(event)
 			assert.NoError(t, err)
 		}
 
@@ -2,6 +2,7 @@ package coze
 
 import (
 	"context"
+	"sync"
 )
 
 var _ WebSocketClient = (*WebSocketChat)(nil)
@@ -11,30 +12,46 @@ type WebSocketChat struct {
 	core *core
 
 	ws *websocketClient
+
+	usageMu    sync.Mutex
+	usageScope UsageScope
 }
 
 func newWebsocketChatClient(ctx context.Context, core *core, req *CreateWebsocketChatReq) *WebSocketChat {
 	ws := newWebSocketClient(mergeWebSocketClientOption(req.WebSocketClientOption, &WebSocketClientOption{
-		ctx:                ctx,
-		core:               core,
-		path:               "/v1/chat",
-		query:              req.toQuery(),
-		responseEventTypes: chatResponseEventTypes,
+		ctx:              ctx,
+		core:             core,
+		path:             "/v1/chat",
+		query:            req.toQuery(),
+		replayEventTypes: []WebSocketEventType{WebSocketEventTypeChatUpdate},
 	}))
 
-	return &WebSocketChat{
+	chat := &WebSocketChat{
 		ctx:  ctx,
 		core: core,
 		ws:   ws,
+		usageScope: UsageScope{
+			BotID:      ptrValue(req.BotID),
+			WorkflowID: ptrValue(req.WorkflowID),
+		},
+	}
+	if core.usageTracker != nil {
+		ws.onSend = chat.checkUsage
+		ws.onReceive = chat.recordUsage
 	}
+	return chat
 }
 
 // Connect establishes the WebSocket connection
 func (c *WebSocketChat) Connect() error {
+	if err := c.core.checkUsage(c.ctx, c.getUsageScope()); err != nil {
+		return err
+	}
 	return c.ws.Connect()
 }
 
-// Close closes the WebSocket connection
+// Close closes the WebSocket connection after the received events are handled. It waits for the
+// handler calling it, so call it in a goroutine from a handler other than OnClosed.
 func (c *WebSocketChat) Close() error {
 	return c.ws.Close()
 }
@@ -44,6 +61,11 @@ func (c *WebSocketChat) IsConnected() bool {
 	return c.ws.IsConnected()
 }
 
+// Stats returns the queue depths and event counters of the connection
+func (c *WebSocketChat) Stats() WebSocketClientStats {
+	return c.ws.Stats()
+}
+
 func (c *WebSocketChat) ChatUpdate(data *WebSocketChatUpdateEventData) error {
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeChatUpdate, data).(WebSocketChatUpdateEvent))
 }
@@ -52,6 +74,12 @@ func (c *WebSocketChat) InputAudioBufferAppend(data *WebSocketInputAudioBufferAp
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeInputAudioBufferAppend, data).(WebSocketInputAudioBufferAppendEvent))
 }
 
+// InputAudioBufferAppendContext waits while the send queue is full and returns after the event is written, so
+// streaming faster than the connection slows the caller down instead of failing.
+func (c *WebSocketChat) InputAudioBufferAppendContext(ctx context.Context, data *WebSocketInputAudioBufferAppendEventData) error {
+	return c.ws.sendEventContext(ctx, newWebSocketEvent(WebSocketEventTypeInputAudioBufferAppend, data).(WebSocketInputAudioBufferAppendEvent))
+}
+
 func (c *WebSocketChat) InputAudioBufferComplete(data *WebSocketInputAudioBufferCompleteEventData) error {
 	return c.ws.sendEvent(newWebSocketEvent(WebSocketEventTypeInputAudioBufferComplete, data).(WebSocketInputAudioBufferCompleteEvent))
 }
@@ -92,11 +120,58 @@ func (c *WebSocketChat) Wait(eventTypes ...WebSocketEventType) error {
 	return c.ws.WaitForEvent(eventTypes, false)
 }
 
-// OnEvent registers an event handler
+// WaitForEvent waits for the events of req until ctx is done, it returns the matched event, or the
+// last one of WaitAll. Use Since, ChatID or EventID to wait for a later occurrence, like the conversation.chat.completed of the second turn.
+func (c *WebSocketChat) WaitForEvent(ctx context.Context, req *WebSocketWaitReq) (IWebSocketEvent, error) {
+	return c.ws.WaitForEventContext(ctx, req)
+}
+
+// WaitMark returns the mark of the events received so far, for WebSocketWaitReq.Since
+func (c *WebSocketChat) WaitMark() WebSocketWaitMark {
+	return c.ws.WaitMark()
+}
+
+// ResetWait makes the following Wait and WaitForEvent calls ignore the events received so far,
+// call it before starting a new turn on the same connection
+func (c *WebSocketChat) ResetWait() {
+	c.ws.ResetWait()
+}
+
+// Events subscribes to the received events of eventTypes, or all the events if eventTypes is
+// empty. Consume it by Recv, C, or All with Go 1.23, and close it when it's no longer consumed.
+func (c *WebSocketChat) Events(ctx context.Context, eventTypes ...WebSocketEventType) *WebSocketEvents {
+	return c.ws.subscribe(ctx, eventTypes)
+}
+
+// OnEvent registers an event handler, it replaces the handler set by the previous OnEvent or
+// On<Event> call of eventType, the handlers added by AddEventHandler are kept
 func (c *WebSocketChat) OnEvent(eventType WebSocketEventType, handler EventHandler) {
 	c.ws.OnEvent(eventType, handler)
 }
 
+// AddEventHandler adds a handler of eventType which runs after the registered ones, unlike OnEvent
+// it doesn't replace them. It returns the func removing the handler.
+func (c *WebSocketChat) AddEventHandler(eventType WebSocketEventType, handler EventHandler) (remove func()) {
+	return c.ws.AddEventHandler(eventType, handler)
+}
+
+// AddWildcardEventHandler adds a handler of all the events which runs after the handlers of the
+// event type. It returns the func removing the handler.
+func (c *WebSocketChat) AddWildcardEventHandler(handler EventHandler) (remove func()) {
+	return c.ws.AddWildcardEventHandler(handler)
+}
+
+// RemoveEventHandlers removes all the handlers of eventType, including the one set by OnEvent
+func (c *WebSocketChat) RemoveEventHandlers(eventType WebSocketEventType) {
+	c.ws.RemoveEventHandlers(eventType)
+}
+
+// Use adds middlewares wrapping the handlers of every event, like WebSocketLoggingMiddleware and
+// WebSocketRecoverMiddleware
+func (c *WebSocketChat) Use(middlewares ...WebSocketMiddleware) {
+	c.ws.Use(middlewares...)
+}
+
 func registerChatEventHandler[T any](c *WebSocketChat, eventType WebSocketEventType, handler func(ctx context.Context, cli *WebSocketChat, event *T) error) {
 	c.ws.OnEvent(eventType, func(event IWebSocketEvent) error {
 		return handler(c.ctx, c, (any)(event).(*T))
@@ -115,6 +190,16 @@ func (c *WebSocketChat) OnClosed(handler func(ctx context.Context, cli *WebSocke
 	registerChatEventHandler(c, WebSocketEventTypeClosed, handler)
 }
 
+// OnReconnecting registers the handler of the lost connection, see WebSocketClientOption.Reconnect.
+func (c *WebSocketChat) OnReconnecting(handler func(ctx context.Context, cli *WebSocketChat, event *WebSocketReconnectingEvent) error) {
+	registerChatEventHandler(c, WebSocketEventTypeReconnecting, handler)
+}
+
+// OnReconnected registers the handler of the restored connection, see WebSocketClientOption.Reconnect.
+func (c *WebSocketChat) OnReconnected(handler func(ctx context.Context, cli *WebSocketChat, event *WebSocketReconnectedEvent) error) {
+	registerChatEventHandler(c, WebSocketEventTypeReconnected, handler)
+}
+
 func (c *WebSocketChat) OnChatCreated(handler func(ctx context.Context, cli *WebSocketChat, event *WebSocketChatCreatedEvent) error) {
 	registerChatEventHandler(c, WebSocketEventTypeChatCreated, handler)
 }
@@ -195,6 +280,63 @@ func (c *WebSocketChat) OnInputAudioBufferSpeechStopped(handler func(ctx context
 	registerChatEventHandler(c, WebSocketEventTypeInputAudioBufferSpeechStopped, handler)
 }
 
+func (c *WebSocketChat) getUsageScope() UsageScope {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	return c.usageScope
+}
+
+// usageCheckedEventTypes are the sent events starting a model response, the budget is checked
+// before them, not before every input_audio_buffer.append
+var usageCheckedEventTypes = map[WebSocketEventType]bool{
+	WebSocketEventTypeChatUpdate:                        true,
+	WebSocketEventTypeConversationMessageCreate:         true,
+	WebSocketEventTypeInputAudioBufferComplete:          true,
+	WebSocketEventTypeConversationChatSubmitToolOutputs: true,
+}
+
+// checkUsage checks the budget before sending an event starting a model response, the user and
+// conversation of the scope are taken from chat.update.
+func (c *WebSocketChat) checkUsage(event IWebSocketEvent) error {
+	if !usageCheckedEventTypes[event.GetEventType()] {
+		return nil
+	}
+	if e, ok := event.(WebSocketChatUpdateEvent); ok && e.Data != nil && e.Data.ChatConfig != nil {
+		c.usageMu.Lock()
+		if e.Data.ChatConfig.UserID != nil {
+			c.usageScope.UserID = *e.Data.ChatConfig.UserID
+		}
+		if e.Data.ChatConfig.ConversationID != nil {
+			c.usageScope.ConversationID = *e.Data.ChatConfig.ConversationID
+		}
+		c.usageMu.Unlock()
+	}
+	return c.core.checkUsage(c.ctx, c.getUsageScope())
+}
+
+// recordUsage records the usage of conversation.chat.completed
+func (c *WebSocketChat) recordUsage(event IWebSocketEvent) {
+	e, ok := event.(*WebSocketConversationChatCompletedEvent)
+	if !ok || e.Data == nil || e.Data.Usage == nil {
+		return
+	}
+	scope := c.getUsageScope()
+	if e.Data.BotID != "" {
+		scope.BotID = e.Data.BotID
+	}
+	if e.Data.ConversationID != "" {
+		scope.ConversationID = e.Data.ConversationID
+	}
+	c.core.recordUsage(c.ctx, &UsageRecord{
+		UsageScope:   scope,
+		Source:       UsageSourceWebSocketChat,
+		ChatID:       e.Data.ID,
+		InputTokens:  e.Data.Usage.InputCount,
+		OutputTokens: e.Data.Usage.OutputCount,
+		TotalTokens:  e.Data.Usage.TokenCount,
+	})
+}
+
 // RegisterHandler registers all handlers with the client
 func (c *WebSocketChat) RegisterHandler(h IWebSocketChatHandler) {
 	c.OnClientError(h.OnClientError)
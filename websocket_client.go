@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,28 +22,146 @@ type websocketClient struct {
 	core        *core
 	dial        websocketDialer
 	conn        websocketConn
-	sendChan    chan IWebSocketEvent // 发送队列, 长度 1000
-	receiveChan chan IWebSocketEvent // 接收队列, 长度 1000
-	closeChan   chan struct{}
-	processing  sync.WaitGroup
-	handlers    sync.Map // map[WebSocketEventType]EventHandler
-	mu          sync.RWMutex
-	connected   bool
-	ctx         context.Context
-	cancel      context.CancelFunc
-	waiter      *eventWaiter
+	sendChan    chan *websocketSendItem // 发送队列, 长度 1000
+	receiveChan chan IWebSocketEvent    // 接收队列, 长度 1000
+	closeChan   chan struct{}           // closed by Close or when the connection is lost for good
+	closeOnce   sync.Once
+	processing  pendingEvents
+	handlers    *websocketHandlers
+	// the subscriptions of Events, map[*WebSocketEvents]struct{}
+	subscriptions sync.Map
+	mu            sync.RWMutex
+	connected     bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+	waiter        *eventWaiter
+
+	// internal hooks of the typed clients, called before an event is queued and after an event is parsed
+	onSend    func(event IWebSocketEvent) error
+	onReceive func(event IWebSocketEvent)
+
+	// ready is closed while the connection is usable, it is replaced by an open channel while reconnecting
+	ready chan struct{}
+	// the last sent events of replayEventTypes, sent again after reconnected
+	replayEvents map[WebSocketEventType]IWebSocketEvent
+
+	lastActivity int64         // unix nano of the last received message or pong
+	idleErr      error         // set by the heartbeat before it closes an idle connection
+	stopping     bool          // set by Close before it waits for the received events to be handled
+	closing      int32         // 1 while Close waits for the close frame of the server
+	receiveDone  chan struct{} // closed when receiveLoop returns
+
+	stats websocketClientStats
 }
 
+// websocketSendItem is a queued event, done receives the result of writing it if set
+type websocketSendItem struct {
+	event IWebSocketEvent
+	done  chan error
+}
+
+type websocketClientStats struct {
+	sent         int64
+	sendFailed   int64
+	sendRejected int64
+	received     int64
+	maxSendQueue int64
+}
+
+// WebSocketClientStats represents the queue depths and event counters of a WebSocket client
+type WebSocketClientStats struct {
+	// The number of events waiting to be written and the capacity of the queue.
+	SendQueueLen int
+	SendQueueCap int
+	// The max SendQueueLen observed.
+	MaxSendQueueLen int
+
+	// The number of received events waiting for the handlers and the capacity of the queue.
+	ReceiveQueueLen int
+	ReceiveQueueCap int
+
+	// The number of written events, events failed to be written, and events rejected since the
+	// queue was full.
+	SentEvents     int64
+	FailedEvents   int64
+	RejectedEvents int64
+
+	ReceivedEvents int64
+}
+
+// ErrWebSocketSendQueueFull is returned if an event can't be queued since the send queue is full
+var ErrWebSocketSendQueueFull = errors.New("send channel full")
+
 type WebSocketClientOption struct {
 	ctx                 context.Context
 	core                *core
 	path                string
 	query               map[string]string
-	responseEventTypes  []WebSocketEventType
+	replayEventTypes    []WebSocketEventType
 	dial                websocketDialer
-	SendChanCapacity    int           // 默认 1000
-	ReceiveChanCapacity int           // 默认 1000
-	HandshakeTimeout    time.Duration // 默认 3s
+	SendChanCapacity    int                       // 默认 1000
+	ReceiveChanCapacity int                       // 默认 1000
+	HandshakeTimeout    time.Duration             // 默认 3s
+	SendTimeout         time.Duration             // 发送队列满时等待的时长, 默认不等待直接返回 ErrWebSocketSendQueueFull, 小于 0 时一直等待
+	WaitSent            bool                      // 发送方法等待事件写入连接后返回, 并返回写入的错误
+	Reconnect           *WebSocketReconnectPolicy // 默认不重连
+
+	// 发送 ping 的间隔, 默认不发送
+	PingInterval time.Duration
+	// 超过该时长未收到任何消息 (包括 pong) 视为断线, 触发重连或 closed 事件, 设置 PingInterval 时默认为 2 倍 PingInterval
+	ReadTimeout time.Duration
+	// 单条消息的写超时, 默认不限制
+	WriteTimeout time.Duration
+	// Close 发送 close frame 后等待服务端回应的时长, 默认 1s
+	CloseTimeout time.Duration
+	// Close 发送的状态码, 默认 1000 (normal closure)
+	CloseCode int
+}
+
+// websocketControlConn is implemented by *websocket.Conn, connections without it are not pinged
+// and closed without a close frame
+type websocketControlConn interface {
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+}
+
+// WebSocketReconnectPolicy configures reconnecting after the connection is lost. The events sent
+// while reconnecting are buffered and sent after reconnected.
+type WebSocketReconnectPolicy struct {
+	// The max number of dials, default is 5.
+	MaxAttempts int
+
+	// The delay before the first dial, doubled after every failed dial, default is 500ms.
+	InitialBackoff time.Duration
+
+	// The max delay between dials, default is 10s.
+	MaxBackoff time.Duration
+}
+
+func (p *WebSocketReconnectPolicy) backoff(attempt int) time.Duration {
+	initial, max := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+func (p *WebSocketReconnectPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 5
+	}
+	return p.MaxAttempts
 }
 
 type websocketConn interface {
@@ -66,7 +185,7 @@ func mergeWebSocketClientOption(opt *WebSocketClientOption, other *WebSocketClie
 	opt.core = other.core
 	opt.path = other.path
 	opt.query = other.query
-	opt.responseEventTypes = other.responseEventTypes
+	opt.replayEventTypes = other.replayEventTypes
 	return opt
 }
 
@@ -89,19 +208,31 @@ func newWebSocketClient(opt *WebSocketClientOption) *websocketClient {
 	if opt.dial == nil {
 		opt.dial = dialWebSocket
 	}
+	if opt.ReadTimeout == 0 && opt.PingInterval > 0 {
+		opt.ReadTimeout = 2 * opt.PingInterval
+	}
+	if opt.CloseTimeout == 0 {
+		opt.CloseTimeout = time.Second
+	}
+	if opt.CloseCode == 0 {
+		opt.CloseCode = websocket.CloseNormalClosure
+	}
 
 	client := &websocketClient{
 		opt:         opt,
 		core:        opt.core,
 		dial:        opt.dial,
-		sendChan:    make(chan IWebSocketEvent, opt.SendChanCapacity),
+		sendChan:    make(chan *websocketSendItem, opt.SendChanCapacity),
 		receiveChan: make(chan IWebSocketEvent, opt.ReceiveChanCapacity),
 		closeChan:   make(chan struct{}),
-		handlers:    sync.Map{},
+		handlers:    newWebSocketHandlers(),
 		ctx:         ctx,
 		cancel:      cancel,
-		waiter:      newEventWaiter(opt.responseEventTypes),
+		waiter:      newEventWaiter(),
+		ready:       make(chan struct{}),
+		receiveDone: make(chan struct{}),
 	}
+	close(client.ready)
 
 	return client
 }
@@ -115,6 +246,28 @@ func (c *websocketClient) Connect() error {
 		return fmt.Errorf("already connected")
 	}
 
+	conn, err := c.dialConn()
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.connected = true
+	c.watchConn(conn)
+
+	// Start goroutines
+	go c.sendLoop()
+	go c.receiveLoop()
+	go c.handleEvents()
+	if c.opt.ReadTimeout > 0 {
+		go c.heartbeatLoop()
+	}
+
+	return nil
+}
+
+// dialConn builds the url and headers and dials a new connection
+func (c *websocketClient) dialConn() (websocketConn, error) {
 	baseURL := c.opt.core.baseURL
 	path := c.opt.path
 	auth := c.opt.core.auth
@@ -123,7 +276,7 @@ func (c *websocketClient) Connect() error {
 	// Build WebSocket URL
 	u, err := url.Parse(baseURL)
 	if err != nil {
-		return fmt.Errorf("invalid base URL: %w", err)
+		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
 
 	// Convert HTTP URL to WebSocket URL
@@ -151,7 +304,7 @@ func (c *websocketClient) Connect() error {
 	// Get auth header
 	accessToken, err := auth.Token(c.ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get auth header: %w", err)
+		return nil, fmt.Errorf("failed to get auth header: %w", err)
 	}
 
 	// Setup headers
@@ -170,47 +323,138 @@ func (c *websocketClient) Connect() error {
 	c.core.Log(c.ctx, LogLevelDebug, "[%s] connecting to websocket: %s", c.opt.path, u.String())
 	conn, err := c.dial(dialer, u.String(), headers)
 	if err != nil {
-		return fmt.Errorf("failed to connect to WebSocket: %w", err)
+		return nil, fmt.Errorf("failed to connect to WebSocket: %w", err)
 	}
-
-	c.conn = conn
-	c.connected = true
-
-	// Start goroutines
-	go c.sendLoop()
-	go c.receiveLoop()
-	go c.handleEvents()
-
-	return nil
+	return conn, nil
 }
 
-// Close closes the WebSocket connection
+// Close closes the WebSocket connection after the received events are handled. The handlers may
+// send events or check the state meanwhile. A handler calling Close would wait for itself forever,
+// so call it in a goroutine there, except in the handler of the closed event.
 func (c *websocketClient) Close() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if !c.connected {
+	if !c.connected || c.stopping {
+		c.mu.Unlock()
 		return nil
 	}
+	c.stopping = true
+	c.mu.Unlock()
 
-	// wait for receive channels to be empty
+	// wait for receive channels to be empty, without the lock which the handlers may need
 	c.processing.Wait()
 
+	c.mu.Lock()
+	if !c.connected {
+		// lost meanwhile, see lose
+		c.mu.Unlock()
+		return nil
+	}
 	c.connected = false
+	conn := c.conn
+	c.mu.Unlock()
+	c.closeHandshake(conn)
+
 	c.cancel()
 
 	// Close connection
 	var err error
-	if c.conn != nil {
-		err = c.conn.Close()
+	if conn != nil {
+		err = conn.Close()
 	}
 
 	// Close channels
-	close(c.closeChan)
+	c.closeOnce.Do(func() { close(c.closeChan) })
 
 	return err
 }
 
+// closeHandshake sends a close frame and waits for the close frame of the server, which ends
+// receiveLoop
+func (c *websocketClient) closeHandshake(conn websocketConn) {
+	control, ok := conn.(websocketControlConn)
+	if !ok {
+		return
+	}
+	atomic.StoreInt32(&c.closing, 1)
+	message := websocket.FormatCloseMessage(c.opt.CloseCode, "")
+	if err := control.WriteControl(websocket.CloseMessage, message, time.Now().Add(c.opt.CloseTimeout)); err != nil {
+		return
+	}
+	select {
+	case <-c.receiveDone:
+	case <-time.After(c.opt.CloseTimeout):
+		c.core.Log(c.ctx, LogLevelDebug, "[%s] close frame is not answered in %s", c.opt.path, c.opt.CloseTimeout)
+	}
+}
+
+// watchConn resets the idle timer of a new connection and records its pongs
+func (c *websocketClient) watchConn(conn websocketConn) {
+	atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+	if control, ok := conn.(websocketControlConn); ok {
+		control.SetPongHandler(func(string) error {
+			atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+			return nil
+		})
+	}
+}
+
+// heartbeatLoop pings the server and closes the connection if nothing is received in ReadTimeout,
+// receiveLoop then handles it as a lost connection.
+func (c *websocketClient) heartbeatLoop() {
+	interval := c.opt.PingInterval
+	if interval <= 0 || interval > c.opt.ReadTimeout/2 {
+		interval = c.opt.ReadTimeout / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pingEvery := int(c.opt.PingInterval / interval)
+	for tick := 1; ; tick++ {
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+		c.mu.RLock()
+		conn := c.conn
+		c.mu.RUnlock()
+		select {
+		case <-c.readyChan():
+		default:
+			continue // reconnecting
+		}
+
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&c.lastActivity)))
+		if idle > c.opt.ReadTimeout {
+			c.mu.Lock()
+			c.idleErr = fmt.Errorf("no message received in %s", c.opt.ReadTimeout)
+			c.mu.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		control, ok := conn.(websocketControlConn)
+		if pingEvery > 0 && ok && tick%pingEvery == 0 {
+			if err := control.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				c.core.Log(c.ctx, LogLevelDebug, "[%s] send ping failed, err=%s", c.opt.path, err)
+			}
+		}
+	}
+}
+
+func (c *websocketClient) readyChan() chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ready
+}
+
+// takeIdleErr returns the error of a connection closed by heartbeatLoop
+func (c *websocketClient) takeIdleErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.idleErr
+	c.idleErr = nil
+	return err
+}
+
 // IsConnected returns whether the client is connected
 func (c *websocketClient) IsConnected() bool {
 	c.mu.RLock()
@@ -218,25 +462,171 @@ func (c *websocketClient) IsConnected() bool {
 	return c.connected
 }
 
-// 发送事件
+// 发送事件, 按 SendTimeout 和 WaitSent 等待
 func (c *websocketClient) sendEvent(event IWebSocketEvent) error {
+	return c.send(c.ctx, event, c.opt.SendTimeout, c.opt.WaitSent)
+}
+
+// sendEventContext waits until the event is queued and written, it returns the error of writing
+func (c *websocketClient) sendEventContext(ctx context.Context, event IWebSocketEvent) error {
+	return c.send(ctx, event, -1, true)
+}
+
+func (c *websocketClient) send(ctx context.Context, event IWebSocketEvent, timeout time.Duration, waitSent bool) error {
 	if !c.IsConnected() {
 		return fmt.Errorf("websocket not connected")
 	}
+	if c.onSend != nil {
+		if err := c.onSend(event); err != nil {
+			return err
+		}
+	}
 
+	item := &websocketSendItem{event: event}
+	if waitSent {
+		item.done = make(chan error, 1)
+	}
+	if err := c.enqueue(ctx, item, timeout); err != nil {
+		return err
+	}
+	c.recordReplayEvent(event)
+	if !waitSent {
+		return nil
+	}
 	select {
-	case c.sendChan <- event:
+	case err := <-item.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closeChan:
+		// the event may be written just before
+		select {
+		case err := <-item.done:
+			return err
+		default:
+			return ErrWebSocketClosed
+		}
+	case <-c.ctx.Done():
+		return fmt.Errorf("context cancelled")
+	}
+}
+
+// enqueue waits at most timeout for room in the send queue, forever if timeout is negative
+func (c *websocketClient) enqueue(ctx context.Context, item *websocketSendItem, timeout time.Duration) error {
+	defer c.recordSendQueueLen()
+	select {
+	case <-c.closeChan:
+		return ErrWebSocketClosed
+	default:
+	}
+	select {
+	case c.sendChan <- item:
 		return nil
 	case <-c.ctx.Done():
 		return fmt.Errorf("context cancelled")
 	default:
-		return fmt.Errorf("send channel full")
+	}
+	if timeout == 0 {
+		atomic.AddInt64(&c.stats.sendRejected, 1)
+		return ErrWebSocketSendQueueFull
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case c.sendChan <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closeChan:
+		return ErrWebSocketClosed
+	case <-c.ctx.Done():
+		return fmt.Errorf("context cancelled")
+	case <-expired:
+		atomic.AddInt64(&c.stats.sendRejected, 1)
+		return ErrWebSocketSendQueueFull
 	}
 }
 
+func (c *websocketClient) recordSendQueueLen() {
+	n := int64(len(c.sendChan))
+	for {
+		max := atomic.LoadInt64(&c.stats.maxSendQueue)
+		if n <= max || atomic.CompareAndSwapInt64(&c.stats.maxSendQueue, max, n) {
+			return
+		}
+	}
+}
+
+// Stats returns the queue depths and event counters
+func (c *websocketClient) Stats() WebSocketClientStats {
+	return WebSocketClientStats{
+		SendQueueLen:    len(c.sendChan),
+		SendQueueCap:    cap(c.sendChan),
+		MaxSendQueueLen: int(atomic.LoadInt64(&c.stats.maxSendQueue)),
+		ReceiveQueueLen: len(c.receiveChan),
+		ReceiveQueueCap: cap(c.receiveChan),
+		SentEvents:      atomic.LoadInt64(&c.stats.sent),
+		FailedEvents:    atomic.LoadInt64(&c.stats.sendFailed),
+		RejectedEvents:  atomic.LoadInt64(&c.stats.sendRejected),
+		ReceivedEvents:  atomic.LoadInt64(&c.stats.received),
+	}
+}
+
+// recordReplayEvent keeps the last config event to send it again after reconnected
+func (c *websocketClient) recordReplayEvent(event IWebSocketEvent) {
+	for _, eventType := range c.opt.replayEventTypes {
+		if event.GetEventType() == eventType {
+			c.mu.Lock()
+			if c.replayEvents == nil {
+				c.replayEvents = map[WebSocketEventType]IWebSocketEvent{}
+			}
+			c.replayEvents[eventType] = event
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+// currentConn returns the connection once it is usable, it waits while reconnecting
+func (c *websocketClient) currentConn() (websocketConn, error) {
+	select {
+	case <-c.readyChan():
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn, nil
+}
+
 // OnEvent registers an event handler
 func (c *websocketClient) OnEvent(eventType WebSocketEventType, handler EventHandler) {
-	c.handlers.Store(eventType, handler)
+	c.handlers.set(eventType, handler)
+}
+
+// AddEventHandler adds a handler of eventType after the registered ones
+func (c *websocketClient) AddEventHandler(eventType WebSocketEventType, handler EventHandler) func() {
+	return c.handlers.add(eventType, handler)
+}
+
+// AddWildcardEventHandler adds a handler of all the events
+func (c *websocketClient) AddWildcardEventHandler(handler EventHandler) func() {
+	return c.handlers.addWildcard(handler)
+}
+
+// RemoveEventHandlers removes all the handlers of eventType
+func (c *websocketClient) RemoveEventHandlers(eventType WebSocketEventType) {
+	c.handlers.remove(eventType)
+}
+
+// Use adds middlewares wrapping the handlers
+func (c *websocketClient) Use(middlewares ...WebSocketMiddleware) {
+	c.handlers.use(middlewares...)
 }
 
 // WaitForEvent waits for specific events
@@ -244,52 +634,153 @@ func (c *websocketClient) WaitForEvent(eventTypes []WebSocketEventType, waitAll
 	return c.waiter.wait(c.ctx, eventTypes, waitAll)
 }
 
+// WaitForEventContext waits for the events of req, it returns the matched event, or the last one
+// of WaitAll. It fails with ErrWebSocketClosed if the client is closed first.
+func (c *websocketClient) WaitForEventContext(ctx context.Context, req *WebSocketWaitReq) (IWebSocketEvent, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+
+	event, err := c.waiter.waitFor(waitCtx, &eventWaitCond{
+		eventTypes: req.EventTypes,
+		waitAll:    req.WaitAll,
+		since:      uint64(req.Since),
+		chatID:     req.ChatID,
+		eventID:    req.EventID,
+	})
+	if errors.Is(err, errEventWaiterShutdown) || (err != nil && ctx.Err() == nil && c.ctx.Err() != nil) {
+		return nil, ErrWebSocketClosed
+	}
+	return event, err
+}
+
+// WaitMark returns the mark of the events received so far
+func (c *websocketClient) WaitMark() WebSocketWaitMark {
+	return c.waiter.mark()
+}
+
+// ResetWait makes the following waits ignore the events received so far
+func (c *websocketClient) ResetWait() {
+	c.waiter.reset()
+}
+
 // sendLoop handles sending messages
 func (c *websocketClient) sendLoop() {
 	for {
 		select {
-		case event := <-c.sendChan:
+		case item := <-c.sendChan:
+			event := item.event
 			data, err := json.Marshal(event)
 			if err != nil {
 				if c.core.logLevel <= LogLevelDebug {
 					c.core.Log(c.ctx, LogLevelDebug, "[%s] send event, marshal_failed, type=%s, event=%s, err=%s", c.opt.path, event.GetEventType(), mustToJson(event), err)
 				}
-				c.handleClientError(fmt.Errorf("failed to marshal event: %w", err))
+				c.sendFailed(item, fmt.Errorf("failed to marshal event: %w", err))
 				continue
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			conn, err := c.currentConn()
+			if err != nil {
+				return
+			}
+			if control, ok := conn.(websocketControlConn); ok && c.opt.WriteTimeout > 0 {
+				_ = control.SetWriteDeadline(time.Now().Add(c.opt.WriteTimeout))
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
 				if c.core.logLevel <= LogLevelDebug {
 					c.core.Log(c.ctx, LogLevelDebug, "[%s] send event, write_failed, type=%s, event=%s, err=%s", c.opt.path, event.GetEventType(), mustToJson(event), err)
 				}
-				c.handleClientError(fmt.Errorf("failed to send message: %w", err))
+				c.sendFailed(item, fmt.Errorf("failed to send message: %w", err))
 				continue
 			}
+			atomic.AddInt64(&c.stats.sent, 1)
+			if item.done != nil {
+				item.done <- nil
+			}
 			if c.core.logLevel <= LogLevelDebug {
 				c.core.Log(c.ctx, LogLevelDebug, "[%s] send event, type=%s, event=%s", c.opt.path, event.GetEventType(), mustToJson(event))
 			}
+		case <-c.closeChan:
+			c.failQueued()
+			return
 		case <-c.ctx.Done():
 			return
 		}
 	}
 }
 
+// failQueued fails the events left in the send queue after the client is closed
+func (c *websocketClient) failQueued() {
+	for {
+		select {
+		case item := <-c.sendChan:
+			atomic.AddInt64(&c.stats.sendFailed, 1)
+			if item.done != nil {
+				item.done <- ErrWebSocketClosed
+			}
+		default:
+			return
+		}
+	}
+}
+
+// sendFailed returns the error to the sender waiting for it, or reports it as a client error
+func (c *websocketClient) sendFailed(item *websocketSendItem, err error) {
+	atomic.AddInt64(&c.stats.sendFailed, 1)
+	if item.done != nil {
+		item.done <- err
+		return
+	}
+	c.handleClientError(err)
+}
+
 // receiveLoop handles receiving messages
 func (c *websocketClient) receiveLoop() {
+	defer close(c.receiveDone)
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
 		default:
-			_, message, err := c.conn.ReadMessage()
+			conn, err := c.currentConn()
+			if err != nil {
+				return
+			}
+			_, message, err := conn.ReadMessage()
 			if err != nil {
-				if errors.Is(err, net.ErrClosed) {
+				if idleErr := c.takeIdleErr(); idleErr != nil {
+					err = idleErr
+				} else if errors.Is(err, net.ErrClosed) || c.ctx.Err() != nil || atomic.LoadInt32(&c.closing) == 1 {
+					return
+				}
+				if c.opt.Reconnect != nil && !isServerClose(err) && c.reconnect(conn, err) {
+					continue
+				}
+				// Close is called meanwhile, it ends the client without the events of a lost connection
+				c.mu.RLock()
+				stopping := c.stopping
+				c.mu.RUnlock()
+				if c.ctx.Err() != nil || stopping {
 					return
 				}
-				c.handleClientError(fmt.Errorf("failed to read message: %w", err))
-				c.waiter.shutdown()
+				c.lose(err)
 				return
 			}
+			atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
 
 			event, err := parseWebSocketEvent(message)
 			if err != nil {
@@ -298,9 +789,14 @@ func (c *websocketClient) receiveLoop() {
 				continue
 			}
 
-			if err := c.waiter.trigger(event.GetEventType()); err != nil {
+			if c.onReceive != nil {
+				c.onReceive(event)
+			}
+
+			if err := c.waiter.triggerEvent(event.GetEventType(), event); err != nil {
 				c.core.Log(c.ctx, LogLevelWarn, "[%s] trigger event failed, event_type=%s, err=%s", c.opt.path, event.GetEventType(), err)
 			}
+			c.publish(event)
 
 			if event.GetEventType() == WebSocketEventTypeSpeechAudioUpdate {
 				c.core.Log(c.ctx, LogLevelDebug, "[%s] receive event, type=%s, event=%s", c.opt.path, event.GetEventType(), event.(*WebSocketSpeechAudioUpdateEvent).dumpWithoutBinary())
@@ -310,13 +806,152 @@ func (c *websocketClient) receiveLoop() {
 				c.core.Log(c.ctx, LogLevelDebug, "[%s] receive event, type=%s, event=%s", c.opt.path, event.GetEventType(), message)
 			}
 
-			// 没有 timeout 或者 channel full 处理, 暂时符合预期
-			c.processing.Add(1)
-			c.receiveChan <- event
+			atomic.AddInt64(&c.stats.received, 1)
+			if !c.dispatch(event) {
+				return
+			}
+		}
+	}
+}
+
+// dispatch queues an event for the handlers, it waits while the handlers are behind and returns
+// false if the client is closed meanwhile
+func (c *websocketClient) dispatch(event IWebSocketEvent) bool {
+	c.processing.Add(1)
+	select {
+	case c.receiveChan <- event:
+		return true
+	default:
+	}
+	c.core.Log(c.ctx, LogLevelWarn, "[%s] receive channel full, the handlers are slower than the server, event_type=%s", c.opt.path, event.GetEventType())
+	select {
+	case c.receiveChan <- event:
+		return true
+	case <-c.ctx.Done():
+		c.processing.Done()
+		return false
+	}
+}
+
+// reconnect dials a new connection by the reconnect policy and sends the replay events again, the
+// events sent meanwhile are buffered by sendChan. It returns false if all the dials failed.
+func (c *websocketClient) reconnect(lost websocketConn, cause error) bool {
+	c.mu.Lock()
+	c.ready = make(chan struct{})
+	ready := c.ready
+	c.mu.Unlock()
+	_ = lost.Close()
+
+	c.core.Log(c.ctx, LogLevelWarn, "[%s] connection lost, reconnecting, err=%s", c.opt.path, cause)
+	c.emitEvent(&WebSocketReconnectingEvent{
+		baseWebSocketEvent: baseWebSocketEvent{EventType: WebSocketEventTypeReconnecting},
+		Data:               &WebSocketReconnectingEventData{Err: cause},
+	})
+
+	policy := c.opt.Reconnect
+	for attempt := 1; attempt <= policy.maxAttempts(); attempt++ {
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-c.ctx.Done():
+			return false
+		}
+		conn, err := c.dialConn()
+		if err == nil {
+			err = c.replay(conn)
+		}
+		if err != nil {
+			c.core.Log(c.ctx, LogLevelWarn, "[%s] reconnect failed, attempt=%d, err=%s", c.opt.path, attempt, err)
+			continue
+		}
+
+		c.mu.Lock()
+		c.conn = conn
+		c.mu.Unlock()
+		c.watchConn(conn)
+		close(ready)
+		c.core.Log(c.ctx, LogLevelInfo, "[%s] reconnected, attempts=%d", c.opt.path, attempt)
+		c.emitEvent(&WebSocketReconnectedEvent{
+			baseWebSocketEvent: baseWebSocketEvent{EventType: WebSocketEventTypeReconnected},
+			Data:               &WebSocketReconnectedEventData{Attempts: attempt},
+		})
+		return true
+	}
+
+	close(ready)
+	return false
+}
+
+// lose ends the client after the connection is lost for good, including all the reconnect
+// attempts failed. The client is disconnected, the queued and waiting senders fail with
+// ErrWebSocketClosed and the waits end. The goroutines stop once the closed event is handled, so
+// Close does nothing afterwards.
+func (c *websocketClient) lose(err error) {
+	c.mu.Lock()
+	c.connected = false
+	conn := c.conn
+	c.mu.Unlock()
+	_ = conn.Close()
+	c.closeOnce.Do(func() { close(c.closeChan) })
+
+	c.handleClientError(fmt.Errorf("failed to read message: %w", err))
+	c.emitEvent(newWebSocketClosedEvent(err))
+	c.waiter.shutdown()
+	c.processing.Wait()
+	c.cancel()
+}
+
+// replay sends the last config events on a new connection before it is used
+func (c *websocketClient) replay(conn websocketConn) error {
+	c.mu.RLock()
+	events := make([]IWebSocketEvent, 0, len(c.replayEvents))
+	for _, eventType := range c.opt.replayEventTypes {
+		if event, ok := c.replayEvents[eventType]; ok {
+			events = append(events, event)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if control, ok := conn.(websocketControlConn); ok && c.opt.WriteTimeout > 0 {
+			_ = control.SetWriteDeadline(time.Now().Add(c.opt.WriteTimeout))
 		}
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// isServerClose returns whether the server closed the connection on purpose, which is not reconnected
+func isServerClose(err error) bool {
+	return websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.ClosePolicyViolation)
+}
+
+// newWebSocketClosedEvent creates the closed event of a connection ended by err
+func newWebSocketClosedEvent(err error) *WebSocketClosedEvent {
+	data := &WebSocketClosedEventData{Code: websocket.CloseAbnormalClosure, Reason: err.Error()}
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		data.Code, data.Reason = closeErr.Code, closeErr.Text
+	}
+	return &WebSocketClosedEvent{
+		baseWebSocketEvent: baseWebSocketEvent{EventType: WebSocketEventTypeClosed},
+		Data:               data,
 	}
 }
 
+// emitEvent dispatches an event raised by the client itself like a received event
+func (c *websocketClient) emitEvent(event IWebSocketEvent) {
+	_ = c.waiter.triggerEvent(event.GetEventType(), event)
+	c.publish(event)
+	c.dispatch(event)
+}
+
 // handleEvents processes received events
 func (c *websocketClient) handleEvents() {
 	for {
@@ -332,35 +967,64 @@ func (c *websocketClient) handleEvents() {
 func (c *websocketClient) handleEvent(event IWebSocketEvent) {
 	defer c.processing.Done()
 
-	handler := c.getHandler(event.GetEventType())
-
-	if handler != nil {
-		if err := handler(event); err != nil {
-			c.core.Log(c.ctx, LogLevelWarn, "[%s] handler %s failed, logid=%s, err=%s", c.opt.path, event.GetEventType(), event.GetDetail().LogID, err)
+	if err := c.callHandler(event); err != nil {
+		var logID string
+		if detail := event.GetDetail(); detail != nil {
+			logID = detail.LogID
 		}
+		c.core.Log(c.ctx, LogLevelWarn, "[%s] handler %s failed, logid=%s, err=%s", c.opt.path, event.GetEventType(), logID, err)
 	}
 }
 
 // handleClientError handles errors
 func (c *websocketClient) handleClientError(err error) {
-	handler := c.getHandler(WebSocketEventTypeClientError)
-	if handler == nil || err == nil {
+	if err == nil {
 		return
 	}
-	if err := handler(&WebSocketClientErrorEvent{
+	event := &WebSocketClientErrorEvent{
 		baseWebSocketEvent: baseWebSocketEvent{
 			EventType: WebSocketEventTypeClientError,
 		},
 		Data: err,
-	}); err != nil {
+	}
+	c.publish(event)
+	if err := c.callHandler(event); err != nil {
 		c.core.Log(c.ctx, LogLevelWarn, "[%s] handler %s failed, err=%s", c.opt.path, WebSocketEventTypeClientError, err)
 	}
 }
 
 func (c *websocketClient) getHandler(eventType WebSocketEventType) EventHandler {
-	handler, ok := c.handlers.Load(eventType)
-	if !ok {
-		return nil
+	return c.handlers.get(eventType)
+}
+
+// pendingEvents counts the events queued or being handled. Unlike sync.WaitGroup, Add may be
+// called concurrently with Wait, which happens when the client raises events while closing.
+type pendingEvents struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	n    int
+}
+
+func (p *pendingEvents) Add(delta int) {
+	p.mu.Lock()
+	p.n += delta
+	if p.n <= 0 && p.cond != nil {
+		p.cond.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+func (p *pendingEvents) Done() {
+	p.Add(-1)
+}
+
+func (p *pendingEvents) Wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cond == nil {
+		p.cond = sync.NewCond(&p.mu)
+	}
+	for p.n > 0 {
+		p.cond.Wait()
 	}
-	return handler.(EventHandler)
 }
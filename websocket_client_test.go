@@ -148,3 +148,74 @@ func (r *mockWebSocketConn) ReadMessage() (messageType int, p []byte, err error)
 
 	return websocket.TextMessage, []byte(excepted), nil
 }
+
+// fakeWebSocketConn is a connection driven by the test: messages and errors pushed to incoming are
+// read by the client, written messages are pushed to written.
+type fakeWebSocketConn struct {
+	incoming chan any // []byte or error
+	written  chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func newFakeWebSocketConn() *fakeWebSocketConn {
+	return &fakeWebSocketConn{
+		incoming: make(chan any, 100),
+		written:  make(chan []byte, 100),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (r *fakeWebSocketConn) Close() error {
+	r.once.Do(func() { close(r.closed) })
+	return nil
+}
+
+func (r *fakeWebSocketConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-r.closed:
+		return net.ErrClosed
+	default:
+	}
+	r.written <- data
+	return nil
+}
+
+func (r *fakeWebSocketConn) ReadMessage() (messageType int, p []byte, err error) {
+	select {
+	case item := <-r.incoming:
+		if err, ok := item.(error); ok {
+			return 0, nil, err
+		}
+		return websocket.TextMessage, item.([]byte), nil
+	case <-r.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// fakeWebSocketDialer returns the conns in order, and fails when there is none left
+func fakeWebSocketDialer(conns ...websocketConn) websocketDialer {
+	var mu sync.Mutex
+	return func(dialer websocket.Dialer, urlStr string, requestHeader http.Header) (websocketConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(conns) == 0 {
+			return nil, errors.New("dial failed")
+		}
+		conn := conns[0]
+		conns = conns[1:]
+		if conn == nil {
+			return nil, errors.New("dial failed")
+		}
+		return conn, nil
+	}
+}
+
+func newFakeWebSocketCore() *core {
+	return newCore(&clientOption{
+		baseURL:  CnBaseURL,
+		logLevel: LogLevelInfo,
+		logger:   newStdLogger(),
+		auth:     NewTokenAuth("token"),
+	})
+}
@@ -25,6 +25,14 @@ type WebSocketClientErrorEvent struct {
 // seq:common:2
 type WebSocketClosedEvent struct {
 	baseWebSocketEvent
+	Data *WebSocketClosedEventData `json:"data,omitempty"`
+}
+
+// WebSocketClosedEventData contains why the connection is closed
+type WebSocketClosedEventData struct {
+	// The close code of the server, or 1006 if the connection is lost without a close frame.
+	Code   int    `json:"code"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // WebSocketErrorEvent represents an error event
@@ -34,6 +42,34 @@ type WebSocketErrorEvent struct {
 	Data *Error `json:"data,omitempty"`
 }
 
+// WebSocketReconnectingEvent represents the connection is lost and the client is reconnecting, only
+// fired if WebSocketClientOption.Reconnect is set
+// seq:common:4
+type WebSocketReconnectingEvent struct {
+	baseWebSocketEvent
+	Data *WebSocketReconnectingEventData `json:"data,omitempty"`
+}
+
+// WebSocketReconnectingEventData contains the reason of reconnecting
+type WebSocketReconnectingEventData struct {
+	// The error of the lost connection.
+	Err error `json:"-"`
+}
+
+// WebSocketReconnectedEvent represents the client is reconnected, the last config event such as
+// chat.update has been sent again
+// seq:common:5
+type WebSocketReconnectedEvent struct {
+	baseWebSocketEvent
+	Data *WebSocketReconnectedEventData `json:"data,omitempty"`
+}
+
+// WebSocketReconnectedEventData contains the result of reconnecting
+type WebSocketReconnectedEventData struct {
+	// The number of dials until reconnected.
+	Attempts int `json:"attempts"`
+}
+
 // v1/audio/speech req
 
 // WebSocketSpeechUpdateEvent 流式输入文字
@@ -12,9 +12,11 @@ type WebSocketEventType string
 const (
 	// common
 
-	WebSocketEventTypeClientError WebSocketEventType = "client_error" // sdk error
-	WebSocketEventTypeClosed      WebSocketEventType = "closed"       // connection closed
-	WebSocketEventTypeError       WebSocketEventType = "error"        // 发生错误
+	WebSocketEventTypeClientError  WebSocketEventType = "client_error" // sdk error
+	WebSocketEventTypeClosed       WebSocketEventType = "closed"       // connection closed
+	WebSocketEventTypeError        WebSocketEventType = "error"        // 发生错误
+	WebSocketEventTypeReconnecting WebSocketEventType = "reconnecting" // sdk 断线重连中
+	WebSocketEventTypeReconnected  WebSocketEventType = "reconnected"  // sdk 重连成功
 
 	// v1/audio/speech
 
@@ -90,9 +92,11 @@ const (
 
 var websocketEvents = map[string]reflect.Type{
 	// common
-	string(WebSocketEventTypeClientError): reflect.TypeOf(WebSocketClientErrorEvent{}),
-	string(WebSocketEventTypeClosed):      reflect.TypeOf(WebSocketClosedEvent{}),
-	string(WebSocketEventTypeError):       reflect.TypeOf(WebSocketErrorEvent{}),
+	string(WebSocketEventTypeClientError):  reflect.TypeOf(WebSocketClientErrorEvent{}),
+	string(WebSocketEventTypeClosed):       reflect.TypeOf(WebSocketClosedEvent{}),
+	string(WebSocketEventTypeError):        reflect.TypeOf(WebSocketErrorEvent{}),
+	string(WebSocketEventTypeReconnecting): reflect.TypeOf(WebSocketReconnectingEvent{}),
+	string(WebSocketEventTypeReconnected):  reflect.TypeOf(WebSocketReconnectedEvent{}),
 
 	// v1/audio/speech req
 	string(WebSocketEventTypeSpeechUpdate):            reflect.TypeOf(WebSocketSpeechUpdateEvent{}),
@@ -149,59 +153,7 @@ var websocketEvents = map[string]reflect.Type{
 	string(WebSocketEventTypeInputAudioBufferSpeechStopped):        reflect.TypeOf(WebSocketInputAudioBufferSpeechStoppedEvent{}),
 }
 
-var audioSpeechResponseEventTypes = []WebSocketEventType{
-	WebSocketEventTypeClientError,
-	WebSocketEventTypeClosed,
-	WebSocketEventTypeError,
-
-	WebSocketEventTypeSpeechCreated,
-	WebSocketEventTypeSpeechUpdated,
-	WebSocketEventTypeInputTextBufferCompleted,
-	WebSocketEventTypeSpeechAudioUpdate,
-	WebSocketEventTypeSpeechAudioCompleted,
-}
-
-var audioTranscriptionResponseEventTypes = []WebSocketEventType{
-	WebSocketEventTypeClientError,
-	WebSocketEventTypeClosed,
-	WebSocketEventTypeError,
-
-	WebSocketEventTypeTranscriptionsCreated,
-	WebSocketEventTypeTranscriptionsUpdated,
-	WebSocketEventTypeInputAudioBufferCompleted,
-	WebSocketEventTypeInputAudioBufferCleared,
-	WebSocketEventTypeTranscriptionsMessageUpdate,
-	WebSocketEventTypeTranscriptionsMessageCompleted,
-}
-
-var chatResponseEventTypes = []WebSocketEventType{
-	WebSocketEventTypeClientError,
-	WebSocketEventTypeClosed,
-	WebSocketEventTypeError,
-
-	WebSocketEventTypeChatCreated,
-	WebSocketEventTypeChatUpdated,
-	WebSocketEventTypeConversationChatCreated,
-	WebSocketEventTypeConversationChatInProgress,
-	WebSocketEventTypeConversationMessageDelta,
-	WebSocketEventTypeConversationAudioSentenceStart,
-	WebSocketEventTypeConversationAudioDelta,
-	WebSocketEventTypeConversationMessageCompleted,
-	WebSocketEventTypeConversationAudioCompleted,
-	WebSocketEventTypeConversationChatCompleted,
-	WebSocketEventTypeConversationChatFailed,
-	WebSocketEventTypeInputAudioBufferCompleted,
-	WebSocketEventTypeInputAudioBufferCleared,
-	WebSocketEventTypeConversationCleared,
-	WebSocketEventTypeConversationChatCanceled,
-	WebSocketEventTypeConversationAudioTranscriptUpdate,
-	WebSocketEventTypeConversationAudioTranscriptCompleted,
-	WebSocketEventTypeConversationChatRequiresAction,
-	WebSocketEventTypeInputAudioBufferSpeechStarted,
-	WebSocketEventTypeInputAudioBufferSpeechStopped,
-}
-
-const websocketEventTypeSize = 45
+const websocketEventTypeSize = 47
 
 var websocketEventTypes = [websocketEventTypeSize]WebSocketEventType{
 	WebSocketEventTypeClientError,
@@ -249,6 +201,8 @@ var websocketEventTypes = [websocketEventTypeSize]WebSocketEventType{
 	WebSocketEventTypeConversationChatRequiresAction,
 	WebSocketEventTypeInputAudioBufferSpeechStarted,
 	WebSocketEventTypeInputAudioBufferSpeechStopped,
+	WebSocketEventTypeReconnecting,
+	WebSocketEventTypeReconnected,
 }
 
 var websocketEventTypeIndex = map[WebSocketEventType]int{}
@@ -0,0 +1,234 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrWebSocketClosed is returned by AwaitWebSocketEvent if the connection is closed before the
+// awaited event is received, and by the send methods if the connection is lost for good before
+// the event is written
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// ErrWebSocketEventsLagged is returned by a subscription dropped since its buffer was full, the
+// consumer was slower than the server
+var ErrWebSocketEventsLagged = errors.New("websocket events subscription lagged")
+
+// WebSocketEvents is a subscription to the events of a WebSocket client, it receives the events in
+// the order they arrive, independent of the registered handlers. The subscription buffers the
+// events received after it's created, so events responding to a request sent after subscribing
+// are never missed.
+//
+// The buffer holds ReceiveChanCapacity events. A subscription falling behind never holds up the
+// client, it's dropped once its buffer is full and fails with ErrWebSocketEventsLagged after the
+// buffered events are received.
+type WebSocketEvents struct {
+	client     *websocketClient
+	ctx        context.Context
+	eventTypes map[WebSocketEventType]bool
+	events     chan IWebSocketEvent
+	done       chan struct{}
+	closeOnce  sync.Once
+	lagged     chan struct{}
+	lagOnce    sync.Once
+	chanOnce   sync.Once
+	ch         chan IWebSocketEvent
+}
+
+// subscribe creates a subscription of eventTypes, or all the events if eventTypes is empty
+func (c *websocketClient) subscribe(ctx context.Context, eventTypes []WebSocketEventType) *WebSocketEvents {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	events := &WebSocketEvents{
+		client: c,
+		ctx:    ctx,
+		events: make(chan IWebSocketEvent, c.opt.ReceiveChanCapacity),
+		done:   make(chan struct{}),
+		lagged: make(chan struct{}),
+	}
+	if len(eventTypes) > 0 {
+		events.eventTypes = make(map[WebSocketEventType]bool, len(eventTypes))
+		for _, eventType := range eventTypes {
+			events.eventTypes[eventType] = true
+		}
+	}
+	c.subscriptions.Store(events, struct{}{})
+	return events
+}
+
+// publish passes an event to the subscriptions, a subscription whose buffer is full is dropped
+// instead of holding up receiveLoop
+func (c *websocketClient) publish(event IWebSocketEvent) {
+	c.subscriptions.Range(func(key, _ any) bool {
+		events := key.(*WebSocketEvents)
+		if events.eventTypes != nil && !events.eventTypes[event.GetEventType()] {
+			return true
+		}
+		select {
+		case events.events <- event:
+		default:
+			c.core.Log(c.ctx, LogLevelWarn, "[%s] events subscription full, the consumer is slower than the server, dropped, event_type=%s", c.opt.path, event.GetEventType())
+			events.lag()
+		}
+		return true
+	})
+}
+
+// lag drops the subscription from the client, the buffered events can still be received
+func (e *WebSocketEvents) lag() {
+	e.lagOnce.Do(func() {
+		e.client.subscriptions.Delete(e)
+		close(e.lagged)
+	})
+}
+
+// Recv returns the next event. It returns io.EOF after the connection is closed and all the
+// buffered events are received, ErrWebSocketEventsLagged if the subscription fell behind, or the
+// error of ctx if it's done.
+func (e *WebSocketEvents) Recv() (IWebSocketEvent, error) {
+	select {
+	case event := <-e.events:
+		return event, nil
+	default:
+	}
+	select {
+	case event := <-e.events:
+		return event, nil
+	case <-e.client.receiveDone:
+		select {
+		case event := <-e.events:
+			return event, nil
+		default:
+			e.Close()
+			return nil, io.EOF
+		}
+	case <-e.lagged:
+		select {
+		case event := <-e.events:
+			return event, nil
+		default:
+			e.Close()
+			return nil, ErrWebSocketEventsLagged
+		}
+	case <-e.done:
+		return nil, io.EOF
+	case <-e.ctx.Done():
+		e.Close()
+		return nil, e.ctx.Err()
+	}
+}
+
+// C returns a channel of the events, it's closed when Recv would return an error, see Err
+func (e *WebSocketEvents) C() <-chan IWebSocketEvent {
+	e.chanOnce.Do(func() {
+		e.ch = make(chan IWebSocketEvent)
+		go func() {
+			defer close(e.ch)
+			for {
+				event, err := e.Recv()
+				if err != nil {
+					return
+				}
+				select {
+				case e.ch <- event:
+				case <-e.done:
+					return
+				case <-e.ctx.Done():
+					e.Close()
+					return
+				}
+			}
+		}()
+	})
+	return e.ch
+}
+
+// Err returns ErrWebSocketEventsLagged if the subscription fell behind, nil otherwise
+func (e *WebSocketEvents) Err() error {
+	select {
+	case <-e.lagged:
+		return ErrWebSocketEventsLagged
+	default:
+		return nil
+	}
+}
+
+// Close stops the subscription, the events not received yet are dropped
+func (e *WebSocketEvents) Close() {
+	e.closeOnce.Do(func() {
+		e.client.subscriptions.Delete(e)
+		close(e.done)
+	})
+}
+
+// AwaitWebSocketEvent receives events from the subscription until an event of eventType arrives
+// and returns it as T. It fails if an error event arrives or the connection is closed first.
+//
+// It's a function since methods can't have type parameters. It takes a subscription created before
+// the request is sent, since subscribing after sending may miss a fast response.
+//
+//	events := client.Events(ctx)
+//	defer events.Close()
+//	if err := client.ChatUpdate(data); err != nil {
+//		return err
+//	}
+//	updated, err := coze.AwaitWebSocketEvent[*coze.WebSocketChatUpdatedEvent](ctx, events, coze.WebSocketEventTypeChatUpdated)
+func AwaitWebSocketEvent[T IWebSocketEvent](ctx context.Context, events *WebSocketEvents, eventType WebSocketEventType) (T, error) {
+	var zero T
+	if events.eventTypes != nil && !events.eventTypes[eventType] {
+		return zero, fmt.Errorf("await event_type: %s not subscribed", eventType)
+	}
+	for {
+		event, err := events.recvContext(ctx)
+		if err == io.EOF {
+			return zero, ErrWebSocketClosed
+		} else if err != nil {
+			return zero, err
+		}
+		if event.GetEventType() == eventType {
+			if e, ok := event.(T); ok {
+				return e, nil
+			}
+			return zero, fmt.Errorf("await event_type: %s, unexpected event %T", eventType, event)
+		}
+		switch e := event.(type) {
+		case *WebSocketErrorEvent:
+			if e.Data != nil {
+				return zero, e.Data
+			}
+			return zero, fmt.Errorf("await event_type: %s, receive error event, id=%s", eventType, e.GetID())
+		case *WebSocketClosedEvent:
+			return zero, ErrWebSocketClosed
+		}
+	}
+}
+
+// recvContext is Recv which also returns if ctx is done, the subscription is kept open
+func (e *WebSocketEvents) recvContext(ctx context.Context) (IWebSocketEvent, error) {
+	if ctx == nil || ctx.Done() == nil {
+		return e.Recv()
+	}
+	select {
+	case event := <-e.events:
+		return event, nil
+	default:
+	}
+	select {
+	case event := <-e.events:
+		return event, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-e.client.receiveDone:
+		return e.Recv()
+	case <-e.lagged:
+		return e.Recv()
+	case <-e.done:
+		return nil, io.EOF
+	case <-e.ctx.Done():
+		return e.Recv()
+	}
+}
@@ -0,0 +1,32 @@
+//go:build go1.23
+
+package coze
+
+import (
+	"io"
+	"iter"
+)
+
+// All returns an iterator over the events of the subscription, it stops after the connection is
+// closed, or yields the error once if the subscription fell behind or ctx is done. It's a method
+// of the subscription built with Go 1.23, since the module supports Go versions without iter.
+//
+//	for event, err := range client.Events(ctx).All() {
+//		...
+//	}
+func (e *WebSocketEvents) All() iter.Seq2[IWebSocketEvent, error] {
+	return func(yield func(IWebSocketEvent, error) bool) {
+		for {
+			event, err := e.Recv()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build go1.23
+
+package coze
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketEventsAll(t *testing.T) {
+	as := assert.New(t)
+	conn := newFakeWebSocketConn()
+	chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{
+		WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+	})
+	as.Nil(chat.Connect())
+	events := chat.Events(context.Background(), WebSocketEventTypeConversationMessageDelta)
+	defer events.Close()
+
+	conn.incoming <- []byte(`{"event_type":"conversation.message.delta","data":{"content":"a"}}`)
+	conn.incoming <- []byte(`{"event_type":"conversation.message.delta","data":{"content":"b"}}`)
+
+	var content string
+	for event, err := range events.All() {
+		as.Nil(err)
+		content += event.(*WebSocketConversationMessageDeltaEvent).Data.Content
+		if content == "ab" {
+			break
+		}
+	}
+	as.Equal("ab", content)
+	as.Nil(chat.Close())
+}
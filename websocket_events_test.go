@@ -0,0 +1,157 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketEvents(t *testing.T) {
+	as := assert.New(t)
+
+	newChat := func(conn *fakeWebSocketConn) *WebSocketChat {
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+		})
+		as.Nil(chat.Connect())
+		return chat
+	}
+
+	t.Run("recv in order until closed", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newChat(conn)
+		events := chat.Events(context.Background())
+		defer events.Close()
+
+		conn.incoming <- []byte(`{"event_type":"chat.created","id":"1"}`)
+		conn.incoming <- []byte(`{"event_type":"conversation.message.delta","id":"2","data":{"content":"hi"}}`)
+		conn.incoming <- errors.New("connection reset")
+
+		var types []WebSocketEventType
+		for {
+			event, err := events.Recv()
+			if err == io.EOF {
+				break
+			}
+			as.Nil(err)
+			types = append(types, event.GetEventType())
+		}
+		as.Equal([]WebSocketEventType{
+			WebSocketEventTypeChatCreated,
+			WebSocketEventTypeConversationMessageDelta,
+			WebSocketEventTypeClientError,
+			WebSocketEventTypeClosed,
+		}, types)
+	})
+
+	t.Run("filter and channel", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newChat(conn)
+		events := chat.Events(context.Background(), WebSocketEventTypeConversationMessageDelta)
+		defer events.Close()
+
+		conn.incoming <- []byte(`{"event_type":"chat.created"}`)
+		conn.incoming <- []byte(`{"event_type":"conversation.message.delta","data":{"content":"a"}}`)
+		conn.incoming <- []byte(`{"event_type":"conversation.message.delta","data":{"content":"b"}}`)
+
+		var content string
+		for event := range events.C() {
+			content += event.(*WebSocketConversationMessageDeltaEvent).Data.Content
+			if content == "ab" {
+				as.Nil(chat.Close())
+			}
+		}
+		as.Equal("ab", content)
+	})
+
+	t.Run("ctx done", func(t *testing.T) {
+		chat := newChat(newFakeWebSocketConn())
+		ctx, cancel := context.WithCancel(context.Background())
+		events := chat.Events(ctx)
+		cancel()
+		_, err := events.Recv()
+		as.True(errors.Is(err, context.Canceled))
+		_, ok := chat.ws.subscriptions.Load(events)
+		as.False(ok)
+		as.Nil(chat.Close())
+	})
+
+	t.Run("await", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newChat(conn)
+		events := chat.Events(context.Background())
+		defer events.Close()
+
+		as.Nil(chat.ChatUpdate(&WebSocketChatUpdateEventData{ChatConfig: &WebSocketChatConfig{UserID: ptr("user")}}))
+		as.Contains(string(<-conn.written), `"event_type":"chat.update"`)
+		// the response may arrive before awaiting
+		conn.incoming <- []byte(`{"event_type":"chat.created"}`)
+		conn.incoming <- []byte(`{"event_type":"chat.updated","id":"updated","data":{"chat_config":{"user_id":"user"}}}`)
+		as.Eventually(func() bool { return len(events.events) == 2 }, time.Second, time.Millisecond)
+
+		updated, err := AwaitWebSocketEvent[*WebSocketChatUpdatedEvent](context.Background(), events, WebSocketEventTypeChatUpdated)
+		as.Nil(err)
+		as.Equal("updated", updated.ID)
+		as.Equal("user", *updated.Data.ChatConfig.UserID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = AwaitWebSocketEvent[*WebSocketChatUpdatedEvent](ctx, events, WebSocketEventTypeChatUpdated)
+		as.True(errors.Is(err, context.DeadlineExceeded))
+
+		conn.incoming <- []byte(`{"event_type":"error","data":{"code":4000,"msg":"invalid config"}}`)
+		_, err = AwaitWebSocketEvent[*WebSocketChatUpdatedEvent](context.Background(), events, WebSocketEventTypeChatUpdated)
+		as.NotNil(err)
+
+		conn.incoming <- errors.New("connection reset")
+		_, err = AwaitWebSocketEvent[*WebSocketChatUpdatedEvent](context.Background(), events, WebSocketEventTypeChatUpdated)
+		as.True(errors.Is(err, ErrWebSocketClosed))
+	})
+
+	t.Run("await mismatched type", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newChat(conn)
+		events := chat.Events(context.Background())
+		defer events.Close()
+		conn.incoming <- []byte(`{"event_type":"chat.updated"}`)
+		_, err := AwaitWebSocketEvent[*WebSocketChatCreatedEvent](context.Background(), events, WebSocketEventTypeChatUpdated)
+		as.NotNil(err)
+
+		filtered := chat.Events(context.Background(), WebSocketEventTypeChatCreated)
+		defer filtered.Close()
+		_, err = AwaitWebSocketEvent[*WebSocketChatUpdatedEvent](context.Background(), filtered, WebSocketEventTypeChatUpdated)
+		as.NotNil(err)
+		as.Nil(chat.Close())
+	})
+	t.Run("lagged subscription is dropped", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn), ReceiveChanCapacity: 2},
+		})
+		as.Nil(chat.Connect())
+		defer chat.Close()
+		events := chat.Events(context.Background(), WebSocketEventTypeConversationMessageDelta)
+		defer events.Close()
+
+		for i := 0; i < 3; i++ {
+			conn.incoming <- []byte(`{"event_type":"conversation.message.delta","data":{"content":"a"}}`)
+		}
+		// the client isn't held up by the subscription
+		conn.incoming <- []byte(`{"event_type":"chat.created"}`)
+		as.Nil(chat.ws.WaitForEvent([]WebSocketEventType{WebSocketEventTypeChatCreated}, false))
+		_, ok := chat.ws.subscriptions.Load(events)
+		as.False(ok)
+		as.Equal(ErrWebSocketEventsLagged, events.Err())
+
+		for i := 0; i < 2; i++ {
+			_, err := events.Recv()
+			as.Nil(err)
+		}
+		_, err := events.Recv()
+		as.Equal(ErrWebSocketEventsLagged, err)
+	})
+}
@@ -0,0 +1,183 @@
+package coze
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// WebSocketMiddleware wraps the handlers of an event, the first registered middleware is the
+// outermost one
+type WebSocketMiddleware func(next EventHandler) EventHandler
+
+// websocketHandler is a registered handler, its pointer identifies it for removal
+type websocketHandler struct {
+	handler EventHandler
+}
+
+// websocketHandlers holds the handlers of each event type, the wildcard handlers of all the events
+// and the middlewares
+type websocketHandlers struct {
+	mu          sync.RWMutex
+	byType      map[WebSocketEventType][]*websocketHandler
+	primary     map[WebSocketEventType]*websocketHandler // set by OnEvent
+	wildcard    []*websocketHandler
+	middlewares []WebSocketMiddleware
+}
+
+func newWebSocketHandlers() *websocketHandlers {
+	return &websocketHandlers{
+		byType:  map[WebSocketEventType][]*websocketHandler{},
+		primary: map[WebSocketEventType]*websocketHandler{},
+	}
+}
+
+// set replaces the handler set by the previous call with eventType in place, or adds it
+func (r *websocketHandlers) set(eventType WebSocketEventType, handler EventHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.primary[eventType]; ok {
+		h.handler = handler
+		return
+	}
+	h := &websocketHandler{handler: handler}
+	r.primary[eventType] = h
+	r.byType[eventType] = append(r.byType[eventType], h)
+}
+
+// add appends a handler of eventType and returns the func removing it
+func (r *websocketHandlers) add(eventType WebSocketEventType, handler EventHandler) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := &websocketHandler{handler: handler}
+	r.byType[eventType] = append(r.byType[eventType], h)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.byType[eventType] = removeWebSocketHandler(r.byType[eventType], h)
+	}
+}
+
+// addWildcard appends a handler of all the events and returns the func removing it
+func (r *websocketHandlers) addWildcard(handler EventHandler) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := &websocketHandler{handler: handler}
+	r.wildcard = append(r.wildcard, h)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.wildcard = removeWebSocketHandler(r.wildcard, h)
+	}
+}
+
+// remove removes all the handlers of eventType, the wildcard handlers are kept
+func (r *websocketHandlers) remove(eventType WebSocketEventType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byType, eventType)
+	delete(r.primary, eventType)
+}
+
+func (r *websocketHandlers) use(middlewares ...WebSocketMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// get returns the handlers of eventType followed by the wildcard handlers wrapped by the
+// middlewares, or nil if there is no handler. All the handlers are called even if one fails, the
+// first error is returned.
+func (r *websocketHandlers) get(eventType WebSocketEventType) EventHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handlers := make([]EventHandler, 0, len(r.byType[eventType])+len(r.wildcard))
+	for _, h := range r.byType[eventType] {
+		handlers = append(handlers, h.handler)
+	}
+	for _, h := range r.wildcard {
+		handlers = append(handlers, h.handler)
+	}
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	var handler EventHandler
+	if len(handlers) == 1 {
+		handler = handlers[0]
+	} else {
+		handler = func(event IWebSocketEvent) error {
+			var firstErr error
+			for _, h := range handlers {
+				if err := h(event); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			return firstErr
+		}
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	return handler
+}
+
+func removeWebSocketHandler(handlers []*websocketHandler, h *websocketHandler) []*websocketHandler {
+	for i, v := range handlers {
+		if v == h {
+			return append(handlers[:i:i], handlers[i+1:]...)
+		}
+	}
+	return handlers
+}
+
+// WebSocketRecoverMiddleware recovers from the panics of the handlers and returns them as errors,
+// so the middlewares outside of it see them like errors. The client recovers from panics anyway,
+// and logs them with the stack.
+func WebSocketRecoverMiddleware() WebSocketMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(event IWebSocketEvent) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("handler %s panic: %v", event.GetEventType(), r)
+				}
+			}()
+			return next(event)
+		}
+	}
+}
+
+// WebSocketLoggingMiddleware logs every handled event with the time the handlers took at debug
+// level, and the failed ones at warn level
+func WebSocketLoggingMiddleware(logger Logger) WebSocketMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(event IWebSocketEvent) error {
+			start := time.Now()
+			err := next(event)
+			if err != nil {
+				logger.Log(context.Background(), LogLevelWarn, "[websocket] handle event failed, event_type=%s, id=%s, duration=%s, err=%s", event.GetEventType(), event.GetID(), time.Since(start), err)
+			} else {
+				logger.Log(context.Background(), LogLevelDebug, "[websocket] handle event, event_type=%s, id=%s, duration=%s", event.GetEventType(), event.GetID(), time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// callHandler calls the handlers of the event, a panic is logged and reported as an error instead
+// of killing the calling loop
+func (c *websocketClient) callHandler(event IWebSocketEvent) (err error) {
+	handler := c.getHandler(event.GetEventType())
+	if handler == nil {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.core.Log(c.ctx, LogLevelError, "[%s] handler %s panic, err=%v, stack=%s", c.opt.path, event.GetEventType(), r, debug.Stack())
+			err = fmt.Errorf("handler %s panic: %v", event.GetEventType(), r)
+		}
+	}()
+	return handler(event)
+}
@@ -0,0 +1,135 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recordLogger) Log(ctx context.Context, level LogLevel, message string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, fmt.Sprintf(message, args...))
+}
+
+func TestWebSocketHandlers(t *testing.T) {
+	as := assert.New(t)
+	created := &WebSocketChatCreatedEvent{baseWebSocketEvent: baseWebSocketEvent{EventType: WebSocketEventTypeChatCreated, ID: "1"}}
+
+	t.Run("ordered handlers", func(t *testing.T) {
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{})
+		var calls []string
+		record := func(name string) EventHandler {
+			return func(event IWebSocketEvent) error {
+				calls = append(calls, name)
+				return nil
+			}
+		}
+		as.Nil(chat.ws.getHandler(WebSocketEventTypeChatCreated))
+
+		chat.OnChatCreated(func(ctx context.Context, cli *WebSocketChat, event *WebSocketChatCreatedEvent) error {
+			calls = append(calls, "app")
+			return nil
+		})
+		removeMetrics := chat.AddEventHandler(WebSocketEventTypeChatCreated, record("metrics"))
+		removeWildcard := chat.AddWildcardEventHandler(record("wildcard"))
+		// replaces the app handler in place
+		chat.OnEvent(WebSocketEventTypeChatCreated, record("app2"))
+
+		as.Nil(chat.ws.getHandler(WebSocketEventTypeChatCreated)(created))
+		as.Equal([]string{"app2", "metrics", "wildcard"}, calls)
+
+		calls = nil
+		as.Nil(chat.ws.getHandler(WebSocketEventTypeChatUpdated)(created))
+		as.Equal([]string{"wildcard"}, calls)
+
+		calls = nil
+		removeMetrics()
+		removeMetrics()
+		as.Nil(chat.ws.getHandler(WebSocketEventTypeChatCreated)(created))
+		as.Equal([]string{"app2", "wildcard"}, calls)
+
+		calls = nil
+		chat.RemoveEventHandlers(WebSocketEventTypeChatCreated)
+		as.Nil(chat.ws.getHandler(WebSocketEventTypeChatCreated)(created))
+		as.Equal([]string{"wildcard"}, calls)
+
+		removeWildcard()
+		as.Nil(chat.ws.getHandler(WebSocketEventTypeChatCreated))
+	})
+
+	t.Run("all handlers run and the first error is returned", func(t *testing.T) {
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{})
+		called := 0
+		chat.AddEventHandler(WebSocketEventTypeChatCreated, func(event IWebSocketEvent) error {
+			called++
+			return errors.New("first")
+		})
+		chat.AddEventHandler(WebSocketEventTypeChatCreated, func(event IWebSocketEvent) error {
+			called++
+			return errors.New("second")
+		})
+		err := chat.ws.getHandler(WebSocketEventTypeChatCreated)(created)
+		as.Equal("first", err.Error())
+		as.Equal(2, called)
+	})
+
+	t.Run("middlewares", func(t *testing.T) {
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{})
+		var calls []string
+		trace := func(name string) WebSocketMiddleware {
+			return func(next EventHandler) EventHandler {
+				return func(event IWebSocketEvent) error {
+					calls = append(calls, name+" before")
+					err := next(event)
+					calls = append(calls, name+" after")
+					return err
+				}
+			}
+		}
+		logger := &recordLogger{}
+		chat.Use(trace("outer"), trace("inner"))
+		chat.Use(WebSocketLoggingMiddleware(logger), WebSocketRecoverMiddleware())
+		chat.AddEventHandler(WebSocketEventTypeChatCreated, func(event IWebSocketEvent) error {
+			calls = append(calls, "handler")
+			panic("boom")
+		})
+
+		err := chat.ws.getHandler(WebSocketEventTypeChatCreated)(created)
+		as.NotNil(err)
+		as.Contains(err.Error(), "boom")
+		as.Equal([]string{"outer before", "inner before", "handler", "inner after", "outer after"}, calls)
+		as.Len(logger.messages, 1)
+		as.Contains(logger.messages[0], "event_type=chat.created")
+		as.Contains(logger.messages[0], "boom")
+	})
+
+	t.Run("panic doesn't kill the event loop", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{
+			WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+		})
+		chat.OnChatCreated(func(ctx context.Context, cli *WebSocketChat, event *WebSocketChatCreatedEvent) error {
+			panic("boom")
+		})
+		updated := make(chan struct{})
+		chat.OnChatUpdated(func(ctx context.Context, cli *WebSocketChat, event *WebSocketChatUpdatedEvent) error {
+			close(updated)
+			return nil
+		})
+		as.Nil(chat.Connect())
+		conn.incoming <- []byte(`{"event_type":"chat.created"}`)
+		conn.incoming <- []byte(`{"event_type":"chat.updated"}`)
+		<-updated
+		as.Nil(chat.Close())
+	})
+}
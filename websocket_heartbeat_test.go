@@ -0,0 +1,164 @@
+package coze
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeControlWebSocketConn is a fakeWebSocketConn supporting control frames, it answers pings
+// by pongs and close frames by close frames when the peer is alive.
+type fakeControlWebSocketConn struct {
+	*fakeWebSocketConn
+	mu       sync.Mutex
+	alive    bool
+	pings    int
+	closeMsg []byte
+	pong     func(appData string) error
+	deadline time.Time
+}
+
+func newFakeControlWebSocketConn(alive bool) *fakeControlWebSocketConn {
+	return &fakeControlWebSocketConn{fakeWebSocketConn: newFakeWebSocketConn(), alive: alive}
+}
+
+func (r *fakeControlWebSocketConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch messageType {
+	case websocket.PingMessage:
+		r.pings++
+		if r.alive && r.pong != nil {
+			_ = r.pong("")
+		}
+	case websocket.CloseMessage:
+		r.closeMsg = data
+		if r.alive {
+			r.incoming <- &websocket.CloseError{Code: websocket.CloseNormalClosure}
+		}
+	}
+	return nil
+}
+
+func (r *fakeControlWebSocketConn) SetWriteDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadline = t
+	return nil
+}
+
+func (r *fakeControlWebSocketConn) SetPongHandler(h func(appData string) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pong = h
+}
+
+func TestWebSocketHeartbeat(t *testing.T) {
+	as := assert.New(t)
+
+	newSpeech := func(opt *WebSocketClientOption) (*WebSocketAudioSpeech, chan *WebSocketClosedEvent) {
+		speech := newWebSocketAudioSpeechClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketAudioSpeechReq{WebSocketClientOption: opt})
+		closed := make(chan *WebSocketClosedEvent, 1)
+		speech.OnClosed(func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketClosedEvent) error {
+			closed <- event
+			return nil
+		})
+		return speech, closed
+	}
+
+	t.Run("ping keeps the connection alive", func(t *testing.T) {
+		conn := newFakeControlWebSocketConn(true)
+		speech, closed := newSpeech(&WebSocketClientOption{
+			dial:         fakeWebSocketDialer(conn),
+			PingInterval: 20 * time.Millisecond,
+			ReadTimeout:  time.Second,
+			WriteTimeout: time.Second,
+		})
+		as.Nil(speech.Connect())
+		time.Sleep(150 * time.Millisecond)
+		as.Empty(closed)
+
+		as.Nil(speech.SpeechUpdate(&WebSocketSpeechUpdateEventData{}))
+		<-conn.written
+		as.Nil(speech.Close())
+
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		as.Greater(conn.pings, 3)
+		as.False(conn.deadline.IsZero())
+		as.Equal(websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), conn.closeMsg)
+	})
+
+	t.Run("dead peer fires closed", func(t *testing.T) {
+		conn := newFakeControlWebSocketConn(false)
+		speech, closed := newSpeech(&WebSocketClientOption{
+			dial:         fakeWebSocketDialer(conn),
+			PingInterval: 10 * time.Millisecond,
+			CloseTimeout: 10 * time.Millisecond,
+		})
+		as.Nil(speech.Connect())
+		event := <-closed
+		as.Equal(websocket.CloseAbnormalClosure, event.Data.Code)
+		as.Equal("no message received in 20ms", event.Data.Reason)
+		as.Nil(speech.Wait(WebSocketEventTypeClosed))
+		as.Nil(speech.Close())
+	})
+
+	t.Run("server close", func(t *testing.T) {
+		conn := newFakeControlWebSocketConn(true)
+		speech, closed := newSpeech(&WebSocketClientOption{
+			dial:      fakeWebSocketDialer(conn),
+			Reconnect: &WebSocketReconnectPolicy{},
+		})
+		as.Nil(speech.Connect())
+		conn.incoming <- &websocket.CloseError{Code: websocket.CloseGoingAway, Text: "restarting"}
+		event := <-closed
+		as.Equal(websocket.CloseGoingAway, event.Data.Code)
+		as.Equal("restarting", event.Data.Reason)
+		as.Nil(speech.Close())
+	})
+
+	t.Run("close code", func(t *testing.T) {
+		conn := newFakeControlWebSocketConn(true)
+		speech, _ := newSpeech(&WebSocketClientOption{dial: fakeWebSocketDialer(conn), CloseCode: websocket.CloseGoingAway})
+		as.Nil(speech.Connect())
+		start := time.Now()
+		as.Nil(speech.Close())
+		as.Less(time.Since(start), 500*time.Millisecond)
+		as.Equal(websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), conn.closeMsg)
+	})
+	t.Run("handlers run during close", func(t *testing.T) {
+		conn := newFakeControlWebSocketConn(true)
+		speech, _ := newSpeech(&WebSocketClientOption{dial: fakeWebSocketDialer(conn)})
+		entered, release := make(chan struct{}), make(chan struct{})
+		handled := make(chan error, 1)
+		speech.OnEvent(WebSocketEventTypeSpeechAudioUpdate, func(event IWebSocketEvent) error {
+			close(entered)
+			<-release
+			as.True(speech.IsConnected())
+			err := speech.InputTextBufferAppendContext(context.Background(), &WebSocketInputTextBufferAppendEventData{Delta: "more"})
+			handled <- err
+			return err
+		})
+		as.Nil(speech.Connect())
+		conn.incoming <- []byte(`{"event_type":"speech.audio.update","data":{"delta":"AQI="}}`)
+		<-entered
+
+		closed := make(chan error, 1)
+		go func() { closed <- speech.Close() }()
+		as.Eventually(func() bool {
+			speech.ws.mu.RLock()
+			defer speech.ws.mu.RUnlock()
+			return speech.ws.stopping
+		}, time.Second, time.Millisecond)
+		close(release)
+		as.Nil(<-handled)
+		as.Contains(string(<-conn.written), "more")
+		as.Nil(<-closed)
+		as.False(speech.IsConnected())
+	})
+}
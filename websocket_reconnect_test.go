@@ -0,0 +1,143 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketReconnect(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("reconnect and replay config", func(t *testing.T) {
+		first, second := newFakeWebSocketConn(), newFakeWebSocketConn()
+		chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{
+			WebSocketClientOption: &WebSocketClientOption{
+				dial:      fakeWebSocketDialer(first, nil, second),
+				Reconnect: &WebSocketReconnectPolicy{InitialBackoff: time.Millisecond},
+			},
+		})
+		var mu sync.Mutex
+		var events []WebSocketEventType
+		var attempts int
+		record := func(event IWebSocketEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event.GetEventType())
+			return nil
+		}
+		chat.OnEvent(WebSocketEventTypeReconnecting, record)
+		chat.OnReconnected(func(ctx context.Context, cli *WebSocketChat, event *WebSocketReconnectedEvent) error {
+			attempts = event.Data.Attempts
+			return record(event)
+		})
+		chat.OnEvent(WebSocketEventTypeConversationMessageDelta, record)
+
+		as.Nil(chat.Connect())
+		as.Nil(chat.ChatUpdate(&WebSocketChatUpdateEventData{ChatConfig: &WebSocketChatConfig{UserID: ptr("user")}}))
+		as.Contains(string(<-first.written), `"event_type":"chat.update"`)
+
+		first.incoming <- errors.New("connection reset")
+		as.Nil(chat.ws.WaitForEvent([]WebSocketEventType{WebSocketEventTypeReconnecting}, false))
+		// sent during the gap, it is buffered until reconnected
+		as.Nil(chat.ConversationChatCancel(nil))
+
+		as.Contains(string(<-second.written), `"user_id":"user"`)
+		as.Contains(string(<-second.written), `"event_type":"conversation.chat.cancel"`)
+		second.incoming <- []byte(`{"event_type":"conversation.message.delta","data":{"content":"hi"}}`)
+		as.Nil(chat.ws.WaitForEvent([]WebSocketEventType{WebSocketEventTypeConversationMessageDelta}, false))
+		as.Nil(chat.Close())
+
+		mu.Lock()
+		defer mu.Unlock()
+		as.Equal([]WebSocketEventType{WebSocketEventTypeReconnecting, WebSocketEventTypeReconnected, WebSocketEventTypeConversationMessageDelta}, events)
+		as.Equal(2, attempts)
+	})
+
+	t.Run("give up after max attempts", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		speech := newWebSocketAudioSpeechClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketAudioSpeechReq{
+			WebSocketClientOption: &WebSocketClientOption{
+				dial:      fakeWebSocketDialer(conn),
+				Reconnect: &WebSocketReconnectPolicy{MaxAttempts: 2, InitialBackoff: 20 * time.Millisecond},
+			},
+		})
+		clientErr := make(chan error, 1)
+		speech.OnClientError(func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketClientErrorEvent) error {
+			clientErr <- event.Data
+			return nil
+		})
+		closed := make(chan struct{})
+		speech.OnClosed(func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketClosedEvent) error {
+			as.False(cli.IsConnected())
+			close(closed)
+			// Close of a lost client does nothing
+			return cli.Close()
+		})
+		as.Nil(speech.Connect())
+		conn.incoming <- errors.New("connection reset")
+		as.Nil(speech.ws.WaitForEvent([]WebSocketEventType{WebSocketEventTypeReconnecting}, false))
+
+		// sent during the gap, they fail once the reconnect gives up
+		sent := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				sent <- speech.InputTextBufferAppendContext(context.Background(), &WebSocketInputTextBufferAppendEventData{Delta: "hi"})
+			}()
+		}
+		as.Contains((<-clientErr).Error(), "connection reset")
+		<-closed
+		as.NotNil(<-sent)
+		as.NotNil(<-sent)
+
+		as.False(speech.IsConnected())
+		as.NotNil(speech.InputTextBufferAppend(&WebSocketInputTextBufferAppendEventData{Delta: "hi"}))
+		_, err := speech.WaitForEvent(context.Background(), &WebSocketWaitReq{EventTypes: []WebSocketEventType{WebSocketEventTypeSpeechAudioCompleted}})
+		as.Equal(ErrWebSocketClosed, err)
+		as.Nil(speech.Close())
+	})
+
+	t.Run("close while reconnecting", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		speech := newWebSocketAudioSpeechClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketAudioSpeechReq{
+			WebSocketClientOption: &WebSocketClientOption{
+				dial:      fakeWebSocketDialer(conn),
+				Reconnect: &WebSocketReconnectPolicy{InitialBackoff: time.Hour},
+			},
+		})
+		var lostEvents int32
+		speech.OnClientError(func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketClientErrorEvent) error {
+			atomic.AddInt32(&lostEvents, 1)
+			return nil
+		})
+		speech.OnClosed(func(ctx context.Context, cli *WebSocketAudioSpeech, event *WebSocketClosedEvent) error {
+			atomic.AddInt32(&lostEvents, 1)
+			return nil
+		})
+		as.Nil(speech.Connect())
+		conn.incoming <- errors.New("connection reset")
+		as.Nil(speech.ws.WaitForEvent([]WebSocketEventType{WebSocketEventTypeReconnecting}, false))
+
+		as.Nil(speech.Close())
+		select {
+		case <-speech.ws.receiveDone:
+		case <-time.After(time.Second):
+			t.Fatal("receive loop is not stopped")
+		}
+		as.False(speech.IsConnected())
+		as.Equal(int32(0), atomic.LoadInt32(&lostEvents))
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		policy := &WebSocketReconnectPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+		as.Equal(time.Second, policy.backoff(1))
+		as.Equal(4*time.Second, policy.backoff(3))
+		as.Equal(5*time.Second, policy.backoff(10))
+		as.Equal(5, policy.maxAttempts())
+	})
+}
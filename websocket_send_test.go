@@ -0,0 +1,140 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedWebSocketConn blocks every write until the gate is opened, and fails writes with writeErr
+type gatedWebSocketConn struct {
+	*fakeWebSocketConn
+	gate     chan struct{}
+	writeErr error
+}
+
+func (r *gatedWebSocketConn) WriteMessage(messageType int, data []byte) error {
+	<-r.gate
+	if r.writeErr != nil {
+		return r.writeErr
+	}
+	return r.fakeWebSocketConn.WriteMessage(messageType, data)
+}
+
+func TestWebSocketSend(t *testing.T) {
+	as := assert.New(t)
+	audio := &WebSocketInputAudioBufferAppendEventData{Delta: []byte("pcm")}
+
+	newTranscription := func(conn websocketConn, opt *WebSocketClientOption) *WebSocketAudioTranscription {
+		opt.dial = fakeWebSocketDialer(conn)
+		opt.SendChanCapacity = 1
+		client := newWebSocketAudioTranscriptionClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketAudioTranscriptionReq{WebSocketClientOption: opt})
+		as.Nil(client.Connect())
+		return client
+	}
+	// fill sends events until the writer is blocked and the queue is full
+	fill := func(client *WebSocketAudioTranscription) {
+		as.Nil(client.InputAudioBufferAppend(audio))
+		as.Eventually(func() bool { return client.Stats().SendQueueLen == 0 }, time.Second, time.Millisecond)
+		as.Nil(client.InputAudioBufferAppend(audio))
+	}
+
+	t.Run("queue full", func(t *testing.T) {
+		conn := &gatedWebSocketConn{fakeWebSocketConn: newFakeWebSocketConn(), gate: make(chan struct{})}
+		client := newTranscription(conn, &WebSocketClientOption{})
+		fill(client)
+		as.True(errors.Is(client.InputAudioBufferAppend(audio), ErrWebSocketSendQueueFull))
+
+		stats := client.Stats()
+		as.Equal(1, stats.SendQueueLen)
+		as.Equal(1, stats.SendQueueCap)
+		as.Equal(int64(1), stats.RejectedEvents)
+
+		close(conn.gate)
+		as.Eventually(func() bool { return client.Stats().SentEvents == 2 }, time.Second, time.Millisecond)
+		as.Nil(client.Close())
+	})
+
+	t.Run("send timeout", func(t *testing.T) {
+		conn := &gatedWebSocketConn{fakeWebSocketConn: newFakeWebSocketConn(), gate: make(chan struct{})}
+		client := newTranscription(conn, &WebSocketClientOption{SendTimeout: 20 * time.Millisecond})
+		fill(client)
+		start := time.Now()
+		as.True(errors.Is(client.InputAudioBufferAppend(audio), ErrWebSocketSendQueueFull))
+		as.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(conn.gate)
+		}()
+		client.ws.opt.SendTimeout = -1
+		as.Nil(client.InputAudioBufferAppend(audio))
+		as.Nil(client.Close())
+	})
+
+	t.Run("context send", func(t *testing.T) {
+		conn := &gatedWebSocketConn{fakeWebSocketConn: newFakeWebSocketConn(), gate: make(chan struct{})}
+		client := newTranscription(conn, &WebSocketClientOption{})
+		fill(client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		as.True(errors.Is(client.InputAudioBufferAppendContext(ctx, audio), context.DeadlineExceeded))
+
+		close(conn.gate)
+		as.Nil(client.InputAudioBufferAppendContext(context.Background(), audio))
+		as.Equal(int64(3), client.Stats().SentEvents)
+		as.Nil(client.Close())
+	})
+
+	t.Run("write error is returned", func(t *testing.T) {
+		gate := make(chan struct{})
+		close(gate)
+		conn := &gatedWebSocketConn{fakeWebSocketConn: newFakeWebSocketConn(), gate: gate, writeErr: errors.New("broken pipe")}
+		client := newTranscription(conn, &WebSocketClientOption{})
+		err := client.InputAudioBufferAppendContext(context.Background(), audio)
+		as.NotNil(err)
+		as.Contains(err.Error(), "broken pipe")
+		as.Equal(int64(1), client.Stats().FailedEvents)
+
+		client.ws.opt.WaitSent = true
+		as.NotNil(client.InputAudioBufferAppend(audio))
+		as.Nil(client.Close())
+	})
+
+	t.Run("slow handlers", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		client := newTranscription(conn, &WebSocketClientOption{ReceiveChanCapacity: 1})
+		var handled int32
+		client.OnTranscriptionsMessageUpdate(func(ctx context.Context, cli *WebSocketAudioTranscription, event *WebSocketTranscriptionsMessageUpdateEvent) error {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&handled, 1)
+			return nil
+		})
+		for i := 0; i < 20; i++ {
+			conn.incoming <- []byte(fmt.Sprintf(`{"event_type":"transcriptions.message.update","id":"%d"}`, i))
+		}
+		as.Eventually(func() bool { return atomic.LoadInt32(&handled) == 20 }, time.Second, time.Millisecond)
+		as.Equal(int64(20), client.Stats().ReceivedEvents)
+		as.Nil(client.Close())
+	})
+
+	t.Run("close unblocks a full receive queue", func(t *testing.T) {
+		conn := newFakeWebSocketConn()
+		client := newTranscription(conn, &WebSocketClientOption{ReceiveChanCapacity: 1})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-client.ws.receiveDone
+		}()
+		client.ws.cancel()
+		wg.Wait()
+	})
+}
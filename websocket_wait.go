@@ -4,18 +4,94 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
-	"sync/atomic"
+	"time"
 )
 
-const maxEventSize = websocketEventTypeSize
+// the number of recent events kept for the correlated waits started after the events arrived
+const eventWaiterHistorySize = 256
+
+// errEventWaiterShutdown is returned by the waits released by shutdown
+var errEventWaiterShutdown = errors.New("event waiter shutdown")
+
+// WebSocketWaitMark marks a point in the received events, see WebSocketWaitReq.Since
+type WebSocketWaitMark uint64
+
+// WebSocketWaitReq represents the events to wait for
+type WebSocketWaitReq struct {
+	// The event types to wait for.
+	EventTypes []WebSocketEventType
+	// Wait for all the event types, by default the wait returns on any of them.
+	WaitAll bool
+	// Only the events received after the mark count, like the events of the turn started after
+	// WaitMark is called. By default, all the events since connected or the last ResetWait count.
+	Since WebSocketWaitMark
+	// Only the events of the chat count, the chat id is taken from data.chat_id, or data.id of the
+	// conversation.chat.* events.
+	ChatID string
+	// Only the event with the id counts.
+	EventID string
+	// The max time to wait, default is no limit besides ctx.
+	Timeout time.Duration
+}
+
+type eventWaitRecord struct {
+	seq       uint64
+	eventType WebSocketEventType
+	event     IWebSocketEvent // nil if triggered by type only
+}
+
+type eventWaitCond struct {
+	eventTypes []WebSocketEventType
+	waitAll    bool
+	since      uint64
+	chatID     string
+	eventID    string
+}
 
+func (r *eventWaitCond) correlated() bool {
+	return r.chatID != "" || r.eventID != ""
+}
+
+func (r *eventWaitCond) match(record *eventWaitRecord) bool {
+	if record.seq <= r.since {
+		return false
+	}
+	if !r.correlated() {
+		return true
+	}
+	if record.event == nil {
+		return false
+	}
+	if r.eventID != "" && record.event.GetID() != r.eventID {
+		return false
+	}
+	if r.chatID != "" && websocketEventChatID(record.event) != r.chatID {
+		return false
+	}
+	return true
+}
+
+type eventWait struct {
+	cond    *eventWaitCond
+	pending map[WebSocketEventType]bool
+	matched IWebSocketEvent
+	done    chan struct{}
+}
+
+// eventWaiter records the received events by sequence, waits check the recorded events and are
+// notified of the events received later, so every occurrence of an event type can be waited for.
 type eventWaiter struct {
-	eventMask      uint64              // 位图
-	eventTriggered [maxEventSize]int32 // 1: 已触发, 0: 未触发
-	eventChannels  [maxEventSize]chan struct{}
-	eventOnce      [maxEventSize]sync.Once
-	enableEvents   []WebSocketEventType
+	mu           sync.Mutex
+	seq          uint64
+	base         uint64 // the seq of the last reset
+	last         map[WebSocketEventType]*eventWaitRecord
+	history      []*eventWaitRecord // ring of the recent records
+	next         int
+	waits        map[*eventWait]struct{}
+	shutdownChan chan struct{}
+	isShutdown   bool
 }
 
 func getWebSocketEventTypeIndex(WebSocketEventType WebSocketEventType) (int, bool) {
@@ -23,225 +99,199 @@ func getWebSocketEventTypeIndex(WebSocketEventType WebSocketEventType) (int, boo
 	return i, ok
 }
 
-func newEventWaiter(enableEvents []WebSocketEventType) *eventWaiter {
-	oew := &eventWaiter{
-		enableEvents: enableEvents,
-	}
-	for _, v := range enableEvents {
-		i, ok := getWebSocketEventTypeIndex(v)
-		if !ok {
-			continue
-		}
-		oew.eventChannels[i] = make(chan struct{})
+func newEventWaiter() *eventWaiter {
+	return &eventWaiter{
+		last:         map[WebSocketEventType]*eventWaitRecord{},
+		history:      make([]*eventWaitRecord, 0, eventWaiterHistorySize),
+		waits:        map[*eventWait]struct{}{},
+		shutdownChan: make(chan struct{}),
 	}
-	return oew
 }
 
+// wait waits for the events since connected or the last reset, it returns nil after shutdown
 func (oew *eventWaiter) wait(ctx context.Context, eventTypes []WebSocketEventType, waitAll bool) error {
 	if len(eventTypes) <= 0 {
 		return nil
-	} else if len(eventTypes) == 1 {
-		return oew.waitOne(ctx, eventTypes[0])
 	}
-	if waitAll {
-		return oew.waitAll(ctx, eventTypes)
+	_, err := oew.waitFor(ctx, &eventWaitCond{eventTypes: eventTypes, waitAll: waitAll})
+	if errors.Is(err, errEventWaiterShutdown) {
+		return nil
 	}
-	_, err := oew.waitAny(ctx, eventTypes)
 	return err
 }
 
-// waitOne 等待单个事件
-func (oew *eventWaiter) waitOne(ctx context.Context, eventType WebSocketEventType) error {
-	i, ok := getWebSocketEventTypeIndex(eventType)
-	if !ok {
-		return fmt.Errorf("wait event_type: %s not found", eventType)
+// waitFor returns the matched event, or the last matched one of waitAll. The event is nil if the
+// wait is satisfied by trigger without an event.
+func (oew *eventWaiter) waitFor(ctx context.Context, cond *eventWaitCond) (IWebSocketEvent, error) {
+	if len(cond.eventTypes) == 0 {
+		return nil, errors.New("no event types specified")
 	}
-	// 检查事件是否已经触发
-	if atomic.LoadInt32(&oew.eventTriggered[i]) == 1 {
-		return nil
-	}
-
-	select {
-	case <-oew.eventChannels[i]:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+	for _, eventType := range cond.eventTypes {
+		if _, ok := getWebSocketEventTypeIndex(eventType); !ok {
+			return nil, fmt.Errorf("wait event_type: %s not found", eventType)
+		}
 	}
-}
 
-// waitAny 等待任意一个事件触发
-func (oew *eventWaiter) waitAny(ctx context.Context, eventTypes []WebSocketEventType) (WebSocketEventType, error) {
-	if len(eventTypes) == 0 {
-		return "", errors.New("no event types specified")
+	oew.mu.Lock()
+	if cond.since < oew.base {
+		cond.since = oew.base
 	}
-
-	// 创建事件类型掩码
-	var eventMask uint64
-	for _, eventType := range eventTypes {
-		i, ok := getWebSocketEventTypeIndex(eventType)
-		if !ok {
-			return "", fmt.Errorf("wait event_type: %s not found", eventType)
+	w := &eventWait{cond: cond, pending: map[WebSocketEventType]bool{}, done: make(chan struct{})}
+	var latest *eventWaitRecord
+	for _, eventType := range cond.eventTypes {
+		record := oew.find(eventType, cond)
+		if record == nil {
+			w.pending[eventType] = true
+			continue
+		}
+		if !cond.waitAll {
+			oew.mu.Unlock()
+			return record.event, nil
 		}
-		eventMask |= 1 << i
-	}
-
-	// 检查是否已有事件触发
-	currentMask := atomic.LoadUint64(&oew.eventMask)
-	if triggeredMask := currentMask & eventMask; triggeredMask != 0 {
-		// 找到第一个触发的事件
-		for _, eventType := range eventTypes {
-			i, ok := getWebSocketEventTypeIndex(eventType)
-			if !ok {
-				return "", fmt.Errorf("wait event_type: %s not found", eventType)
-			}
-			if triggeredMask&(1<<i) != 0 {
-				return eventType, nil
-			}
+		if latest == nil || record.seq > latest.seq {
+			latest = record
 		}
 	}
-
-	// 创建一个聚合 channel
-	done := make(chan WebSocketEventType, 1)
-	var wg sync.WaitGroup
-
-	for _, et := range eventTypes {
-		wg.Add(1)
-		go func(eventType WebSocketEventType) {
-			defer wg.Done()
-
-			// 再次检查是否已触发（避免竞态条件）
-			i, _ := getWebSocketEventTypeIndex(eventType) // 不需要检查合法性
-			if atomic.LoadInt32(&oew.eventTriggered[i]) == 1 {
-				select {
-				case done <- eventType:
-				default:
-				}
-				return
-			}
-
-			select {
-			case <-oew.eventChannels[i]:
-				select {
-				case done <- eventType:
-				default:
-				}
-			case <-ctx.Done():
-			}
-		}(et)
-	}
-
-	// 等待第一个事件或超时
-	select {
-	case eventType := <-done:
-		return eventType, nil
-	case <-ctx.Done():
-		return "", ctx.Err()
+	if len(w.pending) == 0 {
+		oew.mu.Unlock()
+		return latest.event, nil
 	}
-}
-
-// waitAll 等待所有指定事件都触发
-func (oew *eventWaiter) waitAll(ctx context.Context, WebSocketEventTypes []WebSocketEventType) error {
-	if len(WebSocketEventTypes) == 0 {
-		return nil
+	if oew.isShutdown {
+		oew.mu.Unlock()
+		return nil, errEventWaiterShutdown
 	}
+	oew.waits[w] = struct{}{}
+	oew.mu.Unlock()
 
-	// 创建事件类型掩码
-	var targetMask uint64
-	for _, eventType := range WebSocketEventTypes {
-		i, ok := getWebSocketEventTypeIndex(eventType)
-		if !ok {
-			return fmt.Errorf("wait event_type: %s not found", eventType)
+	select {
+	case <-w.done:
+		return w.matched, nil
+	case <-oew.shutdownChan:
+		// the wait may be satisfied by the events triggered before shutdown
+		select {
+		case <-w.done:
+			return w.matched, nil
+		default:
+			return nil, errEventWaiterShutdown
 		}
-		targetMask |= 1 << i
-	}
-
-	// 检查是否所有事件都已触发
-	checkAllTriggered := func() bool {
-		currentMask := atomic.LoadUint64(&oew.eventMask)
-		return (currentMask & targetMask) == targetMask
+	case <-ctx.Done():
+		oew.mu.Lock()
+		delete(oew.waits, w)
+		oew.mu.Unlock()
+		return nil, ctx.Err()
 	}
+}
 
-	if checkAllTriggered() {
+// find returns the latest record of eventType matching cond, the correlated conds only see the
+// recent events kept in history
+func (oew *eventWaiter) find(eventType WebSocketEventType, cond *eventWaitCond) *eventWaitRecord {
+	last := oew.last[eventType]
+	if last == nil || last.seq <= cond.since {
 		return nil
 	}
-
-	// 等待所有事件
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-
-	for _, et := range WebSocketEventTypes {
-		wg.Add(1)
-		go func(eventType WebSocketEventType) {
-			defer wg.Done()
-
-			i, _ := getWebSocketEventTypeIndex(eventType) // 不需要检查合法性
-			if atomic.LoadInt32(&oew.eventTriggered[i]) == 0 {
-				select {
-				case <-oew.eventChannels[i]:
-				case <-ctx.Done():
-					return
-				}
-			}
-		}(et)
+	if cond.match(last) {
+		return last
 	}
-
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		if checkAllTriggered() {
+	for i := 1; i <= len(oew.history); i++ {
+		record := oew.history[(oew.next-i+len(oew.history))%len(oew.history)]
+		if record.seq <= cond.since {
 			return nil
 		}
-		return ctx.Err()
-	case <-ctx.Done():
-		return ctx.Err()
+		if record.eventType == eventType && cond.match(record) {
+			return record
+		}
 	}
+	return nil
 }
 
-// TriggerEvent 触发事件
+// mark returns the mark of the events received so far
+func (oew *eventWaiter) mark() WebSocketWaitMark {
+	oew.mu.Lock()
+	defer oew.mu.Unlock()
+	return WebSocketWaitMark(oew.seq)
+}
+
+// reset makes the waits without Since ignore the events received so far
+func (oew *eventWaiter) reset() {
+	oew.mu.Lock()
+	defer oew.mu.Unlock()
+	oew.base = oew.seq
+}
+
+// trigger records an occurrence of eventType without the event
 func (oew *eventWaiter) trigger(eventType WebSocketEventType) error {
-	i, ok := getWebSocketEventTypeIndex(eventType)
-	if !ok {
+	return oew.triggerEvent(eventType, nil)
+}
+
+// triggerEvent records the event and notifies the waits it satisfies
+func (oew *eventWaiter) triggerEvent(eventType WebSocketEventType, event IWebSocketEvent) error {
+	if _, ok := getWebSocketEventTypeIndex(eventType); !ok {
 		return fmt.Errorf("wait event_type: %s not found", eventType)
 	}
 
-	// 使用 sync.Once 确保只触发一次
-	oew.eventOnce[i].Do(func() {
-		atomic.StoreInt32(&oew.eventTriggered[i], 1)
+	oew.mu.Lock()
+	defer oew.mu.Unlock()
+	oew.seq++
+	record := &eventWaitRecord{seq: oew.seq, eventType: eventType, event: event}
+	oew.last[eventType] = record
+	if len(oew.history) < eventWaiterHistorySize {
+		oew.history = append(oew.history, record)
+	} else {
+		oew.history[oew.next] = record
+	}
+	oew.next = (oew.next + 1) % eventWaiterHistorySize
 
-		// 原子性地设置位掩码
-		for {
-			old := atomic.LoadUint64(&oew.eventMask)
-			new := old | (1 << i)
-			if atomic.CompareAndSwapUint64(&oew.eventMask, old, new) {
-				break
-			}
+	for w := range oew.waits {
+		if !w.pending[eventType] || !w.cond.match(record) {
+			continue
 		}
-
-		safeCloseChan(oew.eventChannels[i]) // 关闭 channel 通知所有等待者
-	})
-
+		delete(w.pending, eventType)
+		if w.cond.waitAll && len(w.pending) > 0 {
+			continue
+		}
+		w.matched = event
+		close(w.done)
+		delete(oew.waits, w)
+	}
 	return nil
 }
 
+// shutdown releases all the waits, including the ones started later
 func (oew *eventWaiter) shutdown() {
-	// 关闭所有 channel
-	for _, eventType := range oew.enableEvents {
-		i, ok := getWebSocketEventTypeIndex(eventType)
-		if !ok {
-			continue
-		}
-
-		safeCloseChan(oew.eventChannels[i])
+	oew.mu.Lock()
+	defer oew.mu.Unlock()
+	if oew.isShutdown {
+		return
 	}
+	oew.isShutdown = true
+	close(oew.shutdownChan)
 }
 
-func safeCloseChan[T any](ch chan T) {
-	defer func() {
-		_ = recover()
-	}()
-	close(ch)
+// websocketEventChatID returns data.chat_id of the event, or data.id if data is a Chat
+func websocketEventChatID(event IWebSocketEvent) string {
+	v := reflect.ValueOf(event)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	data := v.FieldByName("Data")
+	if !data.IsValid() || data.Kind() != reflect.Ptr || data.IsNil() {
+		return ""
+	}
+	if chat, ok := data.Interface().(*Chat); ok {
+		return chat.ID
+	}
+	data = data.Elem()
+	if data.Kind() != reflect.Struct {
+		return ""
+	}
+	if chatID := data.FieldByName("ChatID"); chatID.IsValid() && chatID.Kind() == reflect.String {
+		return chatID.String()
+	}
+	return ""
 }
@@ -12,7 +12,7 @@ func TestEventWaiter_WaitOne(t *testing.T) {
 	as := assert.New(t)
 
 	t.Run("wait for one event", func(t *testing.T) {
-		waiter := newEventWaiter(audioSpeechResponseEventTypes)
+		waiter := newEventWaiter()
 
 		go func() {
 			time.Sleep(100 * time.Millisecond)
@@ -27,7 +27,7 @@ func TestEventWaiter_WaitOne(t *testing.T) {
 	})
 
 	t.Run("wait for one event with timeout", func(t *testing.T) {
-		waiter := newEventWaiter(audioSpeechResponseEventTypes)
+		waiter := newEventWaiter()
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 		defer cancel()
 
@@ -39,7 +39,7 @@ func TestEventWaiter_WaitOne(t *testing.T) {
 func TestEventWaiter_WaitAll(t *testing.T) {
 	as := assert.New(t)
 	t.Run("wait for all events", func(t *testing.T) {
-		waiter := newEventWaiter(audioSpeechResponseEventTypes)
+		waiter := newEventWaiter()
 		go func() {
 			time.Sleep(50 * time.Millisecond)
 			as.Nil(waiter.trigger(WebSocketEventTypeError))
@@ -55,7 +55,7 @@ func TestEventWaiter_WaitAll(t *testing.T) {
 	})
 
 	t.Run("wait for all events with timeout", func(t *testing.T) {
-		waiter := newEventWaiter(audioSpeechResponseEventTypes)
+		waiter := newEventWaiter()
 		go func() {
 			time.Sleep(50 * time.Millisecond)
 			as.Nil(waiter.trigger(WebSocketEventTypeError))
@@ -72,7 +72,7 @@ func TestEventWaiter_WaitAll(t *testing.T) {
 func TestEventWaiter_WaitAny(t *testing.T) {
 	as := assert.New(t)
 	t.Run("wait for any event", func(t *testing.T) {
-		waiter := newEventWaiter(audioSpeechResponseEventTypes)
+		waiter := newEventWaiter()
 		go func() {
 			time.Sleep(100 * time.Millisecond)
 			as.Nil(waiter.trigger(WebSocketEventTypeError))
@@ -86,7 +86,7 @@ func TestEventWaiter_WaitAny(t *testing.T) {
 	})
 
 	t.Run("wait for any event with timeout", func(t *testing.T) {
-		waiter := newEventWaiter(audioSpeechResponseEventTypes)
+		waiter := newEventWaiter()
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 		defer cancel()
 
@@ -97,7 +97,7 @@ func TestEventWaiter_WaitAny(t *testing.T) {
 
 func TestEventWaiter_Shutdown(t *testing.T) {
 	as := assert.New(t)
-	waiter := newEventWaiter(audioSpeechResponseEventTypes)
+	waiter := newEventWaiter()
 
 	go func() {
 		time.Sleep(100 * time.Millisecond)
@@ -110,3 +110,132 @@ func TestEventWaiter_Shutdown(t *testing.T) {
 	err := waiter.wait(ctx, []WebSocketEventType{WebSocketEventTypeError, WebSocketEventTypeClientError}, true)
 	as.Nil(err)
 }
+
+func TestEventWaiter_Repeated(t *testing.T) {
+	as := assert.New(t)
+	chatCompleted := func(chatID string) IWebSocketEvent {
+		return &WebSocketConversationChatCompletedEvent{
+			baseWebSocketEvent: baseWebSocketEvent{EventType: WebSocketEventTypeConversationChatCompleted, ID: "event-" + chatID},
+			Data:               &Chat{ID: chatID},
+		}
+	}
+	completed := []WebSocketEventType{WebSocketEventTypeConversationChatCompleted}
+
+	t.Run("wait since mark", func(t *testing.T) {
+		waiter := newEventWaiter()
+		as.Nil(waiter.triggerEvent(WebSocketEventTypeConversationChatCompleted, chatCompleted("1")))
+		mark := waiter.mark()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := waiter.waitFor(ctx, &eventWaitCond{eventTypes: completed, since: uint64(mark)})
+		as.Equal(context.DeadlineExceeded, err)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			as.Nil(waiter.triggerEvent(WebSocketEventTypeConversationChatCompleted, chatCompleted("2")))
+		}()
+		event, err := waiter.waitFor(context.Background(), &eventWaitCond{eventTypes: completed, since: uint64(mark)})
+		as.Nil(err)
+		as.Equal("2", event.(*WebSocketConversationChatCompletedEvent).Data.ID)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		waiter := newEventWaiter()
+		as.Nil(waiter.trigger(WebSocketEventTypeConversationChatCompleted))
+		as.Nil(waiter.wait(context.Background(), completed, false))
+
+		waiter.reset()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		as.Equal(context.DeadlineExceeded, waiter.wait(ctx, completed, false))
+
+		as.Nil(waiter.trigger(WebSocketEventTypeConversationChatCompleted))
+		as.Nil(waiter.wait(context.Background(), completed, false))
+	})
+
+	t.Run("correlate by chat id and event id", func(t *testing.T) {
+		waiter := newEventWaiter()
+		as.Nil(waiter.triggerEvent(WebSocketEventTypeConversationChatCompleted, chatCompleted("a")))
+		as.Nil(waiter.triggerEvent(WebSocketEventTypeConversationChatCompleted, chatCompleted("b")))
+
+		event, err := waiter.waitFor(context.Background(), &eventWaitCond{eventTypes: completed, chatID: "a"})
+		as.Nil(err)
+		as.Equal("a", event.(*WebSocketConversationChatCompletedEvent).Data.ID)
+
+		event, err = waiter.waitFor(context.Background(), &eventWaitCond{eventTypes: completed, eventID: "event-b"})
+		as.Nil(err)
+		as.Equal("b", event.(*WebSocketConversationChatCompletedEvent).Data.ID)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			as.Nil(waiter.triggerEvent(WebSocketEventTypeConversationMessageDelta, &WebSocketConversationMessageDeltaEvent{
+				baseWebSocketEvent: baseWebSocketEvent{EventType: WebSocketEventTypeConversationMessageDelta},
+				Data:               &Message{ChatID: "c"},
+			}))
+			as.Nil(waiter.triggerEvent(WebSocketEventTypeConversationChatCompleted, chatCompleted("c")))
+		}()
+		event, err = waiter.waitFor(context.Background(), &eventWaitCond{
+			eventTypes: []WebSocketEventType{WebSocketEventTypeConversationMessageDelta, WebSocketEventTypeConversationChatCompleted},
+			waitAll:    true,
+			chatID:     "c",
+		})
+		as.Nil(err)
+		as.Equal(WebSocketEventTypeConversationChatCompleted, event.GetEventType())
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		waiter := newEventWaiter()
+		waiter.shutdown()
+		_, err := waiter.waitFor(context.Background(), &eventWaitCond{eventTypes: completed})
+		as.Equal(errEventWaiterShutdown, err)
+	})
+}
+
+func TestWebSocketWaitForEvent(t *testing.T) {
+	as := assert.New(t)
+	conn := newFakeWebSocketConn()
+	chat := newWebsocketChatClient(context.Background(), newFakeWebSocketCore(), &CreateWebsocketChatReq{
+		WebSocketClientOption: &WebSocketClientOption{dial: fakeWebSocketDialer(conn)},
+	})
+	as.Nil(chat.Connect())
+
+	conn.incoming <- []byte(`{"event_type":"conversation.chat.completed","data":{"id":"1"}}`)
+	as.Nil(chat.Wait())
+
+	// the second turn
+	mark := chat.WaitMark()
+	_, err := chat.WaitForEvent(context.Background(), &WebSocketWaitReq{
+		EventTypes: []WebSocketEventType{WebSocketEventTypeConversationChatCompleted},
+		Since:      mark,
+		Timeout:    20 * time.Millisecond,
+	})
+	as.Equal(context.DeadlineExceeded, err)
+
+	conn.incoming <- []byte(`{"event_type":"conversation.chat.completed","data":{"id":"2"}}`)
+	event, err := chat.WaitForEvent(context.Background(), &WebSocketWaitReq{
+		EventTypes: []WebSocketEventType{WebSocketEventTypeConversationChatCompleted},
+		Since:      mark,
+	})
+	as.Nil(err)
+	as.Equal("2", event.(*WebSocketConversationChatCompletedEvent).Data.ID)
+
+	chat.ResetWait()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		as.Nil(chat.Close())
+	}()
+	_, err = chat.WaitForEvent(context.Background(), &WebSocketWaitReq{
+		EventTypes: []WebSocketEventType{WebSocketEventTypeConversationChatCompleted},
+	})
+	as.Equal(ErrWebSocketClosed, err)
+}
+
+func TestWebSocketEventChatID(t *testing.T) {
+	as := assert.New(t)
+	as.Equal("1", websocketEventChatID(&WebSocketConversationChatCreatedEvent{Data: &Chat{ID: "1"}}))
+	as.Equal("2", websocketEventChatID(&WebSocketConversationMessageDeltaEvent{Data: &Message{ChatID: "2"}}))
+	as.Equal("3", websocketEventChatID(&WebSocketConversationAudioDeltaEvent{Data: &WebSocketConversationAudioDeltaEventData{ChatID: "3"}}))
+	as.Equal("", websocketEventChatID(&WebSocketConversationMessageDeltaEvent{}))
+	as.Equal("", websocketEventChatID(&WebSocketClientErrorEvent{}))
+}
@@ -13,6 +13,9 @@ import (
 //
 // docs: https://www.coze.cn/open/docs/developer_guides/workflow_run
 func (r *workflowRuns) Create(ctx context.Context, req *RunWorkflowsReq) (*RunWorkflowsResp, error) {
+	if err := r.client.checkUsage(ctx, req.usageScope()); err != nil {
+		return nil, err
+	}
 	request := &RawRequestReq{
 		Method: http.MethodPost,
 		URL:    "/v1/workflow/run",
@@ -20,6 +23,9 @@ func (r *workflowRuns) Create(ctx context.Context, req *RunWorkflowsReq) (*RunWo
 	}
 	response := new(runWorkflowsResp)
 	err := r.client.rawRequest(ctx, request, response)
+	if err == nil {
+		r.client.recordWorkflowUsage(ctx, req, response.RunWorkflowsResp)
+	}
 	return response.RunWorkflowsResp, err
 }
 
@@ -27,6 +33,10 @@ func (r *workflowRuns) Create(ctx context.Context, req *RunWorkflowsReq) (*RunWo
 //
 // docs: https://www.coze.cn/open/docs/developer_guides/workflow_resume
 func (r *workflowRuns) Resume(ctx context.Context, req *ResumeRunWorkflowsReq) (Stream[WorkflowEvent], error) {
+	scope := UsageScope{WorkflowID: req.WorkflowID}
+	if err := r.client.checkUsage(ctx, scope); err != nil {
+		return nil, err
+	}
 	request := &RawRequestReq{
 		Method: http.MethodPost,
 		URL:    "/v1/workflow/stream_resume",
@@ -34,13 +44,16 @@ func (r *workflowRuns) Resume(ctx context.Context, req *ResumeRunWorkflowsReq) (
 	}
 	response := new(runWorkflowsResp)
 	err := r.client.rawRequest(ctx, request, response)
-	return newStream(ctx, r.client, response.HTTPResponse, parseWorkflowEvent), err
+	return newStream(ctx, r.client, response.HTTPResponse, r.client.workflowUsageProcessor(scope)), err
 }
 
 // Stream 流式执行工作流
 //
 // docs: https://www.coze.cn/open/docs/developer_guides/workflow_stream_run
 func (r *workflowRuns) Stream(ctx context.Context, req *RunWorkflowsReq) (Stream[WorkflowEvent], error) {
+	if err := r.client.checkUsage(ctx, req.usageScope()); err != nil {
+		return nil, err
+	}
 	request := &RawRequestReq{
 		Method: http.MethodPost,
 		URL:    "/v1/workflow/stream_run",
@@ -48,7 +61,7 @@ func (r *workflowRuns) Stream(ctx context.Context, req *RunWorkflowsReq) (Stream
 	}
 	response := new(runWorkflowsResp)
 	err := r.client.rawRequest(ctx, request, response)
-	return newStream(ctx, r.client, response.HTTPResponse, parseWorkflowEvent), err
+	return newStream(ctx, r.client, response.HTTPResponse, r.client.workflowUsageProcessor(req.usageScope())), err
 }
 
 // WorkflowRunResult represents the result of a workflow runs
@@ -150,6 +163,9 @@ type WorkflowEventMessage struct {
 
 	// Additional fields.
 	Ext map[string]any `json:"ext,omitempty"`
+
+	// Token consumption of the workflow run, only returned with the last message.
+	Usage *ChatUsage `json:"usage,omitempty"`
 }
 
 // WorkflowEventType represents the type of workflow event
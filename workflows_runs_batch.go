@@ -0,0 +1,518 @@
+package coze
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batch runs the workflow requests produced by inputs through Create with bounded concurrency, rate
+// limiting and per-item retry. Results are yielded through the returned iterator, in input order
+// when opt.Ordered is set and in completion order otherwise.
+//
+// Items already recorded in opt.Checkpoint are not executed again, they are yielded with Skipped set.
+func (r *workflowRuns) Batch(ctx context.Context, inputs WorkflowBatchInputs, opt *WorkflowBatchOption) WorkflowBatchResults {
+	// the defaults are filled into a copy, opt may be shared by several batches
+	batchOpt := WorkflowBatchOption{}
+	if opt != nil {
+		batchOpt = *opt
+	}
+	opt = &batchOpt
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+	if opt.RetryInterval <= 0 {
+		opt.RetryInterval = time.Second
+	}
+	if opt.Key == nil {
+		opt.Key = func(index int, req *RunWorkflowsReq) string {
+			return strconv.Itoa(index)
+		}
+	}
+	if opt.ShouldRetry == nil {
+		opt.ShouldRetry = defaultWorkflowBatchShouldRetry
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	b := &workflowBatch{
+		runs:    r,
+		ctx:     ctx,
+		cancel:  cancel,
+		opt:     opt,
+		inputs:  inputs,
+		limiter: newRateLimiter(opt.RateLimit),
+		jobs:    make(chan *WorkflowBatchResult, opt.Concurrency),
+		results: make(chan *WorkflowBatchResult, opt.Concurrency),
+		out:     make(chan *WorkflowBatchResult, opt.Concurrency),
+	}
+	if opt.Ordered {
+		b.window = make(chan struct{}, workflowBatchOrderedWindow*opt.Concurrency)
+	}
+	b.start()
+	return b
+}
+
+// WorkflowBatchInputs is the iterator of workflow requests consumed by Batch
+type WorkflowBatchInputs interface {
+	Next() bool
+	Current() *RunWorkflowsReq
+	Err() error
+}
+
+// NewWorkflowBatchInputs builds a WorkflowBatchInputs over a slice of requests
+func NewWorkflowBatchInputs(reqs []*RunWorkflowsReq) WorkflowBatchInputs {
+	return &sliceWorkflowBatchInputs{reqs: reqs, index: -1}
+}
+
+// WorkflowBatchOption configures a batch run
+type WorkflowBatchOption struct {
+	// The max number of workflow runs in flight, default 4.
+	Concurrency int
+	// The max number of workflow runs started per second, 0 means unlimited.
+	RateLimit float64
+	// The max number of retries for each item, 0 means no retry.
+	MaxRetries int
+	// The interval before the first retry, doubled on every retry, default 1s.
+	RetryInterval time.Duration
+	// Whether results are yielded in input order instead of completion order. Items are started at
+	// most 2*Concurrency ahead of the next result to yield, so a slow item holds up the following
+	// ones instead of buffering all of them.
+	Ordered bool
+	// Records completed items so an interrupted batch can be resumed, optional.
+	Checkpoint WorkflowBatchCheckpoint
+	// Returns the checkpoint key of an item, defaults to the input index.
+	Key func(index int, req *RunWorkflowsReq) string
+	// Reports whether a failed item should be retried, defaults to retrying the transport errors,
+	// the 5xx responses and the rate limited ones.
+	ShouldRetry func(err error) bool
+}
+
+// WorkflowBatchResult is the result of a single item of a batch run
+type WorkflowBatchResult struct {
+	// The position of the item in the inputs.
+	Index int
+	// The checkpoint key of the item.
+	Key string
+	// The workflow request of the item.
+	Req *RunWorkflowsReq
+	// The workflow response, which carries Data, Token, Cost and DebugURL.
+	Resp *RunWorkflowsResp
+	// The number of attempts made, 0 if the item was skipped.
+	Attempts int
+	// Whether the item was completed by a previous run according to the checkpoint.
+	Skipped bool
+	// The error of the last attempt.
+	Err error
+}
+
+// WorkflowBatchResults iterates the results of a batch run
+type WorkflowBatchResults interface {
+	// Next blocks until the next result is available, it returns false when all results have been
+	// yielded or the batch was closed.
+	Next() bool
+	Current() *WorkflowBatchResult
+	// Err returns the error of the inputs iterator or of the context.
+	Err() error
+	// Close stops the batch, items in flight are cancelled.
+	Close()
+}
+
+// WorkflowBatchCheckpoint stores the completed items of a batch run
+type WorkflowBatchCheckpoint interface {
+	Load(key string) (*RunWorkflowsResp, bool)
+	Save(key string, resp *RunWorkflowsResp) error
+}
+
+// NewMemoryWorkflowBatchCheckpoint creates a checkpoint kept in memory
+func NewMemoryWorkflowBatchCheckpoint() WorkflowBatchCheckpoint {
+	return &memoryWorkflowBatchCheckpoint{completed: map[string]*RunWorkflowsResp{}}
+}
+
+// NewFileWorkflowBatchCheckpoint creates a checkpoint persisted in a JSON lines file, the items
+// already recorded in the file are loaded and further completed items are appended.
+func NewFileWorkflowBatchCheckpoint(path string) (WorkflowBatchCheckpoint, error) {
+	checkpoint := &fileWorkflowBatchCheckpoint{
+		memoryWorkflowBatchCheckpoint: memoryWorkflowBatchCheckpoint{completed: map[string]*RunWorkflowsResp{}},
+	}
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			record := new(workflowBatchCheckpointRecord)
+			if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			checkpoint.completed[record.Key] = record.Resp
+		}
+		_ = f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	checkpoint.path = path
+	return checkpoint, nil
+}
+
+type workflowBatch struct {
+	runs    *workflowRuns
+	ctx     context.Context
+	cancel  context.CancelFunc
+	opt     *WorkflowBatchOption
+	inputs  WorkflowBatchInputs
+	limiter *rateLimiter
+
+	jobs    chan *WorkflowBatchResult
+	results chan *WorkflowBatchResult
+	out     chan *WorkflowBatchResult
+	// the slots of the items between the next result to yield and the last started one, only
+	// used by Ordered
+	window chan struct{}
+
+	cur  *WorkflowBatchResult
+	done bool
+	mu   sync.Mutex
+	err  error
+}
+
+func (b *workflowBatch) start() {
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		defer close(b.jobs)
+		b.produce()
+	}()
+	for i := 0; i < b.opt.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for item := range b.jobs {
+				b.run(item)
+				if !b.emit(b.results, item) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(b.results)
+	}()
+	go func() {
+		defer close(b.out)
+		b.collect()
+	}()
+}
+
+func (b *workflowBatch) produce() {
+	for index := 0; b.inputs.Next(); index++ {
+		if b.window != nil {
+			select {
+			case b.window <- struct{}{}:
+			case <-b.ctx.Done():
+				return
+			}
+		}
+		req := b.inputs.Current()
+		item := &WorkflowBatchResult{
+			Index: index,
+			Key:   b.opt.Key(index, req),
+			Req:   req,
+		}
+		ch := b.jobs
+		if b.opt.Checkpoint != nil {
+			if resp, ok := b.opt.Checkpoint.Load(item.Key); ok {
+				item.Resp = resp
+				item.Skipped = true
+				ch = b.results
+			}
+		}
+		if !b.emit(ch, item) {
+			return
+		}
+	}
+	if err := b.inputs.Err(); err != nil {
+		b.setErr(err)
+	}
+}
+
+func (b *workflowBatch) run(item *WorkflowBatchResult) {
+	interval := b.opt.RetryInterval
+	for {
+		if err := b.limiter.wait(b.ctx); err != nil {
+			item.Err = err
+			return
+		}
+		item.Attempts++
+		item.Resp, item.Err = b.runs.Create(b.ctx, item.Req)
+		if item.Err == nil {
+			if b.opt.Checkpoint != nil {
+				if err := b.opt.Checkpoint.Save(item.Key, item.Resp); err != nil {
+					b.runs.client.Log(b.ctx, LogLevelWarn, "[coze] workflow batch save checkpoint failed, key=%s, err=%s", item.Key, err)
+				}
+			}
+			return
+		}
+		if item.Attempts > b.opt.MaxRetries || !b.opt.ShouldRetry(item.Err) {
+			return
+		}
+		b.runs.client.Log(b.ctx, LogLevelWarn, "[coze] workflow batch item failed, key=%s, attempt=%d, err=%s", item.Key, item.Attempts, item.Err)
+		select {
+		case <-time.After(interval):
+		case <-b.ctx.Done():
+			return
+		}
+		interval *= 2
+	}
+}
+
+func (b *workflowBatch) collect() {
+	if !b.opt.Ordered {
+		for item := range b.results {
+			if !b.emit(b.out, item) {
+				return
+			}
+		}
+		return
+	}
+	next := 0
+	pending := map[int]*WorkflowBatchResult{}
+	for item := range b.results {
+		pending[item.Index] = item
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if !b.emit(b.out, ready) {
+				return
+			}
+			<-b.window
+		}
+	}
+}
+
+func (b *workflowBatch) emit(ch chan *WorkflowBatchResult, item *WorkflowBatchResult) bool {
+	select {
+	case ch <- item:
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
+
+func (b *workflowBatch) Next() bool {
+	// the ctx is cancelled once all the results are yielded, which is not an error
+	if b.done {
+		return false
+	}
+	select {
+	case item, ok := <-b.out:
+		if !ok {
+			if err := b.ctx.Err(); err != nil {
+				b.setErr(err)
+			}
+			b.done = true
+			b.cancel()
+			return false
+		}
+		b.cur = item
+		return true
+	case <-b.ctx.Done():
+		b.setErr(b.ctx.Err())
+		return false
+	}
+}
+
+func (b *workflowBatch) Current() *WorkflowBatchResult {
+	return b.cur
+}
+
+func (b *workflowBatch) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *workflowBatch) Close() {
+	b.cancel()
+}
+
+func (b *workflowBatch) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// workflowBatchOrderedWindow is the number of items per worker started ahead of the next result
+// to yield in the Ordered mode
+const workflowBatchOrderedWindow = 2
+
+// the coze error codes worth another attempt
+const (
+	workflowBatchCodeRateLimited = 4013
+	workflowBatchCodeServerMin   = 5000
+	workflowBatchCodeServerMax   = 5999
+)
+
+// defaultWorkflowBatchShouldRetry retries the failures which may pass later: the transport errors,
+// the 5xx and 429 responses and the rate limit and server error codes. Invalid requests and the
+// errors of the workflow itself fail the same on every attempt.
+func defaultWorkflowBatchShouldRetry(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if cozeErr, ok := AsCozeError(err); ok {
+		return cozeErr.Code == workflowBatchCodeRateLimited ||
+			(cozeErr.Code >= workflowBatchCodeServerMin && cozeErr.Code <= workflowBatchCodeServerMax)
+	}
+	if statusCode, ok := requestFailStatusCode(err); ok {
+		return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// requestFailStatusCode returns the status code of the error of a failed response without a coze
+// error in the body, which is "request fail: " followed by the status, such as "502 Bad Gateway"
+func requestFailStatusCode(err error) (int, bool) {
+	const prefix = "request fail: "
+	msg := err.Error()
+	if !strings.HasPrefix(msg, prefix) {
+		return 0, false
+	}
+	status := strings.TrimPrefix(msg, prefix)
+	if i := strings.IndexByte(status, ' '); i >= 0 {
+		status = status[:i]
+	}
+	statusCode, err := strconv.Atoi(status)
+	return statusCode, err == nil
+}
+
+type sliceWorkflowBatchInputs struct {
+	reqs  []*RunWorkflowsReq
+	index int
+}
+
+func (s *sliceWorkflowBatchInputs) Next() bool {
+	if s.index+1 >= len(s.reqs) {
+		return false
+	}
+	s.index++
+	return true
+}
+
+func (s *sliceWorkflowBatchInputs) Current() *RunWorkflowsReq {
+	return s.reqs[s.index]
+}
+
+func (s *sliceWorkflowBatchInputs) Err() error {
+	return nil
+}
+
+type memoryWorkflowBatchCheckpoint struct {
+	mu        sync.RWMutex
+	completed map[string]*RunWorkflowsResp
+}
+
+func (c *memoryWorkflowBatchCheckpoint) Load(key string) (*RunWorkflowsResp, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	resp, ok := c.completed[key]
+	return resp, ok
+}
+
+func (c *memoryWorkflowBatchCheckpoint) Save(key string, resp *RunWorkflowsResp) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completed[key] = resp
+	return nil
+}
+
+type fileWorkflowBatchCheckpoint struct {
+	memoryWorkflowBatchCheckpoint
+	path string
+}
+
+type workflowBatchCheckpointRecord struct {
+	Key  string            `json:"key"`
+	Resp *RunWorkflowsResp `json:"resp"`
+}
+
+func (c *fileWorkflowBatchCheckpoint) Save(key string, resp *RunWorkflowsResp) error {
+	bs, err := json.Marshal(&workflowBatchCheckpointRecord{Key: key, Resp: resp})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(bs, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	c.completed[key] = resp
+	return nil
+}
+
+// rateLimiter spaces calls evenly, allowing at most rate calls per second
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,242 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchWorkflowRuns(fn func(req *RunWorkflowsReq) (*http.Response, error)) *workflowRuns {
+	return newWorkflowRun(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		reqBody := &RunWorkflowsReq{}
+		_ = json.Unmarshal(body, reqBody)
+		return fn(reqBody)
+	})))
+}
+
+func newBatchReqs(n int) []*RunWorkflowsReq {
+	reqs := make([]*RunWorkflowsReq, 0, n)
+	for i := 0; i < n; i++ {
+		reqs = append(reqs, &RunWorkflowsReq{
+			WorkflowID: "workflow1",
+			Parameters: map[string]any{"i": i},
+		})
+	}
+	return reqs
+}
+
+func TestWorkflowRunsBatch(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("ordered with bounded concurrency", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			cur := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				old := atomic.LoadInt32(&maxInFlight)
+				if cur <= old || atomic.CompareAndSwapInt32(&maxInFlight, old, cur) {
+					break
+				}
+			}
+			i := int(req.Parameters["i"].(float64))
+			time.Sleep(time.Duration(10-i) * time.Millisecond)
+			return mockResponse(http.StatusOK, &runWorkflowsResp{
+				RunWorkflowsResp: &RunWorkflowsResp{Data: strconv.Itoa(i), Token: 10, Cost: "0.1", DebugURL: "debug"},
+			})
+		})
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(10)), &WorkflowBatchOption{
+			Concurrency: 3,
+			Ordered:     true,
+		})
+		index := 0
+		for results.Next() {
+			item := results.Current()
+			as.Nil(item.Err)
+			as.Equal(index, item.Index)
+			as.Equal(strconv.Itoa(index), item.Resp.Data)
+			as.Equal(10, item.Resp.Token)
+			as.Equal(1, item.Attempts)
+			index++
+		}
+		as.Nil(results.Err())
+		as.Equal(10, index)
+		as.LessOrEqual(atomic.LoadInt32(&maxInFlight), int32(3))
+		// the batch is cancelled once done, which is not reported as an error
+		for i := 0; i < 10; i++ {
+			as.False(results.Next())
+		}
+		as.Nil(results.Err())
+	})
+
+	t.Run("retry failed item", func(t *testing.T) {
+		var calls int32
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				return nil, errors.New("network error")
+			}
+			return mockResponse(http.StatusOK, &runWorkflowsResp{RunWorkflowsResp: &RunWorkflowsResp{Data: "ok"}})
+		})
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(1)), &WorkflowBatchOption{
+			MaxRetries:    2,
+			RetryInterval: time.Millisecond,
+		})
+		as.True(results.Next())
+		as.Nil(results.Current().Err)
+		as.Equal(3, results.Current().Attempts)
+		as.False(results.Next())
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{baseResponse: baseResponse{Code: 5000, Msg: "internal error"}})
+		})
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(1)), &WorkflowBatchOption{
+			MaxRetries:    1,
+			RetryInterval: time.Millisecond,
+		})
+		as.True(results.Next())
+		as.NotNil(results.Current().Err)
+		as.Equal(2, results.Current().Attempts)
+		as.False(results.Next())
+	})
+
+	t.Run("no retry for invalid request", func(t *testing.T) {
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{baseResponse: baseResponse{Code: 4000, Msg: "invalid"}})
+		})
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(1)), &WorkflowBatchOption{
+			MaxRetries:    3,
+			RetryInterval: time.Millisecond,
+		})
+		as.True(results.Next())
+		as.NotNil(results.Current().Err)
+		as.Equal(1, results.Current().Attempts)
+		as.False(results.Next())
+	})
+
+	t.Run("default should retry", func(t *testing.T) {
+		as.True(defaultWorkflowBatchShouldRetry(&Error{Code: 4013}))
+		as.True(defaultWorkflowBatchShouldRetry(&Error{Code: 5000}))
+		as.False(defaultWorkflowBatchShouldRetry(&Error{Code: 4000}))
+		as.False(defaultWorkflowBatchShouldRetry(&Error{Code: 720701013}))
+		as.True(defaultWorkflowBatchShouldRetry(errors.New("request fail: 502 Bad Gateway")))
+		as.True(defaultWorkflowBatchShouldRetry(errors.New("request fail: 429 Too Many Requests")))
+		as.False(defaultWorkflowBatchShouldRetry(errors.New("request fail: 404 Not Found")))
+		as.False(defaultWorkflowBatchShouldRetry(errors.New("request fail: ")))
+		as.False(defaultWorkflowBatchShouldRetry(context.Canceled))
+		as.False(defaultWorkflowBatchShouldRetry(errors.New("invalid param")))
+	})
+
+	t.Run("option is not modified", func(t *testing.T) {
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{RunWorkflowsResp: &RunWorkflowsResp{Data: "ok"}})
+		})
+		opt := &WorkflowBatchOption{}
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(2)), opt)
+		for results.Next() {
+		}
+		as.Equal(&WorkflowBatchOption{}, opt)
+	})
+
+	t.Run("ordered window", func(t *testing.T) {
+		release := make(chan struct{})
+		var maxStarted int32
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			i := int32(req.Parameters["i"].(float64))
+			for {
+				old := atomic.LoadInt32(&maxStarted)
+				if i <= old || atomic.CompareAndSwapInt32(&maxStarted, old, i) {
+					break
+				}
+			}
+			if i == 0 {
+				<-release
+			}
+			return mockResponse(http.StatusOK, &runWorkflowsResp{RunWorkflowsResp: &RunWorkflowsResp{Data: "ok"}})
+		})
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(20)), &WorkflowBatchOption{
+			Concurrency: 2,
+			Ordered:     true,
+		})
+		time.Sleep(50 * time.Millisecond)
+		// item 0 blocks, only the items in the window of 2*Concurrency are started
+		as.Equal(int32(3), atomic.LoadInt32(&maxStarted))
+		close(release)
+		count := 0
+		for results.Next() {
+			as.Equal(count, results.Current().Index)
+			count++
+		}
+		as.Equal(20, count)
+	})
+
+	t.Run("checkpoint skips completed items", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+		var mu sync.Mutex
+		executed := map[int]bool{}
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			mu.Lock()
+			executed[int(req.Parameters["i"].(float64))] = true
+			mu.Unlock()
+			return mockResponse(http.StatusOK, &runWorkflowsResp{RunWorkflowsResp: &RunWorkflowsResp{Data: "ok", Token: 5}})
+		})
+		checkpoint, err := NewFileWorkflowBatchCheckpoint(path)
+		as.Nil(err)
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(3)), &WorkflowBatchOption{Checkpoint: checkpoint})
+		for results.Next() {
+			as.False(results.Current().Skipped)
+		}
+
+		executed = map[int]bool{}
+		checkpoint, err = NewFileWorkflowBatchCheckpoint(path)
+		as.Nil(err)
+		results = runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(5)), &WorkflowBatchOption{Checkpoint: checkpoint, Ordered: true})
+		skipped := 0
+		for results.Next() {
+			if results.Current().Skipped {
+				skipped++
+				as.Equal(5, results.Current().Resp.Token)
+			}
+		}
+		as.Equal(3, skipped)
+		as.Equal(map[int]bool{3: true, 4: true}, executed)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{RunWorkflowsResp: &RunWorkflowsResp{Data: "ok"}})
+		})
+		start := time.Now()
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(3)), &WorkflowBatchOption{RateLimit: 20})
+		count := 0
+		for results.Next() {
+			count++
+		}
+		as.Equal(3, count)
+		as.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		runs := newBatchWorkflowRuns(func(req *RunWorkflowsReq) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{RunWorkflowsResp: &RunWorkflowsResp{Data: "ok"}})
+		})
+		results := runs.Batch(context.Background(), NewWorkflowBatchInputs(newBatchReqs(100)), &WorkflowBatchOption{Concurrency: 2})
+		as.True(results.Next())
+		results.Close()
+		for results.Next() {
+		}
+		as.ErrorIs(results.Err(), context.Canceled)
+	})
+}
@@ -0,0 +1,406 @@
+package coze
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Trace builds the execution trace of a workflow run, it retrieves the run history, the output of
+// every executed node and, recursively, the traces of the sub workflows.
+//
+// The open API does not expose node inputs nor node start and end times, only the time a node was
+// last updated. The end time of a finished node is approximated by it, and its start time by the
+// end time of the node finished right before it, so the durations are approximate.
+func (r *workflowRunsHistories) Trace(ctx context.Context, req *TraceWorkflowRunsHistoriesReq) (*WorkflowTrace, error) {
+	maxDepth := req.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 3
+	}
+	return r.trace(ctx, req, req.WorkflowID, req.ExecuteID, maxDepth)
+}
+
+func (r *workflowRunsHistories) trace(ctx context.Context, req *TraceWorkflowRunsHistoriesReq, workflowID, executeID string, depth int) (*WorkflowTrace, error) {
+	resp, err := r.Retrieve(ctx, &RetrieveWorkflowsRunsHistoriesReq{
+		WorkflowID: workflowID,
+		ExecuteID:  executeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	trace := &WorkflowTrace{
+		WorkflowID: workflowID,
+		ExecuteID:  executeID,
+	}
+	if len(resp.Histories) == 0 {
+		return trace, nil
+	}
+	history := resp.Histories[0]
+	trace.Status = history.ExecuteStatus
+	trace.StartTime = unixTime(history.CreateTime)
+	if history.ExecuteStatus != WorkflowExecuteStatusRunning {
+		// update_time is the last update of the run, the end time once it's finished
+		end := unixTime(history.UpdateTime)
+		trace.EndTime = &end
+		trace.Duration = end.Sub(trace.StartTime)
+	}
+	trace.Output = history.Output
+	trace.ErrorCode = history.ErrorCode
+	trace.ErrorMessage = history.ErrorMessage
+	trace.DebugURL = history.DebugURL
+	trace.LogID = history.LogID
+
+	for _, status := range history.NodeExecuteStatus {
+		if status == nil {
+			continue
+		}
+		node := &WorkflowTraceNode{
+			NodeID:          status.NodeID,
+			NodeExecuteUUID: status.NodeExecuteUUID,
+			LoopIndex:       status.LoopIndex,
+			BatchIndex:      status.BatchIndex,
+			IsFinish:        status.IsFinish,
+			SubExecuteID:    ptrValue(status.SubExecuteID),
+			SubWorkflowID:   req.SubWorkflowIDs[status.NodeID],
+			updateTime:      unixTime(status.UpdateTime),
+		}
+		// the status of the node output is merged before the timing is computed from it
+		if node.NodeExecuteUUID != "" {
+			output, err := r.ExecuteNodes.Retrieve(ctx, &RetrieveWorkflowsRunsHistoriesExecuteNodesReq{
+				WorkflowID:      workflowID,
+				ExecuteID:       executeID,
+				NodeExecuteUUID: node.NodeExecuteUUID,
+			})
+			if err != nil {
+				node.Error = err.Error()
+			} else if output == nil {
+				node.Error = "node output is missing"
+			} else {
+				node.Output = output.NodeOutput
+				node.IsFinish = output.IsFinish
+			}
+		}
+		trace.Nodes = append(trace.Nodes, node)
+	}
+	sort.SliceStable(trace.Nodes, func(i, j int) bool {
+		a, b := trace.Nodes[i], trace.Nodes[j]
+		if !a.updateTime.Equal(b.updateTime) {
+			return a.updateTime.Before(b.updateTime)
+		}
+		if ptrValue(a.LoopIndex) != ptrValue(b.LoopIndex) {
+			return ptrValue(a.LoopIndex) < ptrValue(b.LoopIndex)
+		}
+		if ptrValue(a.BatchIndex) != ptrValue(b.BatchIndex) {
+			return ptrValue(a.BatchIndex) < ptrValue(b.BatchIndex)
+		}
+		return a.NodeID < b.NodeID
+	})
+
+	start := trace.StartTime
+	for _, node := range trace.Nodes {
+		node.StartTime = start
+		if node.updateTime.Before(node.StartTime) {
+			node.StartTime = node.updateTime
+		}
+		if node.IsFinish {
+			end := node.updateTime
+			node.EndTime = &end
+			node.Duration = end.Sub(node.StartTime)
+		}
+		start = node.updateTime
+
+		// the sub workflow can't be retrieved without its own workflow ID
+		if node.SubExecuteID != "" && node.SubWorkflowID != "" && depth > 1 {
+			sub, err := r.trace(ctx, req, node.SubWorkflowID, node.SubExecuteID, depth-1)
+			if err != nil {
+				node.Error = err.Error()
+			} else {
+				node.SubWorkflow = sub
+			}
+		}
+	}
+	return trace, nil
+}
+
+// TraceWorkflowRunsHistoriesReq represents request for building the trace of a workflow run
+type TraceWorkflowRunsHistoriesReq struct {
+	// The ID of the workflow.
+	WorkflowID string
+
+	// The ID of the workflow execution.
+	ExecuteID string
+
+	// The max nesting depth of sub workflows to retrieve, default 3.
+	MaxDepth int
+
+	// The IDs of the sub workflows by the IDs of the nodes starting them, the run history only
+	// returns the execute IDs of the sub workflows. The sub workflows of unknown IDs are not traced.
+	SubWorkflowIDs map[string]string
+}
+
+// WorkflowTrace represents the execution trace of a workflow run
+type WorkflowTrace struct {
+	WorkflowID string                `json:"workflow_id"`
+	ExecuteID  string                `json:"execute_id"`
+	Status     WorkflowExecuteStatus `json:"status"`
+	StartTime  time.Time             `json:"start_time"`
+	// The last update time of the run, only set once it's finished. A resumed run counts the time
+	// it was interrupted.
+	EndTime  *time.Time    `json:"end_time,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	// The output of the workflow.
+	Output       string `json:"output,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	DebugURL     string `json:"debug_url,omitempty"`
+	LogID        string `json:"log_id,omitempty"`
+
+	// The executed nodes, ordered by end time.
+	Nodes []*WorkflowTraceNode `json:"nodes"`
+}
+
+// WorkflowTraceNode represents a node execution in a workflow trace
+type WorkflowTraceNode struct {
+	NodeID          string `json:"node_id"`
+	NodeExecuteUUID string `json:"node_execute_uuid"`
+	LoopIndex       *int   `json:"loop_index,omitempty"`
+	BatchIndex      *int   `json:"batch_index,omitempty"`
+	IsFinish        bool   `json:"is_finish"`
+
+	// The estimated start time of the node.
+	StartTime time.Time `json:"start_time"`
+	// The last update time of the node, only set once it's finished.
+	EndTime *time.Time `json:"end_time,omitempty"`
+	// The approximate duration of the node, 0 if it's not finished.
+	Duration time.Duration `json:"duration"`
+
+	// The output of the node.
+	Output string `json:"output,omitempty"`
+
+	// The error met when retrieving the node output or the sub workflow trace.
+	Error string `json:"error,omitempty"`
+
+	// The execution ID, the workflow ID and the trace of the sub workflow started by this node.
+	SubExecuteID  string         `json:"sub_execute_id,omitempty"`
+	SubWorkflowID string         `json:"sub_workflow_id,omitempty"`
+	SubWorkflow   *WorkflowTrace `json:"sub_workflow,omitempty"`
+
+	updateTime time.Time
+}
+
+// IsSuccess returns whether the workflow run succeeded
+func (t *WorkflowTrace) IsSuccess() bool {
+	return t.Status == WorkflowExecuteStatusSuccess
+}
+
+// MarshalIndentJSON exports the trace as indented JSON
+func (t *WorkflowTrace) MarshalIndentJSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// WorkflowTraceSpanStatus represents the status of a span, using OpenTelemetry status codes
+type WorkflowTraceSpanStatus int
+
+const (
+	WorkflowTraceSpanStatusUnset WorkflowTraceSpanStatus = 0
+	WorkflowTraceSpanStatusOK    WorkflowTraceSpanStatus = 1
+	WorkflowTraceSpanStatusError WorkflowTraceSpanStatus = 2
+)
+
+// WorkflowTraceSpan represents a span of the OpenTelemetry compatible span tree of a trace
+type WorkflowTraceSpan struct {
+	TraceID       string
+	SpanID        string
+	ParentSpanID  string
+	Name          string
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]string
+	Status        WorkflowTraceSpanStatus
+	StatusMessage string
+	Children      []*WorkflowTraceSpan
+}
+
+// SpanTree converts the trace into a span tree: the workflow run is the root span, every node is a
+// child span, and the sub workflows are nested under the node that started them.
+//
+// Trace and span IDs are derived from the execute ID, so exporting the same run twice gives the
+// same IDs.
+func (t *WorkflowTrace) SpanTree() *WorkflowTraceSpan {
+	traceID := hashID(t.ExecuteID, 16)
+	return t.spanTree(traceID, "")
+}
+
+func (t *WorkflowTrace) spanTree(traceID, parentSpanID string) *WorkflowTraceSpan {
+	root := &WorkflowTraceSpan{
+		TraceID:      traceID,
+		SpanID:       hashID("workflow:"+t.ExecuteID, 8),
+		ParentSpanID: parentSpanID,
+		Name:         "workflow " + t.WorkflowID,
+		StartTime:    t.StartTime,
+		EndTime:      spanEndTime(t.StartTime, t.EndTime),
+		Attributes: map[string]string{
+			"coze.workflow_id":    t.WorkflowID,
+			"coze.execute_id":     t.ExecuteID,
+			"coze.execute_status": string(t.Status),
+			"coze.debug_url":      t.DebugURL,
+			"coze.log_id":         t.LogID,
+		},
+	}
+	switch t.Status {
+	case WorkflowExecuteStatusSuccess:
+		root.Status = WorkflowTraceSpanStatusOK
+	case WorkflowExecuteStatusFail:
+		root.Status = WorkflowTraceSpanStatusError
+		root.StatusMessage = t.ErrorMessage
+		root.Attributes["coze.error_code"] = t.ErrorCode
+	}
+
+	for _, node := range t.Nodes {
+		span := &WorkflowTraceSpan{
+			TraceID:      traceID,
+			SpanID:       hashID("node:"+t.ExecuteID+":"+node.NodeExecuteUUID+":"+node.NodeID, 8),
+			ParentSpanID: root.SpanID,
+			Name:         "node " + node.NodeID,
+			StartTime:    node.StartTime,
+			EndTime:      spanEndTime(node.StartTime, node.EndTime),
+			Attributes: map[string]string{
+				"coze.node_id":           node.NodeID,
+				"coze.node_execute_uuid": node.NodeExecuteUUID,
+				"coze.node_output":       node.Output,
+			},
+		}
+		if node.LoopIndex != nil {
+			span.Attributes["coze.loop_index"] = strconv.Itoa(*node.LoopIndex)
+		}
+		if node.BatchIndex != nil {
+			span.Attributes["coze.batch_index"] = strconv.Itoa(*node.BatchIndex)
+		}
+		if node.Error != "" {
+			span.Status = WorkflowTraceSpanStatusError
+			span.StatusMessage = node.Error
+		} else if node.IsFinish {
+			span.Status = WorkflowTraceSpanStatusOK
+		}
+		if node.SubWorkflow != nil {
+			span.Children = append(span.Children, node.SubWorkflow.spanTree(traceID, span.SpanID))
+		}
+		root.Children = append(root.Children, span)
+	}
+	return root
+}
+
+// MarshalOTLPJSON exports the span tree of the trace in the OTLP/JSON format, so it can be loaded
+// by OpenTelemetry collectors and tracing backends for offline analysis.
+func (t *WorkflowTrace) MarshalOTLPJSON() ([]byte, error) {
+	var spans []*otlpSpan
+	var walk func(span *WorkflowTraceSpan)
+	walk = func(span *WorkflowTraceSpan) {
+		spans = append(spans, span.toOTLP())
+		for _, child := range span.Children {
+			walk(child)
+		}
+	}
+	walk(t.SpanTree())
+
+	return json.Marshal(map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": []*otlpAttribute{newOTLPAttribute("service.name", "coze-workflow")},
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]any{"name": "github.com/coze-dev/coze-go"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	})
+}
+
+type otlpSpan struct {
+	TraceID           string           `json:"traceId"`
+	SpanID            string           `json:"spanId"`
+	ParentSpanID      string           `json:"parentSpanId,omitempty"`
+	Name              string           `json:"name"`
+	Kind              int              `json:"kind"`
+	StartTimeUnixNano string           `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string           `json:"endTimeUnixNano"`
+	Attributes        []*otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus      `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func newOTLPAttribute(key, value string) *otlpAttribute {
+	attr := &otlpAttribute{Key: key}
+	attr.Value.StringValue = value
+	return attr
+}
+
+func (s *WorkflowTraceSpan) toOTLP() *otlpSpan {
+	keys := make([]string, 0, len(s.Attributes))
+	for k, v := range s.Attributes {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	attributes := make([]*otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		attributes = append(attributes, newOTLPAttribute(k, s.Attributes[k]))
+	}
+	return &otlpSpan{
+		TraceID:           s.TraceID,
+		SpanID:            s.SpanID,
+		ParentSpanID:      s.ParentSpanID,
+		Name:              s.Name,
+		Kind:              1, // SPAN_KIND_INTERNAL
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		Attributes:        attributes,
+		Status:            &otlpStatus{Code: int(s.Status), Message: s.StatusMessage},
+	}
+}
+
+// spanEndTime returns the end time of a span, the unfinished ones end at their start
+func spanEndTime(start time.Time, end *time.Time) time.Time {
+	if end == nil {
+		return start
+	}
+	return *end
+}
+
+// hashID derives a hex ID of size bytes from s
+func hashID(s string, size int) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:size])
+}
+
+// unixTime converts a unix timestamp in seconds or in milliseconds to time.Time
+func unixTime(v int) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	if int64(v) > 1e12 {
+		return time.UnixMilli(int64(v))
+	}
+	return time.Unix(int64(v), 0)
+}
@@ -0,0 +1,162 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowRunsHistoriesTrace(t *testing.T) {
+	as := assert.New(t)
+	histories := newWorkflowRunsHistories(newCoreWithTransport(newMockTransport(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/v1/workflows/workflow1/run_histories/exec1":
+			return mockResponse(http.StatusOK, &retrieveWorkflowRunsHistoriesResp{
+				RetrieveWorkflowRunsHistoriesResp: &RetrieveWorkflowRunsHistoriesResp{
+					Histories: []*WorkflowRunHistory{{
+						ExecuteID:     "exec1",
+						ExecuteStatus: WorkflowExecuteStatusSuccess,
+						CreateTime:    1700000000,
+						UpdateTime:    1700000010,
+						Output:        `{"output":"done"}`,
+						NodeExecuteStatus: map[string]*WorkflowRunHistoryNodeExecuteStatus{
+							"end":  {NodeID: "end", IsFinish: true, UpdateTime: 1700000010000, NodeExecuteUUID: "uuid-end"},
+							"sub":  {NodeID: "sub", IsFinish: true, UpdateTime: 1700000006000, NodeExecuteUUID: "uuid-sub", SubExecuteID: ptr("exec2")},
+							"llm":  {NodeID: "llm", IsFinish: false, UpdateTime: 1700000002000, NodeExecuteUUID: "uuid-llm"},
+							"wait": {NodeID: "wait", IsFinish: false, UpdateTime: 1700000011000},
+						},
+					}},
+				},
+			})
+		case "/v1/workflows/workflow2/run_histories/exec2":
+			return mockResponse(http.StatusOK, &retrieveWorkflowRunsHistoriesResp{
+				RetrieveWorkflowRunsHistoriesResp: &RetrieveWorkflowRunsHistoriesResp{
+					Histories: []*WorkflowRunHistory{{
+						ExecuteID:     "exec2",
+						ExecuteStatus: WorkflowExecuteStatusFail,
+						CreateTime:    1700000002,
+						UpdateTime:    1700000006,
+						ErrorCode:     "500",
+						ErrorMessage:  "sub failed",
+					}},
+				},
+			})
+		case "/v1/workflows/workflow1/run_histories/exec1/execute_nodes/uuid-llm":
+			return mockResponse(http.StatusOK, &retrieveWorkflowRunsHistoriesExecuteNodeResp{
+				Data: &RetrieveWorkflowRunsHistoriesExecuteNodesResp{IsFinish: true, NodeOutput: "llm output"},
+			})
+		case "/v1/workflows/workflow1/run_histories/exec1/execute_nodes/uuid-sub":
+			return mockResponse(http.StatusOK, &retrieveWorkflowRunsHistoriesExecuteNodeResp{
+				Data: &RetrieveWorkflowRunsHistoriesExecuteNodesResp{IsFinish: true, NodeOutput: "sub output"},
+			})
+		default:
+			return mockResponse(http.StatusOK, &baseResponse{Code: 4000, Msg: "not found"})
+		}
+	})))
+
+	trace, err := histories.Trace(context.Background(), &TraceWorkflowRunsHistoriesReq{
+		WorkflowID:     "workflow1",
+		ExecuteID:      "exec1",
+		SubWorkflowIDs: map[string]string{"sub": "workflow2"},
+	})
+	as.Nil(err)
+	as.True(trace.IsSuccess())
+	as.Equal(10*time.Second, trace.Duration)
+	as.Len(trace.Nodes, 4)
+	// the node is finished by its output, the timing follows it
+	as.Equal("llm", trace.Nodes[0].NodeID)
+	as.True(trace.Nodes[0].IsFinish)
+	as.Equal(2*time.Second, trace.Nodes[0].Duration)
+	as.Equal("llm output", trace.Nodes[0].Output)
+	as.Equal("sub", trace.Nodes[1].NodeID)
+	as.Equal(4*time.Second, trace.Nodes[1].Duration)
+	as.NotNil(trace.Nodes[1].SubWorkflow)
+	as.Equal("workflow2", trace.Nodes[1].SubWorkflow.WorkflowID)
+	as.Equal("sub failed", trace.Nodes[1].SubWorkflow.ErrorMessage)
+	as.Equal("end", trace.Nodes[2].NodeID)
+	as.NotEmpty(trace.Nodes[2].Error)
+	// the unfinished node has no end time
+	as.Equal("wait", trace.Nodes[3].NodeID)
+	as.Nil(trace.Nodes[3].EndTime)
+	as.Equal(time.Duration(0), trace.Nodes[3].Duration)
+
+	t.Run("json", func(t *testing.T) {
+		bs, err := trace.MarshalIndentJSON()
+		as.Nil(err)
+		decoded := &WorkflowTrace{}
+		as.Nil(json.Unmarshal(bs, decoded))
+		as.Equal("exec2", decoded.Nodes[1].SubWorkflow.ExecuteID)
+		as.NotNil(decoded.Nodes[0].EndTime)
+		// the unfinished end times are left out
+		as.Nil(decoded.Nodes[3].EndTime)
+		as.NotContains(string(bs), "0001-01-01")
+	})
+
+	t.Run("span tree", func(t *testing.T) {
+		root := trace.SpanTree()
+		as.Len(root.TraceID, 32)
+		as.Len(root.SpanID, 16)
+		as.Equal(WorkflowTraceSpanStatusOK, root.Status)
+		as.Len(root.Children, 4)
+		subNode := root.Children[1]
+		as.Equal(root.SpanID, subNode.ParentSpanID)
+		as.Len(subNode.Children, 1)
+		as.Equal(subNode.SpanID, subNode.Children[0].ParentSpanID)
+		as.Equal(root.TraceID, subNode.Children[0].TraceID)
+		as.Equal(WorkflowTraceSpanStatusError, subNode.Children[0].Status)
+		as.Equal(WorkflowTraceSpanStatusError, root.Children[2].Status)
+		as.Equal(root.SpanID, trace.SpanTree().SpanID)
+	})
+
+	t.Run("otlp json", func(t *testing.T) {
+		bs, err := trace.MarshalOTLPJSON()
+		as.Nil(err)
+		var data struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []*otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		as.Nil(json.Unmarshal(bs, &data))
+		spans := data.ResourceSpans[0].ScopeSpans[0].Spans
+		as.Len(spans, 6)
+		as.Equal("workflow workflow1", spans[0].Name)
+		as.Equal("1700000000000000000", spans[0].StartTimeUnixNano)
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		trace, err := histories.Trace(context.Background(), &TraceWorkflowRunsHistoriesReq{
+			WorkflowID:     "workflow1",
+			ExecuteID:      "exec1",
+			MaxDepth:       1,
+			SubWorkflowIDs: map[string]string{"sub": "workflow2"},
+		})
+		as.Nil(err)
+		as.Nil(trace.Nodes[1].SubWorkflow)
+		as.Equal("exec2", trace.Nodes[1].SubExecuteID)
+	})
+
+	t.Run("sub workflow id", func(t *testing.T) {
+		// the sub workflow of an unknown ID is not traced with the ID of the parent
+		trace, err := histories.Trace(context.Background(), &TraceWorkflowRunsHistoriesReq{
+			WorkflowID: "workflow1",
+			ExecuteID:  "exec1",
+		})
+		as.Nil(err)
+		as.Nil(trace.Nodes[1].SubWorkflow)
+		as.Empty(trace.Nodes[1].Error)
+
+		trace, err = histories.Trace(context.Background(), &TraceWorkflowRunsHistoriesReq{
+			WorkflowID:     "workflow1",
+			ExecuteID:      "exec1",
+			SubWorkflowIDs: map[string]string{"sub": "workflow2"},
+		})
+		as.Nil(err)
+		as.Equal("sub failed", trace.Nodes[1].SubWorkflow.ErrorMessage)
+	})
+}
@@ -0,0 +1,191 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// RunTyped runs the workflow synchronously, encodes in as the workflow parameters and decodes the
+// end node output into Out.
+//
+// In must encode to a JSON object (a struct or a map). Out can be a struct, a map, or a string when
+// the workflow returns a non-JSON result.
+func RunTyped[In, Out any](ctx context.Context, api *CozeAPI, workflowID string, in In) (Out, error) {
+	var out Out
+	parameters, err := encodeWorkflowParameters(in)
+	if err != nil {
+		return out, err
+	}
+	resp, err := api.Workflows.Runs.Create(ctx, &RunWorkflowsReq{
+		WorkflowID: workflowID,
+		Parameters: parameters,
+	})
+	if err != nil {
+		return out, err
+	}
+	if err := decodeWorkflowOutput(resp.ExecuteID, resp.Data, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// StreamTyped runs the workflow in streaming mode, encodes in as the workflow parameters and decodes
+// the output of the last node that emitted messages (the end node) into Out.
+//
+// Message chunks of the end node are concatenated before decoding. An Error event is returned as
+// *WorkflowEventError, and an Interrupt event is returned as *WorkflowInterruptedError so the
+// caller can resume the workflow.
+func StreamTyped[In, Out any](ctx context.Context, api *CozeAPI, workflowID string, in In) (Out, error) {
+	var out Out
+	parameters, err := encodeWorkflowParameters(in)
+	if err != nil {
+		return out, err
+	}
+	stream, err := api.Workflows.Runs.Stream(ctx, &RunWorkflowsReq{
+		WorkflowID: workflowID,
+		Parameters: parameters,
+	})
+	if err != nil {
+		return out, err
+	}
+	defer stream.Close()
+
+	content, err := collectWorkflowStreamOutput(stream)
+	if err != nil {
+		return out, err
+	}
+	if err := decodeWorkflowOutput("", content, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// WorkflowOutputError is returned when the workflow output can not be decoded into the requested
+// Go type.
+type WorkflowOutputError struct {
+	// The execution ID, only set for synchronous runs.
+	ExecuteID string
+	// The Go type the output was decoded into.
+	Type reflect.Type
+	// The raw output returned by the workflow.
+	Output string
+	// The underlying decode error.
+	Err error
+}
+
+// Error implements the error interface
+func (e *WorkflowOutputError) Error() string {
+	return fmt.Sprintf("decode workflow output into %s failed, execute_id=%s, output=%s, err=%s",
+		e.Type, e.ExecuteID, e.Output, e.Err)
+}
+
+// Unwrap returns the underlying decode error
+func (e *WorkflowOutputError) Unwrap() error {
+	return e.Err
+}
+
+// WorkflowInterruptedError is returned by the typed stream helper when the workflow is interrupted
+// and waits for a resume.
+type WorkflowInterruptedError struct {
+	Interrupt *WorkflowEventInterrupt
+}
+
+// Error implements the error interface
+func (e *WorkflowInterruptedError) Error() string {
+	if e.Interrupt == nil || e.Interrupt.InterruptData == nil {
+		return "workflow interrupted"
+	}
+	return fmt.Sprintf("workflow interrupted, node_title=%s, event_id=%s, type=%d",
+		e.Interrupt.NodeTitle, e.Interrupt.InterruptData.EventID, e.Interrupt.InterruptData.Type)
+}
+
+// Error implements the error interface
+func (e *WorkflowEventError) Error() string {
+	return fmt.Sprintf("workflow error, code=%d, message=%s", e.ErrorCode, e.ErrorMessage)
+}
+
+func encodeWorkflowParameters(in any) (map[string]any, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if parameters, ok := in.(map[string]any); ok {
+		return parameters, nil
+	}
+	bs, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("encode workflow parameters failed: %w", err)
+	}
+	if string(bs) == "null" {
+		return nil, nil
+	}
+	parameters := map[string]any{}
+	if err := json.Unmarshal(bs, &parameters); err != nil {
+		return nil, fmt.Errorf("workflow parameters must be a JSON object, got %T: %w", in, err)
+	}
+	return parameters, nil
+}
+
+func decodeWorkflowOutput(executeID, output string, out any) error {
+	newErr := func(err error) error {
+		return &WorkflowOutputError{
+			ExecuteID: executeID,
+			Type:      reflect.TypeOf(out).Elem(),
+			Output:    output,
+			Err:       err,
+		}
+	}
+	if output == "" {
+		return newErr(errors.New("empty output"))
+	}
+	if s, ok := out.(*string); ok {
+		// non-JSON string results are returned as is, JSON strings are unquoted
+		if err := json.Unmarshal([]byte(output), s); err != nil {
+			*s = output
+		}
+		return nil
+	}
+	if err := json.Unmarshal([]byte(output), out); err != nil {
+		return newErr(err)
+	}
+	return nil
+}
+
+func collectWorkflowStreamOutput(stream Stream[WorkflowEvent]) (string, error) {
+	var (
+		nodeTitle string
+		content   strings.Builder
+	)
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+		switch event.Event {
+		case WorkflowEventTypeMessage:
+			if event.Message == nil {
+				continue
+			}
+			if event.Message.NodeTitle != nodeTitle {
+				nodeTitle = event.Message.NodeTitle
+				content.Reset()
+			}
+			content.WriteString(event.Message.Content)
+		case WorkflowEventTypeError:
+			if event.Error == nil {
+				return "", errors.New("workflow error")
+			}
+			return "", event.Error
+		case WorkflowEventTypeInterrupt:
+			return "", &WorkflowInterruptedError{Interrupt: event.Interrupt}
+		case WorkflowEventTypeDone:
+			return content.String(), nil
+		}
+	}
+}
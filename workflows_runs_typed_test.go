@@ -0,0 +1,136 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedWorkflowInput struct {
+	City string `json:"city"`
+	Days int    `json:"days"`
+}
+
+type typedWorkflowOutput struct {
+	Output string `json:"output"`
+	Score  int    `json:"score"`
+}
+
+func newTypedWorkflowAPI(fn func(req *http.Request) (*http.Response, error)) *CozeAPI {
+	return &CozeAPI{Workflows: newWorkflows(newCoreWithTransport(newMockTransport(fn)))}
+}
+
+func TestRunTyped(t *testing.T) {
+	as := assert.New(t)
+	t.Run("success", func(t *testing.T) {
+		api := newTypedWorkflowAPI(func(req *http.Request) (*http.Response, error) {
+			as.Equal("/v1/workflow/run", req.URL.Path)
+			body, _ := io.ReadAll(req.Body)
+			reqBody := RunWorkflowsReq{}
+			as.Nil(json.Unmarshal(body, &reqBody))
+			as.Equal("workflow1", reqBody.WorkflowID)
+			as.Equal("beijing", reqBody.Parameters["city"])
+			as.Equal(float64(3), reqBody.Parameters["days"])
+			return mockResponse(http.StatusOK, &runWorkflowsResp{
+				RunWorkflowsResp: &RunWorkflowsResp{
+					ExecuteID: "exec1",
+					Data:      `{"output":"sunny","score":9}`,
+				},
+			})
+		})
+		out, err := RunTyped[typedWorkflowInput, typedWorkflowOutput](context.Background(), api, "workflow1", typedWorkflowInput{City: "beijing", Days: 3})
+		as.Nil(err)
+		as.Equal("sunny", out.Output)
+		as.Equal(9, out.Score)
+	})
+
+	t.Run("string output", func(t *testing.T) {
+		api := newTypedWorkflowAPI(func(req *http.Request) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{
+				RunWorkflowsResp: &RunWorkflowsResp{Data: `plain text`},
+			})
+		})
+		out, err := RunTyped[map[string]any, string](context.Background(), api, "workflow1", nil)
+		as.Nil(err)
+		as.Equal("plain text", out)
+	})
+
+	t.Run("schema mismatch", func(t *testing.T) {
+		api := newTypedWorkflowAPI(func(req *http.Request) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &runWorkflowsResp{
+				RunWorkflowsResp: &RunWorkflowsResp{ExecuteID: "exec1", Data: `{"output":"sunny","score":"high"}`},
+			})
+		})
+		_, err := RunTyped[typedWorkflowInput, typedWorkflowOutput](context.Background(), api, "workflow1", typedWorkflowInput{})
+		as.NotNil(err)
+		var outputErr *WorkflowOutputError
+		as.True(errors.As(err, &outputErr))
+		as.Equal("exec1", outputErr.ExecuteID)
+		as.Contains(err.Error(), "coze.typedWorkflowOutput")
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := RunTyped[[]string, string](context.Background(), newTypedWorkflowAPI(nil), "workflow1", []string{"a"})
+		as.NotNil(err)
+	})
+}
+
+func TestStreamTyped(t *testing.T) {
+	as := assert.New(t)
+	t.Run("success", func(t *testing.T) {
+		api := newTypedWorkflowAPI(func(req *http.Request) (*http.Response, error) {
+			as.Equal("/v1/workflow/stream_run", req.URL.Path)
+			return mockStreamResponse(`id:0
+event:Message
+data:{"content":"hello","node_title":"Message","node_seq_id":"0","node_is_finish":true}
+
+id:1
+event:Message
+data:{"content":"{\"output\":\"sun","node_title":"End","node_seq_id":"0","node_is_finish":false}
+
+id:2
+event:Message
+data:{"content":"ny\",\"score\":7}","node_title":"End","node_seq_id":"1","node_is_finish":true}
+
+id:3
+event:Done
+data:{"debug_url":"https://www.coze.cn/work_flow?***"}
+`)
+		})
+		out, err := StreamTyped[typedWorkflowInput, typedWorkflowOutput](context.Background(), api, "workflow1", typedWorkflowInput{City: "beijing"})
+		as.Nil(err)
+		as.Equal("sunny", out.Output)
+		as.Equal(7, out.Score)
+	})
+
+	t.Run("error event", func(t *testing.T) {
+		api := newTypedWorkflowAPI(func(req *http.Request) (*http.Response, error) {
+			return mockStreamResponse(`id:0
+event:Error
+data:{"error_code":4000,"error_message":"invalid parameter"}
+`)
+		})
+		_, err := StreamTyped[typedWorkflowInput, typedWorkflowOutput](context.Background(), api, "workflow1", typedWorkflowInput{})
+		var eventErr *WorkflowEventError
+		as.True(errors.As(err, &eventErr))
+		as.Equal(4000, eventErr.ErrorCode)
+	})
+
+	t.Run("interrupt event", func(t *testing.T) {
+		api := newTypedWorkflowAPI(func(req *http.Request) (*http.Response, error) {
+			return mockStreamResponse(`id:0
+event:Interrupt
+data:{"interrupt_data":{"event_id":"event1","type":2},"node_title":"Question"}
+`)
+		})
+		_, err := StreamTyped[typedWorkflowInput, typedWorkflowOutput](context.Background(), api, "workflow1", typedWorkflowInput{})
+		var interruptErr *WorkflowInterruptedError
+		as.True(errors.As(err, &interruptErr))
+		as.Equal("event1", interruptErr.Interrupt.InterruptData.EventID)
+	})
+}